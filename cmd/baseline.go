@@ -0,0 +1,79 @@
+package migroCMD
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// CreateBaseline adopts a database that has no migration history: it
+// writes its current schema as a single baseline migration and marks that
+// migration as applied without running it
+// @param config *CONFIG
+// @param db *pgxpool.Pool
+// @return error
+func CreateBaseline(config *CONFIG, db *pgxpool.Pool) error {
+	ctx, cancel := commandContext(config)
+	defer cancel()
+
+	// Make sure no other process is migrating this database
+	unlock, err := acquireMigrationLock(ctx, db, config)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	_, err = createBaselineMigration(ctx, NewMigrator(config, db))
+	return err
+}
+
+// Introspect the live database, write its schema as a baseline migration
+// and mark that migration as applied without running it. Only databases
+// without applied migrations can be baselined.
+// @param ctx context.Context
+// @param migrator *Migrator
+// @return *Migration, error
+func createBaselineMigration(ctx context.Context, migrator *Migrator) (*Migration, error) {
+	applied, err := migrator.appliedVersions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(applied) > 0 {
+		return nil, fmt.Errorf("❌ database already has %d applied migration(s), a baseline is only for databases without migration history", len(applied))
+	}
+
+	exclude, err := migrator.ownTables(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	infof("🔍 Reading the live schema...\n")
+	snapshot, err := introspectSchema(ctx, migrator.db, exclude)
+	if err != nil {
+		return nil, fmt.Errorf("❌ failed to read schema: %w", err)
+	}
+	infof("📋 Found %d table(s), %d view(s), %d sequence(s), %d enum(s), %d function(s)\n",
+		len(snapshot.Tables), len(snapshot.Views), len(snapshot.Sequences), len(snapshot.Enums), len(snapshot.Functions))
+
+	up, down := renderSchemaDDL(snapshot)
+	if len(up) == 0 {
+		infof("⚠️  The database has no objects, the baseline migration will be empty\n")
+	}
+
+	header := fmt.Sprintf("-- Baseline of the existing schema, generated by migro on %s\n"+
+		"-- It was marked as applied without running; it only runs on new databases.",
+		time.Now().UTC().Format("2006-01-02 15:04:05 UTC"))
+	migration, err := writeMigrationFile(migrator.dir, "baseline", header, &Migration{Up: up, Down: down})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := migrator.markApplied(ctx, migration); err != nil {
+		return nil, fmt.Errorf("❌ wrote %s but failed to mark it as applied: %w", migration.Path, err)
+	}
+
+	infof("✅ Created baseline %s and marked version %d as applied\n", migration.Path, migration.Version)
+	return migration, nil
+}
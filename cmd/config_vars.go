@@ -0,0 +1,109 @@
+package migroCMD
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+)
+
+// ${VAR} or ${VAR:-default}
+var envReferenceRegex = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)(:-([^}]*))?\}`)
+
+// Replace ${VAR} and ${VAR:-default} references in config values with
+// environment variables. The YAML is parsed first and only scalar values
+// are expanded, so a value containing ':' or '#' or a newline stays one
+// value, and comments do not need their variables to be set.
+// @param content string
+// @return string, error
+func interpolateEnv(content string) (string, error) {
+	var document yaml.Node
+	if err := yaml.Unmarshal([]byte(content), &document); err != nil {
+		return "", err
+	}
+	if document.Kind == 0 {
+		return content, nil // empty file
+	}
+
+	var unset []string
+	expandEnvValues(&document, &unset)
+	if len(unset) > 0 {
+		return "", fmt.Errorf("config references unset environment variable(s): %s (set them or use ${VAR:-default})", strings.Join(unset, ", "))
+	}
+
+	expanded, err := yaml.Marshal(&document)
+	if err != nil {
+		return "", err
+	}
+	return string(expanded), nil
+}
+
+// Expand environment references in the scalar values under a YAML node.
+// Mapping keys are left as written.
+// @param node *yaml.Node
+// @param unset *[]string - collects referenced variables that are not set
+func expandEnvValues(node *yaml.Node, unset *[]string) {
+	switch node.Kind {
+	case yaml.DocumentNode, yaml.SequenceNode:
+		for _, child := range node.Content {
+			expandEnvValues(child, unset)
+		}
+	case yaml.MappingNode:
+		for i := 1; i < len(node.Content); i += 2 {
+			expandEnvValues(node.Content[i], unset)
+		}
+	case yaml.ScalarNode:
+		if !envReferenceRegex.MatchString(node.Value) {
+			return
+		}
+		node.Value = envReferenceRegex.ReplaceAllStringFunc(node.Value, func(reference string) string {
+			parts := envReferenceRegex.FindStringSubmatch(reference)
+			value, ok := os.LookupEnv(parts[1])
+			if ok && value != "" {
+				return value
+			}
+			if parts[2] != "" {
+				return parts[3]
+			}
+			if !ok {
+				*unset = append(*unset, parts[1])
+			}
+			return value
+		})
+		// The expanded value stays a string, so a password like 0x10 or null
+		// is not re-typed by YAML; viper decodes strings into int fields
+		node.Tag = "!!str"
+	}
+}
+
+// Read <KEY>_FILE settings into their config fields, e.g.
+// DATABASE_PASSWORD_FILE: /run/secrets/db fills DATABASE_PASSWORD with the
+// content of that file. A _FILE setting wins over the plain key.
+// @param config *CONFIG
+// @return error
+func applySecretFiles(config *CONFIG) error {
+	value := reflect.ValueOf(config).Elem()
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Type().Field(i)
+		key := field.Tag.Get("mapstructure")
+		if key == "" || field.Type.Kind() != reflect.String {
+			continue
+		}
+
+		path := viper.GetString(key + "_FILE")
+		if path == "" {
+			continue
+		}
+
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read %s_FILE: %w", key, err)
+		}
+		value.Field(i).SetString(strings.TrimRight(string(content), "\r\n"))
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package migroCMD
+
+import (
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestInterpolateEnv(t *testing.T) {
+	t.Setenv("MIGRO_TEST_PASSWORD", "p@ss: #word\nnext: line")
+	t.Setenv("MIGRO_TEST_PORT", "5433")
+	t.Setenv("MIGRO_TEST_EMPTY", "")
+	t.Setenv("MIGRO_TEST_HEX", "0x10")
+	t.Setenv("MIGRO_TEST_OCTAL", "017")
+	t.Setenv("MIGRO_TEST_NULL", "null")
+
+	tests := []struct {
+		name    string
+		content string
+		want    map[string]any
+		wantErr string
+	}{
+		{
+			name:    "plain reference stays a string",
+			content: "DATABASE_PORT: ${MIGRO_TEST_PORT}",
+			want:    map[string]any{"DATABASE_PORT": "5433"},
+		},
+		{
+			name:    "hex-looking value is not converted",
+			content: "DATABASE_PASSWORD: ${MIGRO_TEST_HEX}",
+			want:    map[string]any{"DATABASE_PASSWORD": "0x10"},
+		},
+		{
+			name:    "octal-looking value is not converted",
+			content: "DATABASE_PASSWORD: ${MIGRO_TEST_OCTAL}",
+			want:    map[string]any{"DATABASE_PASSWORD": "017"},
+		},
+		{
+			name:    "null value is not converted",
+			content: "DATABASE_PASSWORD: ${MIGRO_TEST_NULL}",
+			want:    map[string]any{"DATABASE_PASSWORD": "null"},
+		},
+		{
+			name:    "quoted reference stays a string",
+			content: `DATABASE_PORT: "${MIGRO_TEST_PORT}"`,
+			want:    map[string]any{"DATABASE_PORT": "5433"},
+		},
+		{
+			name:    "YAML syntax in a value stays one value",
+			content: "DATABASE_PASSWORD: ${MIGRO_TEST_PASSWORD}\nDATABASE_USER: app",
+			want:    map[string]any{"DATABASE_PASSWORD": "p@ss: #word\nnext: line", "DATABASE_USER": "app"},
+		},
+		{
+			name:    "default for unset and empty variables",
+			content: "A: ${MIGRO_TEST_UNSET:-fallback}\nB: ${MIGRO_TEST_EMPTY:-other}",
+			want:    map[string]any{"A": "fallback", "B": "other"},
+		},
+		{
+			name:    "reference inside a longer value",
+			content: "DATABASE_HOST: db-${MIGRO_TEST_PORT}.local",
+			want:    map[string]any{"DATABASE_HOST": "db-5433.local"},
+		},
+		{
+			name:    "comments are not expanded",
+			content: "# DATABASE_PASSWORD: ${MIGRO_TEST_UNSET}\nA: b # ${MIGRO_TEST_UNSET}",
+			want:    map[string]any{"A": "b"},
+		},
+		{
+			name:    "nested environments and lists",
+			content: "environments:\n  prod:\n    PORTS:\n      - ${MIGRO_TEST_PORT}",
+			want:    map[string]any{"environments": map[string]any{"prod": map[string]any{"PORTS": []any{"5433"}}}},
+		},
+		{
+			name:    "unset variable without default",
+			content: "A: ${MIGRO_TEST_UNSET}",
+			wantErr: "MIGRO_TEST_UNSET",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expanded, err := interpolateEnv(tt.content)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("interpolateEnv() error = %v, want it to mention %s", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("interpolateEnv() error = %v", err)
+			}
+
+			var got map[string]any
+			if err := yaml.Unmarshal([]byte(expanded), &got); err != nil {
+				t.Fatalf("expanded config is not valid YAML: %v\n%s", err, expanded)
+			}
+			gotYAML, _ := yaml.Marshal(got)
+			wantYAML, _ := yaml.Marshal(tt.want)
+			if string(gotYAML) != string(wantYAML) {
+				t.Errorf("interpolateEnv() =\n%s\nwant\n%s", gotYAML, wantYAML)
+			}
+		})
+	}
+}
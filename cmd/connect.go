@@ -3,89 +3,359 @@ package migroCMD
 import (
 	"context"
 	"fmt"
-	"log"
+	"net"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/spf13/viper"
 )
 
+const (
+	defaultMaxConns          = 20
+	defaultHealthCheckPeriod = 5 * time.Second
+	defaultConnectTimeout    = 10 * time.Second
+	initialRetryBackoff      = 500 * time.Millisecond
+	maxRetryBackoff          = 5 * time.Second
+)
+
 type CONFIG struct {
-	ENV                        string `mapstructure:"ENV"`
-	DATABASE_DRIVER            string `mapstructure:"DATABASE_DRIVER"`
-	DATABASE_HOST              string `mapstructure:"DATABASE_HOST"`
-	DATABASE_PORT              string `mapstructure:"DATABASE_PORT"`
-	DATABASE_USERNAME          string `mapstructure:"DATABASE_USERNAME"`
-	DATABASE_PASSWORD          string `mapstructure:"DATABASE_PASSWORD"`
-	DATABASE_NAME              string `mapstructure:"DATABASE_NAME"`
-	DATABASE_CONNECTION_STRING string `mapstructure:"DATABASE_CONNECTION_STRING"`
-	TIMEOUT_SECONDS            int    `mapstructure:"TIMEOUT_SECONDS"`
-	MIGRATION_DIR              string `mapstructure:"MIGRATION_DIR"`
-	QUERY_DIR                  string `mapstructure:"QUERY_DIR"`
-	SQLC_DIR                   string `mapstructure:"SQLC_DIR"`
+	ENV                                  string `mapstructure:"ENV"`
+	DATABASE_DRIVER                      string `mapstructure:"DATABASE_DRIVER"`
+	DATABASE_HOST                        string `mapstructure:"DATABASE_HOST"`
+	DATABASE_PORT                        string `mapstructure:"DATABASE_PORT"`
+	DATABASE_USERNAME                    string `mapstructure:"DATABASE_USERNAME"`
+	DATABASE_PASSWORD                    string `mapstructure:"DATABASE_PASSWORD"`
+	DATABASE_NAME                        string `mapstructure:"DATABASE_NAME"`
+	DATABASE_SSLMODE                     string `mapstructure:"DATABASE_SSLMODE"`
+	DATABASE_SSLROOTCERT                 string `mapstructure:"DATABASE_SSLROOTCERT"`
+	DATABASE_SSLCERT                     string `mapstructure:"DATABASE_SSLCERT"`
+	DATABASE_SSLKEY                      string `mapstructure:"DATABASE_SSLKEY"`
+	DATABASE_APPLICATION_NAME            string `mapstructure:"DATABASE_APPLICATION_NAME"`
+	DATABASE_URL                         string `mapstructure:"DATABASE_URL"`
+	DATABASE_CONNECTION_STRING           string `mapstructure:"DATABASE_CONNECTION_STRING"`
+	DATABASE_MAX_CONNS                   int    `mapstructure:"DATABASE_MAX_CONNS"`
+	DATABASE_MIN_CONNS                   int    `mapstructure:"DATABASE_MIN_CONNS"`
+	DATABASE_CONNECT_TIMEOUT_SECONDS     int    `mapstructure:"DATABASE_CONNECT_TIMEOUT_SECONDS"`
+	DATABASE_HEALTH_CHECK_PERIOD_SECONDS int    `mapstructure:"DATABASE_HEALTH_CHECK_PERIOD_SECONDS"`
+	DATABASE_WAIT_SECONDS                int    `mapstructure:"DATABASE_WAIT_SECONDS"`
+	TIMEOUT_SECONDS                      int    `mapstructure:"TIMEOUT_SECONDS"`
+	LOCK_TIMEOUT                         string `mapstructure:"LOCK_TIMEOUT"`
+	STATEMENT_TIMEOUT                    string `mapstructure:"STATEMENT_TIMEOUT"`
+	DEFAULT_SCHEMA                       string `mapstructure:"DEFAULT_SCHEMA"`
+	MIGRATION_DIR                        string `mapstructure:"MIGRATION_DIR"`
+	MIGRATION_TABLE                      string `mapstructure:"MIGRATION_TABLE"`
+	MIGRATION_LOCK_TIMEOUT_SECONDS       int    `mapstructure:"MIGRATION_LOCK_TIMEOUT_SECONDS"`
+	MIGRATION_ALLOW_OUT_OF_ORDER         bool   `mapstructure:"MIGRATION_ALLOW_OUT_OF_ORDER"`
+	QUERY_DIR                            string `mapstructure:"QUERY_DIR"`
+	SQLC_DIR                             string `mapstructure:"SQLC_DIR"`
+	OUTPUT_FORMAT                        string `mapstructure:"OUTPUT_FORMAT"`
 }
 
-func DBConnection(config *CONFIG) *pgxpool.Pool {
-	dbURL := config.DATABASE_CONNECTION_STRING
+// Create the context of a single command. TIMEOUT_SECONDS, when set, is the
+// deadline for every query and subprocess the command runs.
+// @param config *CONFIG
+// @return context.Context, context.CancelFunc
+func commandContext(config *CONFIG) (context.Context, context.CancelFunc) {
+	if config.TIMEOUT_SECONDS > 0 {
+		return context.WithTimeout(context.Background(), time.Duration(config.TIMEOUT_SECONDS)*time.Second)
+	}
+	return context.WithCancel(context.Background())
+}
 
-	poolConfig, err := pgxpool.ParseConfig(dbURL)
+// DBConnection opens a connection pool sized from the config and pings the
+// database. With DATABASE_WAIT_SECONDS > 0 it keeps retrying with backoff
+// until the database accepts connections or the wait time is over.
+// @param config *CONFIG
+// @return *pgxpool.Pool, error
+func DBConnection(config *CONFIG) (*pgxpool.Pool, error) {
+	poolConfig, err := pgxpool.ParseConfig(config.DATABASE_CONNECTION_STRING)
 	if err != nil {
-		log.Fatalf("Unable to parse connection string: %v\n", err)
+		return nil, fmt.Errorf("❌ unable to parse connection string: %w", err)
 	}
 
-	poolConfig.MaxConns = 20
+	poolConfig.MaxConns = defaultMaxConns
+	if config.DATABASE_MAX_CONNS > 0 {
+		// One connection holds the migration lock while others run queries
+		if config.DATABASE_MAX_CONNS < 2 {
+			return nil, fmt.Errorf("❌ DATABASE_MAX_CONNS must be at least 2, got %d", config.DATABASE_MAX_CONNS)
+		}
+		poolConfig.MaxConns = int32(config.DATABASE_MAX_CONNS)
+	}
 	poolConfig.MinConns = 1
-	poolConfig.HealthCheckPeriod = 5 * time.Second
+	if config.DATABASE_MIN_CONNS > 0 {
+		poolConfig.MinConns = int32(config.DATABASE_MIN_CONNS)
+	}
+	if poolConfig.MinConns > poolConfig.MaxConns {
+		return nil, fmt.Errorf("❌ DATABASE_MIN_CONNS (%d) is greater than DATABASE_MAX_CONNS (%d)", poolConfig.MinConns, poolConfig.MaxConns)
+	}
+	poolConfig.HealthCheckPeriod = defaultHealthCheckPeriod
+	if config.DATABASE_HEALTH_CHECK_PERIOD_SECONDS > 0 {
+		poolConfig.HealthCheckPeriod = time.Duration(config.DATABASE_HEALTH_CHECK_PERIOD_SECONDS) * time.Second
+	}
+	poolConfig.ConnConfig.ConnectTimeout = defaultConnectTimeout
+	if config.DATABASE_CONNECT_TIMEOUT_SECONDS > 0 {
+		poolConfig.ConnConfig.ConnectTimeout = time.Duration(config.DATABASE_CONNECT_TIMEOUT_SECONDS) * time.Second
+	}
 
 	// Create a new connection pool
 	pool, err := pgxpool.NewWithConfig(context.Background(), poolConfig)
 	if err != nil {
-		log.Fatalf("Unable to create connection pool: %v\n", err)
+		return nil, fmt.Errorf("❌ unable to create connection pool: %w", err)
 	}
 
-	return pool
+	wait := time.Duration(config.DATABASE_WAIT_SECONDS) * time.Second
+	if err := pingWithRetry(pool, poolConfig.ConnConfig.ConnectTimeout, wait); err != nil {
+		pool.Close()
+		return nil, fmt.Errorf("❌ cannot connect to database (%s): %w", describeDatabaseTarget(config), err)
+	}
+
+	return pool, nil
+}
+
+// WaitForDatabase blocks until the database accepts connections
+// @param config *CONFIG
+// @param wait time.Duration - give up after this long
+// @return error
+func WaitForDatabase(config *CONFIG, wait time.Duration) error {
+	infof("⏳ Waiting up to %s for %s...\n", wait, describeDatabaseTarget(config))
+
+	waitConfig := *config
+	waitConfig.DATABASE_WAIT_SECONDS = int(wait / time.Second)
+
+	pool, err := DBConnection(&waitConfig)
+	if err != nil {
+		return err
+	}
+	pool.Close()
+
+	infof("✅ Database is accepting connections\n")
+	return nil
 }
 
-func LoadConfig(configPath string) (*CONFIG, error) {
+// Ping the database, retrying with exponential backoff while wait lasts
+// @param pool *pgxpool.Pool
+// @param connectTimeout time.Duration - limit of a single attempt
+// @param wait time.Duration - 0 means a single attempt
+// @return error
+func pingWithRetry(pool *pgxpool.Pool, connectTimeout time.Duration, wait time.Duration) error {
+	deadline := time.Now().Add(wait)
+	backoff := initialRetryBackoff
+
+	for attempt := 1; ; attempt++ {
+		ctx, cancel := context.WithTimeout(context.Background(), connectTimeout)
+		err := pool.Ping(ctx)
+		cancel()
+		if err == nil {
+			return nil
+		}
+
+		if time.Now().Add(backoff).After(deadline) {
+			if attempt > 1 {
+				return fmt.Errorf("gave up after %d attempts in %s: %w", attempt, wait, err)
+			}
+			return err
+		}
+
+		fmt.Fprintf(os.Stderr, "⏳ Database not ready (attempt %d): %v, retrying in %s\n", attempt, err, backoff)
+		time.Sleep(backoff)
+		backoff = min(backoff*2, maxRetryBackoff)
+	}
+}
+
+// LoadConfig reads the config file and applies the selected environment.
+// ${VAR} and ${VAR:-default} references in values are expanded and
+// <KEY>_FILE settings are read from their files afterwards.
+// Top-level keys are shared defaults; keys under environments.<env>
+// override them. When env is empty, the ENV key picks the environment if
+// it has an entry under environments.
+// @param configPath string
+// @param env string - from --env or MIGRO_ENV
+// @return *CONFIG, error
+func LoadConfig(configPath, env string) (*CONFIG, error) {
 	var config CONFIG
 	viper.SetConfigFile(configPath)
 	viper.SetConfigType("yaml")
 	viper.AutomaticEnv()
 
-	err := viper.ReadInConfig()
+	content, err := os.ReadFile(configPath)
 	if err != nil {
 		return nil, err
 	}
+	expanded, err := interpolateEnv(string(content))
+	if err != nil {
+		return nil, err
+	}
+	if err := viper.ReadConfig(strings.NewReader(expanded)); err != nil {
+		return nil, err
+	}
+
+	if err := applyEnvironment(env); err != nil {
+		return nil, err
+	}
 
 	if err := viper.Unmarshal(&config); err != nil {
 		return nil, err
 	}
+	if err := applySecretFiles(&config); err != nil {
+		return nil, err
+	}
 	config.DATABASE_CONNECTION_STRING = buildConnectionString(&config)
+	if config.MIGRATION_TABLE == "" {
+		config.MIGRATION_TABLE = defaultMigrationTable
+	}
+	if config.DEFAULT_SCHEMA == "" {
+		config.DEFAULT_SCHEMA = defaultSchemaName
+	}
+	if config.OUTPUT_FORMAT == "" {
+		config.OUTPUT_FORMAT = OutputTable
+	}
 
 	return &config, nil
 }
 
-func buildConnectionString(config *CONFIG) string {
-	// Check for placeholder values that indicate incomplete configuration
+// PrintEnvironment prints the resolved environment and database target.
+// It goes to stderr so machine-readable output on stdout stays clean.
+// @param config *CONFIG
+func PrintEnvironment(config *CONFIG) {
+	env := config.ENV
+	if env == "" {
+		env = "default"
+	}
+	fmt.Fprintf(os.Stderr, "🌍 Environment: %s (%s)\n", env, describeDatabaseTarget(config))
+}
+
+// Describe the database a config points to, without the password
+// @param config *CONFIG
+// @return string
+func describeDatabaseTarget(config *CONFIG) string {
+	if config.DATABASE_URL != "" {
+		if u, err := url.Parse(config.DATABASE_URL); err == nil && u.Host != "" {
+			return "DATABASE_URL " + u.Redacted()
+		}
+		return "DATABASE_URL"
+	}
+	return fmt.Sprintf("%s@%s:%s/%s", config.DATABASE_USERNAME, config.DATABASE_HOST, config.DATABASE_PORT, config.DATABASE_NAME)
+}
+
+// Merge the overrides of the selected environment into the loaded config
+// @param env string - explicitly requested environment, may be empty
+// @return error
+func applyEnvironment(env string) error {
+	environments := viper.GetStringMap("environments")
+
+	explicit := env != ""
+	if !explicit {
+		env = viper.GetString("ENV")
+	}
+	if env == "" {
+		return nil
+	}
+
+	overrides, ok := environments[strings.ToLower(env)]
+	if !ok {
+		if !explicit {
+			return nil // ENV without a matching profile only labels the config
+		}
+		var names []string
+		for name := range environments {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		if len(names) == 0 {
+			return fmt.Errorf("environment '%s' not found: the config file has no environments section", env)
+		}
+		return fmt.Errorf("environment '%s' not found (available: %s)", env, strings.Join(names, ", "))
+	}
+
+	settings, ok := overrides.(map[string]interface{})
+	if !ok && overrides != nil {
+		return fmt.Errorf("environments.%s must be a map of config keys", env)
+	}
+	// Merged at config level so environment variables still win
+	if err := viper.MergeConfigMap(settings); err != nil {
+		return fmt.Errorf("failed to apply environment '%s': %w", env, err)
+	}
+	viper.Set("ENV", env)
+	return nil
+}
+
+// Find a placeholder value copied from migro.example.yaml
+// @param config *CONFIG
+// @return string - the placeholder, empty if none is left
+func findPlaceholderValue(config *CONFIG) string {
 	placeholders := []string{"your_username", "your_password", "your_database"}
 	for _, placeholder := range placeholders {
-		if config.DATABASE_USERNAME == placeholder || 
-		   config.DATABASE_PASSWORD == placeholder || 
-		   config.DATABASE_NAME == placeholder {
-			fmt.Printf("❌ Configuration Error: Found placeholder value '%s' in migro.yaml\n", placeholder)
-			fmt.Println("💡 Please update your migro.yaml file with actual database credentials:")
-			fmt.Printf("   DATABASE_USERNAME: <your_actual_username>\n")
-			fmt.Printf("   DATABASE_PASSWORD: <your_actual_password>\n") 
-			fmt.Printf("   DATABASE_NAME: <your_actual_database_name>\n")
-			return ""
+		if config.DATABASE_USERNAME == placeholder ||
+			config.DATABASE_PASSWORD == placeholder ||
+			config.DATABASE_NAME == placeholder {
+			return placeholder
 		}
 	}
-	
-	return fmt.Sprintf("%s://%s:%s@%s:%s/%s?sslmode=disable",
-		config.DATABASE_DRIVER,
-		config.DATABASE_USERNAME,
-		config.DATABASE_PASSWORD,
-		config.DATABASE_HOST,
-		config.DATABASE_PORT,
-		config.DATABASE_NAME)
+	return ""
+}
+
+// Build the connection URL from the DATABASE_* settings. DATABASE_URL, when
+// set, is used as-is. Credentials are percent-encoded so characters such
+// as '@' or '/' in a password do not corrupt the URL.
+// @param config *CONFIG
+// @return string
+func buildConnectionString(config *CONFIG) string {
+	if config.DATABASE_URL != "" {
+		return config.DATABASE_URL
+	}
+
+	// Check for placeholder values that indicate incomplete configuration
+	if placeholder := findPlaceholderValue(config); placeholder != "" {
+		fmt.Fprintf(os.Stderr, "❌ Configuration Error: Found placeholder value '%s' in migro.yaml\n", placeholder)
+		fmt.Fprintln(os.Stderr, "💡 Please update your migro.yaml file with actual database credentials:")
+		fmt.Fprintf(os.Stderr, "   DATABASE_USERNAME: <your_actual_username>\n")
+		fmt.Fprintf(os.Stderr, "   DATABASE_PASSWORD: <your_actual_password>\n")
+		fmt.Fprintf(os.Stderr, "   DATABASE_NAME: <your_actual_database_name>\n")
+		return ""
+	}
+
+	sslMode := config.DATABASE_SSLMODE
+	if sslMode == "" {
+		sslMode = "disable"
+	}
+	applicationName := config.DATABASE_APPLICATION_NAME
+	if applicationName == "" {
+		applicationName = "migro"
+	}
+
+	query := url.Values{}
+	query.Set("sslmode", sslMode)
+	query.Set("application_name", applicationName)
+	if config.DATABASE_SSLROOTCERT != "" {
+		query.Set("sslrootcert", config.DATABASE_SSLROOTCERT)
+	}
+	if config.DATABASE_SSLCERT != "" {
+		query.Set("sslcert", config.DATABASE_SSLCERT)
+	}
+	if config.DATABASE_SSLKEY != "" {
+		query.Set("sslkey", config.DATABASE_SSLKEY)
+	}
+
+	host := config.DATABASE_HOST
+	if config.DATABASE_PORT != "" {
+		host = net.JoinHostPort(host, config.DATABASE_PORT)
+	}
+
+	driver := config.DATABASE_DRIVER
+	if driver == "" {
+		driver = "postgres"
+	}
+
+	dsn := url.URL{
+		Scheme:   driver,
+		User:     url.UserPassword(config.DATABASE_USERNAME, config.DATABASE_PASSWORD),
+		Host:     host,
+		Path:     "/" + config.DATABASE_NAME,
+		RawQuery: query.Encode(),
+	}
+	return dsn.String()
 }
@@ -4,11 +4,10 @@ import (
 	"context"
 	"fmt"
 	"os"
-	"os/exec"
 	"path/filepath"
-	"sort"
 	"strings"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
@@ -17,54 +16,42 @@ import (
 // @param name string
 // @return error
 func CreateEmptyMigration(migrationDir, name string) error {
-	ctx := context.Background()
-
-	// Use goose create command to generate migration file
-	// Note: For create command, we don't need DRIVER and DBSTRING parameters
-	cmd := exec.CommandContext(ctx, "goose",
-		"-dir", migrationDir,
-		"create", name, "sql")
-
-	output, err := cmd.CombinedOutput()
+	createdFile, err := newMigrationFilePath(migrationDir, name)
 	if err != nil {
-		return fmt.Errorf("❌ failed to create migration: %w\nOutput: %s", err, string(output))
-	}
-
-	fmt.Printf("📝 Goose output: %s", string(output))
-
-	// Extract the created file name from goose output
-	createdFile := extractCreatedFileName(migrationDir, string(output))
-	if createdFile == "" {
-		fmt.Println("✅ Migration file created successfully!")
-		return nil
+		return fmt.Errorf("❌ failed to create migration: %w", err)
 	}
 
 	fmt.Printf("📁 Created file: %s\n", createdFile)
 
-	// Enhance the template with more detailed comments
+	// Write the template with detailed comments
 	err = enhanceMigrationTemplate(createdFile, name)
 	if err != nil {
-		fmt.Printf("⚠️  Warning: Could not enhance template: %v\n", err)
-		// Don't fail the whole operation for template enhancement
+		return fmt.Errorf("❌ failed to create migration: %w", err)
 	}
 
 	return nil
 }
 
-// Add Column to Table
+// Create Table
+// @param config: *CONFIG
 // @param db: *pgxpool.Pool
-// @param table: string
-// @param column: string
+// @param table: string (table or schema.table)
+// @param columns: string
 func CreateTable(config *CONFIG, db *pgxpool.Pool, table string, columns string) error {
+	tableName, err := resolveTableName(config, table)
+	if err != nil {
+		return err
+	}
+
 	// rename migration filename
-	migrationFilename := fmt.Sprintf("create_%s", table)
+	migrationFilename := fmt.Sprintf("create_%s", tableName.Label(config.DEFAULT_SCHEMA))
 	// check if any file with pattern 14-digit-number_table.sql exists
 	matches, err := filepath.Glob(fmt.Sprintf("%s/[0-9][0-9][0-9][0-9][0-9][0-9][0-9][0-9][0-9][0-9][0-9][0-9][0-9][0-9]_%s.sql", config.MIGRATION_DIR, migrationFilename))
 	if err != nil {
 		return fmt.Errorf("❌ error checking migration files: %w", err)
 	}
 	if len(matches) > 0 {
-		return fmt.Errorf("❌ migration file for %s already exists", table)
+		return fmt.Errorf("❌ migration file for %s already exists", tableName)
 	}
 
 	// validate column type
@@ -75,8 +62,8 @@ func CreateTable(config *CONFIG, db *pgxpool.Pool, table string, columns string)
 	// rename column type format
 	columns = renameColumnTypeEnhanceFormat(columns)
 
-	// Create migration file using goose and then modify it
-	err = createMigrationTableFile(config, migrationFilename, table, columns)
+	// Create migration file
+	err = createMigrationTableFile(config, migrationFilename, tableName, columns)
 	if err != nil {
 		return fmt.Errorf("❌ create migration failed: %w", err)
 	}
@@ -84,37 +71,13 @@ func CreateTable(config *CONFIG, db *pgxpool.Pool, table string, columns string)
 }
 
 // createMigrationTableFile creates a migration file with table creation SQL
-func createMigrationTableFile(config *CONFIG, migrationName, tableName, columns string) error {
-	ctx := context.Background()
-	migrationsDir := config.MIGRATION_DIR
-
-	// Create empty migration file first
-	cmd := exec.CommandContext(ctx, "goose", "-dir", migrationsDir, "create", migrationName, "sql")
-	output, err := cmd.CombinedOutput()
+func createMigrationTableFile(config *CONFIG, migrationName string, tableName TableName, columns string) error {
+	// Pick the path of the new migration file
+	fileName, err := newMigrationFilePath(config.MIGRATION_DIR, migrationName)
 	if err != nil {
-		return fmt.Errorf("failed to create migration: %w\nOutput: %s", err, string(output))
+		return fmt.Errorf("failed to create migration: %w", err)
 	}
 
-	// Find the latest migration file that was just created
-	pattern := filepath.Join(migrationsDir, "[0-9][0-9][0-9][0-9][0-9][0-9][0-9][0-9][0-9][0-9][0-9][0-9][0-9][0-9]_"+migrationName+".sql")
-	matches, err := filepath.Glob(pattern)
-	if err != nil {
-		return fmt.Errorf("error finding migration file: %w", err)
-	}
-
-	if len(matches) == 0 {
-		return fmt.Errorf("no migration file found after creation")
-	}
-
-	// Sort by modification time to get the latest file
-	sort.Slice(matches, func(i, j int) bool {
-		infoI, _ := os.Stat(matches[i])
-		infoJ, _ := os.Stat(matches[j])
-		return infoI.ModTime().After(infoJ.ModTime())
-	})
-
-	fileName := matches[0]
-
 	// Generate the SQL content
 	sqlContent, err := generateCreateTableSQL(tableName, columns)
 	if err != nil {
@@ -131,7 +94,7 @@ func createMigrationTableFile(config *CONFIG, migrationName, tableName, columns
 -- +goose StatementBegin
 DROP TABLE IF EXISTS %s;
 -- +goose StatementEnd
-`, sqlContent, tableName)
+`, sqlContent, tableName.Quoted())
 
 	err = os.WriteFile(fileName, []byte(content), 0644)
 	if err != nil {
@@ -143,15 +106,15 @@ DROP TABLE IF EXISTS %s;
 }
 
 // generateCreateTableSQL generates the CREATE TABLE SQL statement
-func generateCreateTableSQL(tableName, columns string) (string, error) {
+func generateCreateTableSQL(tableName TableName, columns string) (string, error) {
 	// Get singular name for primary key
-	singularName := getSingularName(tableName)
+	singularName := getSingularName(tableName.Name)
 	primaryKey := singularName + "_id"
 
 	var columnDefs []string
 
 	// Add primary key
-	columnDefs = append(columnDefs, fmt.Sprintf("    %s serial primary key", primaryKey))
+	columnDefs = append(columnDefs, fmt.Sprintf("    %s serial primary key", pgx.Identifier{primaryKey}.Sanitize()))
 
 	// Process column definitions
 	if strings.TrimSpace(columns) != "" {
@@ -176,7 +139,7 @@ func generateCreateTableSQL(tableName, columns string) (string, error) {
 	columnDefs = append(columnDefs, "    deleted_at timestamp")
 
 	// Build CREATE TABLE query
-	sql := fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s(\n%s\n);", tableName, strings.Join(columnDefs, ",\n"))
+	sql := fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s(\n%s\n);", tableName.Quoted(), strings.Join(columnDefs, ",\n"))
 	return sql, nil
 }
 
@@ -198,7 +161,7 @@ func parseColumnDefinition(column string) (string, error) {
 		return "", fmt.Errorf("invalid column format, expected name:type[:options...]")
 	}
 
-	columnName := strings.TrimSpace(parts[0])
+	columnName := quoteColumnName(parts[0])
 	columnType := strings.TrimSpace(parts[1])
 
 	var columnExtra strings.Builder
@@ -314,16 +277,22 @@ var columnTypeMap = map[string]string{
 // Add Column to Table
 // @param config: *CONFIG
 // @param db: *pgxpool.Pool
-// @param table: string
+// @param table: string (table or schema.table)
 // @param columns: string
 func AddColumn(config *CONFIG, db *pgxpool.Pool, table string, columns string) error {
+	tableName, err := resolveTableName(config, table)
+	if err != nil {
+		return err
+	}
+	table = tableName.Label(config.DEFAULT_SCHEMA)
+
 	// check table exists in migration files
-	exists, err := checkTableExistsInMigrations(config.MIGRATION_DIR, table)
+	exists, err := checkTableExistsInMigrations(config, tableName)
 	if err != nil {
 		return fmt.Errorf("❌ error checking table exists: %w", err)
 	}
 	if !exists {
-		return fmt.Errorf("❌ table '%s' does not exist in migration files", table)
+		return fmt.Errorf("❌ table '%s' does not exist in migration files", tableName)
 	}
 
 	// get column names
@@ -388,8 +357,8 @@ func AddColumn(config *CONFIG, db *pgxpool.Pool, table string, columns string) e
 	// rename column type format
 	columns = renameColumnTypeEnhanceFormat(columns)
 
-	// Create migration file using goose and then modify it
-	err = createMigrationAddColumnsFile(config, migrationFilename, table, columns)
+	// Create migration file
+	err = createMigrationAddColumnsFile(config, migrationFilename, tableName, columns)
 	if err != nil {
 		return fmt.Errorf("❌ create migration failed: %w", err)
 	}
@@ -397,54 +366,21 @@ func AddColumn(config *CONFIG, db *pgxpool.Pool, table string, columns string) e
 }
 
 // createMigrationAddColumnsFile creates a migration file with ALTER TABLE ADD COLUMN SQL
-func createMigrationAddColumnsFile(config *CONFIG, migrationName, tableName, columns string) error {
-	ctx := context.Background()
-	migrationsDir := config.MIGRATION_DIR
-
-	// Create empty migration file first
-	cmd := exec.CommandContext(ctx, "goose", "-dir", migrationsDir, "create", migrationName, "sql")
-	output, err := cmd.CombinedOutput()
+func createMigrationAddColumnsFile(config *CONFIG, migrationName string, tableName TableName, columns string) error {
+	// Pick the path of the new migration file
+	fileName, err := newMigrationFilePath(config.MIGRATION_DIR, migrationName)
 	if err != nil {
-		return fmt.Errorf("failed to create migration: %w\nOutput: %s", err, string(output))
+		return fmt.Errorf("failed to create migration: %w", err)
 	}
 
-	// Find the latest migration file that was just created
-	pattern := filepath.Join(migrationsDir, "[0-9][0-9][0-9][0-9][0-9][0-9][0-9][0-9][0-9][0-9][0-9][0-9][0-9][0-9]_"+migrationName+".sql")
-	matches, err := filepath.Glob(pattern)
-	if err != nil {
-		return fmt.Errorf("error finding migration file: %w", err)
-	}
-
-	if len(matches) == 0 {
-		return fmt.Errorf("no migration file found after creation")
-	}
-
-	// Sort by modification time to get the latest file
-	sort.Slice(matches, func(i, j int) bool {
-		infoI, _ := os.Stat(matches[i])
-		infoJ, _ := os.Stat(matches[j])
-		return infoI.ModTime().After(infoJ.ModTime())
-	})
-
-	fileName := matches[0]
-
 	// Generate the SQL content
-	upSQL, downSQL, err := generateAddColumnsSQL(tableName, columns)
+	up, down, err := generateAddColumnsSQL(tableName, columns)
 	if err != nil {
 		return fmt.Errorf("error generating SQL: %w", err)
 	}
 
-	// Write the SQL content to the file
-	content := fmt.Sprintf(`-- +goose Up
--- +goose StatementBegin
-%s
--- +goose StatementEnd
-
--- +goose Down
--- +goose StatementBegin
-%s
--- +goose StatementEnd
-`, upSQL, downSQL)
+	// One statement per block, so each runs as its own query
+	content := renderMigrationSQL("", &Migration{Up: up, Down: down})
 
 	err = os.WriteFile(fileName, []byte(content), 0644)
 	if err != nil {
@@ -456,7 +392,7 @@ func createMigrationAddColumnsFile(config *CONFIG, migrationName, tableName, col
 }
 
 // generateAddColumnsSQL generates ALTER TABLE ADD COLUMN and DROP COLUMN SQL statements
-func generateAddColumnsSQL(tableName, columns string) (string, string, error) {
+func generateAddColumnsSQL(tableName TableName, columns string) ([]string, []string, error) {
 	var upStatements []string
 	var downStatements []string
 
@@ -470,21 +406,21 @@ func generateAddColumnsSQL(tableName, columns string) (string, string, error) {
 
 		columnDef, err := parseColumnDefinitionForAlter(column)
 		if err != nil {
-			return "", "", fmt.Errorf("error parsing column '%s': %w", column, err)
+			return nil, nil, fmt.Errorf("error parsing column '%s': %w", column, err)
 		}
 
 		// Extract column name for DROP statement
 		parts := strings.Split(column, ":")
 		if len(parts) < 1 {
-			return "", "", fmt.Errorf("invalid column format: %s", column)
+			return nil, nil, fmt.Errorf("invalid column format: %s", column)
 		}
-		columnName := strings.TrimSpace(parts[0])
+		columnName := quoteColumnName(parts[0])
 
-		upStatements = append(upStatements, fmt.Sprintf("ALTER TABLE %s ADD COLUMN IF NOT EXISTS %s;", tableName, columnDef))
-		downStatements = append(downStatements, fmt.Sprintf("ALTER TABLE %s DROP COLUMN IF EXISTS %s;", tableName, columnName))
+		upStatements = append(upStatements, fmt.Sprintf("ALTER TABLE %s ADD COLUMN IF NOT EXISTS %s;", tableName.Quoted(), columnDef))
+		downStatements = append(downStatements, fmt.Sprintf("ALTER TABLE %s DROP COLUMN IF EXISTS %s;", tableName.Quoted(), columnName))
 	}
 
-	return strings.Join(upStatements, "\n"), strings.Join(downStatements, "\n"), nil
+	return upStatements, downStatements, nil
 }
 
 // parseColumnDefinitionForAlter parses a column definition for ALTER TABLE ADD COLUMN
@@ -494,7 +430,7 @@ func parseColumnDefinitionForAlter(column string) (string, error) {
 		return "", fmt.Errorf("invalid column format, expected name:type[:options...]")
 	}
 
-	columnName := strings.TrimSpace(parts[0])
+	columnName := quoteColumnName(parts[0])
 	columnType := strings.TrimSpace(parts[1])
 
 	var columnExtra strings.Builder
@@ -568,8 +504,7 @@ func formatDefaultValueForAlter(defaultVal, columnType string, isArray bool) (st
 }
 
 // getColumnDefinition gets the full definition of a column for rollback purposes
-func getColumnDefinition(db *pgxpool.Pool, tableName, columnName string) (string, error) {
-	ctx := context.Background()
+func getColumnDefinition(ctx context.Context, db *pgxpool.Pool, tableName TableName, columnName string) (string, error) {
 	query := `
 		SELECT 
 			column_name,
@@ -578,20 +513,20 @@ func getColumnDefinition(db *pgxpool.Pool, tableName, columnName string) (string
 			is_nullable,
 			column_default
 		FROM information_schema.columns 
-		WHERE table_name = $1 AND column_name = $2
+		WHERE table_schema = $1 AND table_name = $2 AND column_name = $3
 	`
 
 	var colName, dataType string
 	var maxLength *int
 	var isNullable, columnDefault *string
 
-	err := db.QueryRow(ctx, query, tableName, columnName).Scan(&colName, &dataType, &maxLength, &isNullable, &columnDefault)
+	err := db.QueryRow(ctx, query, tableName.Schema, tableName.Name, columnName).Scan(&colName, &dataType, &maxLength, &isNullable, &columnDefault)
 	if err != nil {
 		return "", fmt.Errorf("error getting column definition: %w", err)
 	}
 
 	// Build column definition string
-	var definition string = fmt.Sprintf("%s %s", colName, strings.ToUpper(dataType))
+	var definition string = fmt.Sprintf("%s %s", pgx.Identifier{colName}.Sanitize(), strings.ToUpper(dataType))
 
 	// Add length for varchar types
 	if maxLength != nil && (dataType == "character varying" || dataType == "varchar") {
@@ -614,16 +549,22 @@ func getColumnDefinition(db *pgxpool.Pool, tableName, columnName string) (string
 // Delete Column from Table
 // @param config: *CONFIG
 // @param db: *pgxpool.Pool
-// @param table: string
+// @param table: string (table or schema.table)
 // @param columns: string
 func DeleteColumn(config *CONFIG, db *pgxpool.Pool, table string, columns string) error {
+	tableName, err := resolveTableName(config, table)
+	if err != nil {
+		return err
+	}
+	table = tableName.Label(config.DEFAULT_SCHEMA)
+
 	// check table exists in migration files
-	exists, err := checkTableExistsInMigrations(config.MIGRATION_DIR, table)
+	exists, err := checkTableExistsInMigrations(config, tableName)
 	if err != nil {
 		return fmt.Errorf("❌ error checking table exists: %w", err)
 	}
 	if !exists {
-		return fmt.Errorf("❌ table '%s' does not exist in migration files", table)
+		return fmt.Errorf("❌ table '%s' does not exist in migration files", tableName)
 	}
 
 	// get column names
@@ -633,12 +574,12 @@ func DeleteColumn(config *CONFIG, db *pgxpool.Pool, table string, columns string
 	if len(columnNames) == 1 {
 		columnName := strings.TrimSpace(strings.Split(columnNames[0], ":")[0])
 		// check column exists in migration files
-		exists, err := checkColumnExistsInMigrations(config.MIGRATION_DIR, table, columnName)
+		exists, err := checkColumnExistsInMigrations(config, tableName, columnName)
 		if err != nil {
 			return fmt.Errorf("❌ error checking column exists: %w", err)
 		}
 		if !exists {
-			return fmt.Errorf("❌ column '%s' does not exist in table '%s' (checked from migration files)", columnName, table)
+			return fmt.Errorf("❌ column '%s' does not exist in table '%s' (checked from migration files)", columnName, tableName)
 		}
 		migrationFilename = fmt.Sprintf("delete_column_%s_from_%s", columnName, table)
 	} else {
@@ -649,12 +590,12 @@ func DeleteColumn(config *CONFIG, db *pgxpool.Pool, table string, columns string
 			colName := strings.TrimSpace(strings.Split(col, ":")[0])
 			if colName != "" {
 				// check column exists in migration files
-				exists, err := checkColumnExistsInMigrations(config.MIGRATION_DIR, table, colName)
+				exists, err := checkColumnExistsInMigrations(config, tableName, colName)
 				if err != nil {
 					return fmt.Errorf("❌ error checking column exists: %w", err)
 				}
 				if !exists {
-					return fmt.Errorf("❌ column '%s' does not exist in table '%s' (checked from migration files)", colName, table)
+					return fmt.Errorf("❌ column '%s' does not exist in table '%s' (checked from migration files)", colName, tableName)
 				}
 				columnNamesForFile = append(columnNamesForFile, colName)
 			}
@@ -694,8 +635,8 @@ func DeleteColumn(config *CONFIG, db *pgxpool.Pool, table string, columns string
 		return fmt.Errorf("❌ migration file for %s already exists", migrationFilename)
 	}
 
-	// Create migration file using goose and then modify it
-	err = createMigrationDeleteColumnsFile(config, migrationFilename, table, columns)
+	// Create migration file
+	err = createMigrationDeleteColumnsFile(config, migrationFilename, tableName, columns)
 	if err != nil {
 		return fmt.Errorf("❌ create migration failed: %w", err)
 	}
@@ -703,54 +644,21 @@ func DeleteColumn(config *CONFIG, db *pgxpool.Pool, table string, columns string
 }
 
 // createMigrationDeleteColumnsFile creates a migration file with ALTER TABLE DROP COLUMN SQL
-func createMigrationDeleteColumnsFile(config *CONFIG, migrationName, tableName, columns string) error {
-	ctx := context.Background()
-	migrationsDir := config.MIGRATION_DIR
-
-	// Create empty migration file first
-	cmd := exec.CommandContext(ctx, "goose", "-dir", migrationsDir, "create", migrationName, "sql")
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		return fmt.Errorf("failed to create migration: %w\nOutput: %s", err, string(output))
-	}
-
-	// Find the latest migration file that was just created
-	pattern := filepath.Join(migrationsDir, "[0-9][0-9][0-9][0-9][0-9][0-9][0-9][0-9][0-9][0-9][0-9][0-9][0-9][0-9]_"+migrationName+".sql")
-	matches, err := filepath.Glob(pattern)
+func createMigrationDeleteColumnsFile(config *CONFIG, migrationName string, tableName TableName, columns string) error {
+	// Pick the path of the new migration file
+	fileName, err := newMigrationFilePath(config.MIGRATION_DIR, migrationName)
 	if err != nil {
-		return fmt.Errorf("error finding migration file: %w", err)
-	}
-
-	if len(matches) == 0 {
-		return fmt.Errorf("no migration file found after creation")
+		return fmt.Errorf("failed to create migration: %w", err)
 	}
 
-	// Sort by modification time to get the latest file
-	sort.Slice(matches, func(i, j int) bool {
-		infoI, _ := os.Stat(matches[i])
-		infoJ, _ := os.Stat(matches[j])
-		return infoI.ModTime().After(infoJ.ModTime())
-	})
-
-	fileName := matches[0]
-
 	// Generate the SQL content
-	upSQL, downSQL, err := generateDeleteColumnsSQL(tableName, columns)
+	up, down, err := generateDeleteColumnsSQL(tableName, columns)
 	if err != nil {
 		return fmt.Errorf("error generating SQL: %w", err)
 	}
 
-	// Write the SQL content to the file
-	content := fmt.Sprintf(`-- +goose Up
--- +goose StatementBegin
-%s
--- +goose StatementEnd
-
--- +goose Down
--- +goose StatementBegin
-%s
--- +goose StatementEnd
-`, upSQL, downSQL)
+	// One statement per block, so each runs as its own query
+	content := renderMigrationSQL("", &Migration{Up: up, Down: down})
 
 	err = os.WriteFile(fileName, []byte(content), 0644)
 	if err != nil {
@@ -762,7 +670,7 @@ func createMigrationDeleteColumnsFile(config *CONFIG, migrationName, tableName,
 }
 
 // generateDeleteColumnsSQL generates ALTER TABLE DROP COLUMN and ADD COLUMN SQL statements
-func generateDeleteColumnsSQL(tableName, columns string) (string, string, error) {
+func generateDeleteColumnsSQL(tableName TableName, columns string) ([]string, []string, error) {
 	var upStatements []string
 	var downStatements []string
 
@@ -773,14 +681,15 @@ func generateDeleteColumnsSQL(tableName, columns string) (string, string, error)
 		if columnName == "" {
 			continue
 		}
+		columnName = quoteColumnName(columnName)
 
-		upStatements = append(upStatements, fmt.Sprintf("ALTER TABLE %s DROP COLUMN IF EXISTS %s;", tableName, columnName))
+		upStatements = append(upStatements, fmt.Sprintf("ALTER TABLE %s DROP COLUMN IF EXISTS %s;", tableName.Quoted(), columnName))
 
 		// For rollback, create a basic column definition
 		// Note: This creates a generic TEXT column for rollback
 		// Users should manually adjust the column type if needed after rollback
-		downStatements = append(downStatements, fmt.Sprintf("-- TODO: Adjust column type as needed\nALTER TABLE %s ADD COLUMN IF NOT EXISTS %s TEXT;", tableName, columnName))
+		downStatements = append(downStatements, fmt.Sprintf("-- TODO: Adjust column type as needed\nALTER TABLE %s ADD COLUMN IF NOT EXISTS %s TEXT;", tableName.Quoted(), columnName))
 	}
 
-	return strings.Join(upStatements, "\n"), strings.Join(downStatements, "\n"), nil
+	return upStatements, downStatements, nil
 }
@@ -1,31 +1,35 @@
 package migroCMD
 
 import (
-	"context"
 	"fmt"
 	"strings"
 	"time"
 
-	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 // Insert data into table
 // @param config *CONFIG
 // @param db *pgxpool.Pool
-// @param table string
+// @param table string (table or schema.table)
 // @param data string (format: "column1=value1,column2=value2")
 // @return error
 func InsertData(config *CONFIG, db *pgxpool.Pool, table string, data string) error {
-	ctx := context.Background()
+	ctx, cancel := commandContext(config)
+	defer cancel()
+
+	tableName, err := resolveTableName(config, table)
+	if err != nil {
+		return err
+	}
 
 	// check table exists in migration files
-	exists, err := checkTableExistsInMigrations(config.MIGRATION_DIR, table)
+	exists, err := checkTableExistsInMigrations(config, tableName)
 	if err != nil {
 		return fmt.Errorf("❌ error checking table exists: %w", err)
 	}
 	if !exists {
-		return fmt.Errorf("❌ table '%s' does not exist in migration files", table)
+		return fmt.Errorf("❌ table '%s' does not exist in migration files", tableName)
 	}
 
 	// Parse data
@@ -37,25 +41,27 @@ func InsertData(config *CONFIG, db *pgxpool.Pool, table string, data string) err
 	// Build INSERT query
 	query := fmt.Sprintf(
 		"INSERT INTO %s (%s) VALUES (%s) RETURNING *",
-		table,
-		strings.Join(columns, ", "),
+		tableName.Quoted(),
+		strings.Join(quoteColumnNames(columns), ", "),
 		buildPlaceholders(len(values)),
 	)
 
-	fmt.Printf("🔄 Executing: %s\n", query)
-	fmt.Printf("📝 Values: %v\n", values)
+	infof("🔄 Executing: %s\n", query)
+	infof("📝 Values: %v\n", values)
 
 	// Execute query
 	rows, err := db.Query(ctx, query, values...)
 	if err != nil {
 		return fmt.Errorf("❌ insert failed: %w", err)
 	}
-	defer rows.Close()
+	columns, records, err := collectRows(rows)
+	if err != nil {
+		return fmt.Errorf("❌ insert failed: %w", err)
+	}
 
 	// Print result
-	fmt.Println("✅ Insert successful!")
-	err = printQueryResults(rows)
-	if err != nil {
+	infof("✅ Insert successful!\n")
+	if err := printQueryResults(config.OUTPUT_FORMAT, columns, records); err != nil {
 		return fmt.Errorf("❌ error printing results: %w", err)
 	}
 
@@ -65,20 +71,26 @@ func InsertData(config *CONFIG, db *pgxpool.Pool, table string, data string) err
 // Update data in table
 // @param config *CONFIG
 // @param db *pgxpool.Pool
-// @param table string
+// @param table string (table or schema.table)
 // @param data string (format: "column1=value1,column2=value2")
 // @param where string (format: "id=1" or "name='test'")
 // @return error
 func UpdateData(config *CONFIG, db *pgxpool.Pool, table string, data string, where string) error {
-	ctx := context.Background()
+	ctx, cancel := commandContext(config)
+	defer cancel()
+
+	tableName, err := resolveTableName(config, table)
+	if err != nil {
+		return err
+	}
 
 	// check table exists in migration files
-	exists, err := checkTableExistsInMigrations(config.MIGRATION_DIR, table)
+	exists, err := checkTableExistsInMigrations(config, tableName)
 	if err != nil {
 		return fmt.Errorf("❌ error checking table exists: %w", err)
 	}
 	if !exists {
-		return fmt.Errorf("❌ table '%s' does not exist in migration files", table)
+		return fmt.Errorf("❌ table '%s' does not exist in migration files", tableName)
 	}
 
 	// Parse data
@@ -103,25 +115,27 @@ func UpdateData(config *CONFIG, db *pgxpool.Pool, table string, data string, whe
 	// Build UPDATE query
 	query := fmt.Sprintf(
 		"UPDATE %s SET %s WHERE %s RETURNING *",
-		table,
+		tableName.Quoted(),
 		strings.Join(setClauses, ", "),
 		whereClause,
 	)
 
-	fmt.Printf("🔄 Executing: %s\n", query)
-	fmt.Printf("📝 Values: %v\n", allValues)
+	infof("🔄 Executing: %s\n", query)
+	infof("📝 Values: %v\n", allValues)
 
 	// Execute query
 	rows, err := db.Query(ctx, query, allValues...)
 	if err != nil {
 		return fmt.Errorf("❌ update failed: %w", err)
 	}
-	defer rows.Close()
+	columns, records, err := collectRows(rows)
+	if err != nil {
+		return fmt.Errorf("❌ update failed: %w", err)
+	}
 
 	// Print result
-	fmt.Println("✅ Update successful!")
-	err = printQueryResults(rows)
-	if err != nil {
+	infof("✅ Update successful!\n")
+	if err := printQueryResults(config.OUTPUT_FORMAT, columns, records); err != nil {
 		return fmt.Errorf("❌ error printing results: %w", err)
 	}
 
@@ -131,26 +145,30 @@ func UpdateData(config *CONFIG, db *pgxpool.Pool, table string, data string, whe
 // Select one record from table
 // @param config *CONFIG
 // @param db *pgxpool.Pool
-// @param table string
+// @param table string (table or schema.table)
 // @param columns string (optional, default: "*")
 // @param where string (format: "id=1")
 // @return error
 func SelectOne(config *CONFIG, db *pgxpool.Pool, table string, columns string, where string) error {
-	ctx := context.Background()
+	ctx, cancel := commandContext(config)
+	defer cancel()
+
+	tableName, err := resolveTableName(config, table)
+	if err != nil {
+		return err
+	}
 
 	// check table exists in migration files
-	exists, err := checkTableExistsInMigrations(config.MIGRATION_DIR, table)
+	exists, err := checkTableExistsInMigrations(config, tableName)
 	if err != nil {
 		return fmt.Errorf("❌ error checking table exists: %w", err)
 	}
 	if !exists {
-		return fmt.Errorf("❌ table '%s' does not exist in migration files", table)
+		return fmt.Errorf("❌ table '%s' does not exist in migration files", tableName)
 	}
 
 	// Default columns
-	if columns == "" {
-		columns = "*"
-	}
+	columns = selectColumnList(columns)
 
 	// Parse WHERE clause
 	whereClause, values, err := parseWhereClause(where, 0)
@@ -162,30 +180,30 @@ func SelectOne(config *CONFIG, db *pgxpool.Pool, table string, columns string, w
 	query := fmt.Sprintf(
 		"SELECT %s FROM %s WHERE %s AND deleted_at IS NULL LIMIT 1",
 		columns,
-		table,
+		tableName.Quoted(),
 		whereClause,
 	)
 
-	fmt.Printf("🔄 Executing: %s\n", query)
-	fmt.Printf("📝 Values: %v\n", values)
+	infof("🔄 Executing: %s\n", query)
+	infof("📝 Values: %v\n", values)
 
 	// Execute query
 	rows, err := db.Query(ctx, query, values...)
 	if err != nil {
 		return fmt.Errorf("❌ select failed: %w", err)
 	}
-	defer rows.Close()
-
-	// Check if any results
-	if !rows.Next() {
-		fmt.Println("📭 No records found")
-		return nil
+	resultColumns, records, err := collectRows(rows)
+	if err != nil {
+		return fmt.Errorf("❌ select failed: %w", err)
 	}
 
 	// Print result
-	fmt.Println("✅ Record found!")
-	err = printQueryResults(rows)
-	if err != nil {
+	if len(records) == 0 {
+		infof("📭 No records found\n")
+	} else {
+		infof("✅ Record found!\n")
+	}
+	if err := printQueryResults(config.OUTPUT_FORMAT, resultColumns, records); err != nil {
 		return fmt.Errorf("❌ error printing results: %w", err)
 	}
 
@@ -195,34 +213,38 @@ func SelectOne(config *CONFIG, db *pgxpool.Pool, table string, columns string, w
 // Select many records from table
 // @param config *CONFIG
 // @param db *pgxpool.Pool
-// @param table string
+// @param table string (table or schema.table)
 // @param columns string (optional, default: "*")
 // @param where string (optional, format: "status='active'")
 // @param limit int (optional, default: 100)
 // @return error
 func SelectMany(config *CONFIG, db *pgxpool.Pool, table string, columns string, where string, limit int) error {
-	ctx := context.Background()
+	ctx, cancel := commandContext(config)
+	defer cancel()
+
+	tableName, err := resolveTableName(config, table)
+	if err != nil {
+		return err
+	}
 
 	// check table exists in migration files
-	exists, err := checkTableExistsInMigrations(config.MIGRATION_DIR, table)
+	exists, err := checkTableExistsInMigrations(config, tableName)
 	if err != nil {
 		return fmt.Errorf("❌ error checking table exists: %w", err)
 	}
 	if !exists {
-		return fmt.Errorf("❌ table '%s' does not exist in migration files", table)
+		return fmt.Errorf("❌ table '%s' does not exist in migration files", tableName)
 	}
 
 	// Default columns
-	if columns == "" {
-		columns = "*"
-	}
+	columns = selectColumnList(columns)
 
 	// Default limit
 	if limit <= 0 {
 		limit = 100
 	}
 
-	var query string
+	var filter string
 	var values []interface{}
 
 	// Build query with or without WHERE
@@ -232,26 +254,15 @@ func SelectMany(config *CONFIG, db *pgxpool.Pool, table string, columns string,
 			return fmt.Errorf("❌ error parsing where clause: %w", err)
 		}
 		values = whereValues
-
-		query = fmt.Sprintf(
-			"SELECT %s FROM %s WHERE %s AND deleted_at IS NULL ORDER BY created_at DESC LIMIT %d",
-			columns,
-			table,
-			whereClause,
-			limit,
-		)
+		filter = fmt.Sprintf("FROM %s WHERE %s AND deleted_at IS NULL", tableName.Quoted(), whereClause)
 	} else {
-		query = fmt.Sprintf(
-			"SELECT %s FROM %s WHERE deleted_at IS NULL ORDER BY created_at DESC LIMIT %d",
-			columns,
-			table,
-			limit,
-		)
+		filter = fmt.Sprintf("FROM %s WHERE deleted_at IS NULL", tableName.Quoted())
 	}
+	query := fmt.Sprintf("SELECT %s %s ORDER BY created_at DESC LIMIT %d", columns, filter, limit)
 
-	fmt.Printf("🔄 Executing: %s\n", query)
+	infof("🔄 Executing: %s\n", query)
 	if len(values) > 0 {
-		fmt.Printf("📝 Values: %v\n", values)
+		infof("📝 Values: %v\n", values)
 	}
 
 	// Execute query
@@ -259,24 +270,22 @@ func SelectMany(config *CONFIG, db *pgxpool.Pool, table string, columns string,
 	if err != nil {
 		return fmt.Errorf("❌ select failed: %w", err)
 	}
-	defer rows.Close()
+	resultColumns, records, err := collectRows(rows)
+	if err != nil {
+		return fmt.Errorf("❌ select failed: %w", err)
+	}
 
 	// Count results
 	count := 0
-	fmt.Println("✅ Records found:")
-	err = printQueryResults(rows)
-	if err != nil {
+	infof("✅ Records found:\n")
+	if err := printQueryResults(config.OUTPUT_FORMAT, resultColumns, records); err != nil {
 		return fmt.Errorf("❌ error printing results: %w", err)
 	}
 
 	// Count rows (approximate)
-	rows.Close()
-	countQuery := strings.Replace(query, fmt.Sprintf("SELECT %s FROM", columns), "SELECT COUNT(*) FROM", 1)
-	countQuery = strings.Replace(countQuery, fmt.Sprintf("ORDER BY created_at DESC LIMIT %d", limit), "", 1)
-
-	err = db.QueryRow(ctx, countQuery, values...).Scan(&count)
+	err = db.QueryRow(ctx, "SELECT COUNT(*) "+filter, values...).Scan(&count)
 	if err == nil {
-		fmt.Printf("\n📊 Total records: %d (showing max %d)\n", count, limit)
+		infof("📊 Total records: %d (showing max %d)\n", count, limit)
 	}
 
 	return nil
@@ -285,19 +294,25 @@ func SelectMany(config *CONFIG, db *pgxpool.Pool, table string, columns string,
 // Soft delete record (update deleted_at)
 // @param config *CONFIG
 // @param db *pgxpool.Pool
-// @param table string
+// @param table string (table or schema.table)
 // @param where string (format: "id=1")
 // @return error
 func SoftDelete(config *CONFIG, db *pgxpool.Pool, table string, where string) error {
-	ctx := context.Background()
+	ctx, cancel := commandContext(config)
+	defer cancel()
+
+	tableName, err := resolveTableName(config, table)
+	if err != nil {
+		return err
+	}
 
 	// check table exists in migration files
-	exists, err := checkTableExistsInMigrations(config.MIGRATION_DIR, table)
+	exists, err := checkTableExistsInMigrations(config, tableName)
 	if err != nil {
 		return fmt.Errorf("❌ error checking table exists: %w", err)
 	}
 	if !exists {
-		return fmt.Errorf("❌ table '%s' does not exist in migration files", table)
+		return fmt.Errorf("❌ table '%s' does not exist in migration files", tableName)
 	}
 
 	// Parse WHERE clause
@@ -313,33 +328,33 @@ func SoftDelete(config *CONFIG, db *pgxpool.Pool, table string, where string) er
 	// Build UPDATE query for soft delete
 	query := fmt.Sprintf(
 		"UPDATE %s SET deleted_at = $%d, updated_at = $%d WHERE %s AND deleted_at IS NULL RETURNING *",
-		table,
+		tableName.Quoted(),
 		len(values),
 		len(values)+1,
 		whereClause,
 	)
 	values = append(values, time.Now())
 
-	fmt.Printf("🔄 Executing soft delete: %s\n", query)
-	fmt.Printf("📝 Values: %v\n", values)
+	infof("🔄 Executing soft delete: %s\n", query)
+	infof("📝 Values: %v\n", values)
 
 	// Execute query
 	rows, err := db.Query(ctx, query, values...)
 	if err != nil {
 		return fmt.Errorf("❌ soft delete failed: %w", err)
 	}
-	defer rows.Close()
+	columns, records, err := collectRows(rows)
+	if err != nil {
+		return fmt.Errorf("❌ soft delete failed: %w", err)
+	}
 
 	// Check if any rows affected
-	if !rows.Next() {
-		fmt.Println("📭 No records found to delete (may already be deleted)")
-		return nil
+	if len(records) == 0 {
+		infof("📭 No records found to delete (may already be deleted)\n")
+	} else {
+		infof("✅ Soft delete successful!\n")
 	}
-
-	// Print result
-	fmt.Println("✅ Soft delete successful!")
-	err = printQueryResults(rows)
-	if err != nil {
+	if err := printQueryResults(config.OUTPUT_FORMAT, columns, records); err != nil {
 		return fmt.Errorf("❌ error printing results: %w", err)
 	}
 
@@ -392,7 +407,7 @@ func parseUpdateData(data string) ([]string, []interface{}, error) {
 
 	var setClauses []string
 	for i, column := range columns {
-		setClauses = append(setClauses, fmt.Sprintf("%s = $%d", column, i+1))
+		setClauses = append(setClauses, fmt.Sprintf("%s = $%d", quoteColumnName(column), i+1))
 	}
 
 	return setClauses, values, nil
@@ -421,12 +436,43 @@ func parseWhereClause(where string, startIndex int) (string, []interface{}, erro
 		value = strings.Trim(value, "'")
 	}
 
-	whereClause := fmt.Sprintf("%s = $%d", column, startIndex+1)
+	whereClause := fmt.Sprintf("%s = $%d", quoteColumnName(column), startIndex+1)
 	values := []interface{}{value}
 
 	return whereClause, values, nil
 }
 
+// quoteColumnNames quotes column names given on the command line
+// @param columns []string
+// @return []string
+func quoteColumnNames(columns []string) []string {
+	quoted := make([]string, len(columns))
+	for i, column := range columns {
+		quoted[i] = quoteColumnName(column)
+	}
+	return quoted
+}
+
+// selectColumnList quotes the plain column names of a --columns list and
+// keeps "*" and expressions such as count(*) as they are
+// @param columns string (format: "id,name" or "*")
+// @return string
+func selectColumnList(columns string) string {
+	if strings.TrimSpace(columns) == "" {
+		return "*"
+	}
+
+	var list []string
+	for _, column := range strings.Split(columns, ",") {
+		column = strings.TrimSpace(column)
+		if tokens, err := tokenizeSQL(column); err == nil && len(tokens) == 1 && tokens[0].isIdentifier() {
+			column = quoteColumnName(column)
+		}
+		list = append(list, column)
+	}
+	return strings.Join(list, ", ")
+}
+
 // buildPlaceholders builds PostgreSQL placeholders ($1, $2, ...)
 // @param count int
 // @return string
@@ -438,100 +484,23 @@ func buildPlaceholders(count int) string {
 	return strings.Join(placeholders, ", ")
 }
 
-// printQueryResults prints query results in a formatted table
-// @param rows pgx.Rows
+// printQueryResults prints query results in the configured output format.
+// The row count goes to stderr so json, yaml and csv stay parseable.
+// @param format string
+// @param columns []string
+// @param rows [][]interface{}
 // @return error
-func printQueryResults(rows interface{}) error {
-	switch r := rows.(type) {
-	case pgx.Rows:
-		return printPgxRows(r)
-	default:
-		fmt.Println("📋 Query results (unsupported type)")
+func printQueryResults(format string, columns []string, rows [][]interface{}) error {
+	if format == OutputTable && len(rows) == 0 {
+		infof("📭 No rows returned\n")
 		return nil
 	}
-}
 
-// printPgxRows prints pgx rows in formatted table
-func printPgxRows(rows pgx.Rows) error {
-	// Get field descriptions
-	fieldDescriptions := rows.FieldDescriptions()
-	if len(fieldDescriptions) == 0 {
-		fmt.Println("📭 No columns in result")
-		return nil
+	if err := printRecords(format, columns, rows); err != nil {
+		return err
 	}
-
-	// Print header
-	fmt.Printf("\n")
-	for i, field := range fieldDescriptions {
-		if i > 0 {
-			fmt.Printf(" | ")
-		}
-		fmt.Printf("%-15s", string(field.Name))
-	}
-	fmt.Printf("\n")
-
-	// Print separator
-	for i := range fieldDescriptions {
-		if i > 0 {
-			fmt.Printf("-+-")
-		}
-		fmt.Printf("%-15s", strings.Repeat("-", 15))
-	}
-	fmt.Printf("\n")
-
-	// Print rows
-	rowCount := 0
-	for rows.Next() {
-		values, err := rows.Values()
-		if err != nil {
-			return fmt.Errorf("error scanning row: %w", err)
-		}
-
-		for i, value := range values {
-			if i > 0 {
-				fmt.Printf(" | ")
-			}
-			// Format value based on type
-			formatted := formatValue(value)
-			if len(formatted) > 15 {
-				formatted = formatted[:12] + "..."
-			}
-			fmt.Printf("%-15s", formatted)
-		}
-		fmt.Printf("\n")
-		rowCount++
-	}
-
-	if rowCount == 0 {
-		fmt.Println("📭 No rows returned")
-	} else {
-		fmt.Printf("\n📊 %d row(s) returned\n", rowCount)
-	}
-
-	return rows.Err()
-}
-
-// formatValue formats a value for display
-func formatValue(value interface{}) string {
-	if value == nil {
-		return "<NULL>"
-	}
-
-	switch v := value.(type) {
-	case string:
-		return v
-	case int, int32, int64:
-		return fmt.Sprintf("%d", v)
-	case float32, float64:
-		return fmt.Sprintf("%.2f", v)
-	case bool:
-		if v {
-			return "true"
-		}
-		return "false"
-	case time.Time:
-		return v.Format("2006-01-02 15:04:05")
-	default:
-		return fmt.Sprintf("%v", v)
+	if format == OutputTable {
+		infof("\n📊 %d row(s) returned\n", len(rows))
 	}
+	return nil
 }
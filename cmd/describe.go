@@ -0,0 +1,379 @@
+package migroCMD
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// TableDescription is the structure and size of a table, view or
+// materialized view as shown by migro describe
+type TableDescription struct {
+	Schema      string             `json:"schema" yaml:"schema"`
+	Name        string             `json:"name" yaml:"name"`
+	Kind        string             `json:"kind" yaml:"kind"`
+	RowEstimate *int64             `json:"row_estimate" yaml:"row_estimate"` // nil until the table is analyzed
+	TotalBytes  int64              `json:"total_bytes" yaml:"total_bytes"`
+	TableBytes  int64              `json:"table_bytes" yaml:"table_bytes"`
+	IndexBytes  int64              `json:"index_bytes" yaml:"index_bytes"`
+	Columns     []DescribedColumn  `json:"columns" yaml:"columns"`
+	Indexes     []DescribedIndex   `json:"indexes" yaml:"indexes"`
+	Checks      []DescribedCheck   `json:"checks" yaml:"checks"`
+	Triggers    []DescribedTrigger `json:"triggers" yaml:"triggers"`
+}
+
+// DescribedColumn is a column and the keys it takes part in
+type DescribedColumn struct {
+	Name       string   `json:"name" yaml:"name"`
+	Type       string   `json:"type" yaml:"type"`
+	Nullable   bool     `json:"nullable" yaml:"nullable"`
+	Default    *string  `json:"default" yaml:"default"`
+	PrimaryKey bool     `json:"primary_key" yaml:"primary_key"`
+	Unique     bool     `json:"unique" yaml:"unique"`
+	References []string `json:"references,omitempty" yaml:"references,omitempty"` // schema.table(column) of each foreign key
+}
+
+// DescribedIndex is an index of the table, including those backing constraints
+type DescribedIndex struct {
+	Name       string `json:"name" yaml:"name"`
+	Definition string `json:"definition" yaml:"definition"`
+	Primary    bool   `json:"primary" yaml:"primary"`
+	Unique     bool   `json:"unique" yaml:"unique"`
+}
+
+// DescribedCheck is a check constraint
+type DescribedCheck struct {
+	Name       string `json:"name" yaml:"name"`
+	Definition string `json:"definition" yaml:"definition"`
+}
+
+// DescribedTrigger is a user trigger
+type DescribedTrigger struct {
+	Name       string `json:"name" yaml:"name"`
+	Definition string `json:"definition" yaml:"definition"`
+	Enabled    bool   `json:"enabled" yaml:"enabled"`
+}
+
+// Relation kinds of pg_class.relkind shown by describe and list-tables
+var relationKinds = map[string]string{
+	"r": "table",
+	"p": "partitioned table",
+	"f": "foreign table",
+	"v": "view",
+	"m": "materialized view",
+}
+
+// DescribeTable prints the columns, keys, indexes, check constraints,
+// triggers, row estimate and size of a table in the configured output
+// format. CSV only holds the columns.
+// @param config *CONFIG
+// @param db *pgxpool.Pool
+// @param table string (table or schema.table)
+// @return error
+func DescribeTable(config *CONFIG, db *pgxpool.Pool, table string) error {
+	tableName, err := resolveTableName(config, table)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := commandContext(config)
+	defer cancel()
+
+	description, err := describeTable(ctx, db, tableName)
+	if err != nil {
+		return err
+	}
+
+	switch config.OUTPUT_FORMAT {
+	case OutputJSON, OutputYAML:
+		return printDocument(config.OUTPUT_FORMAT, description)
+	case OutputCSV:
+		return printRecords(OutputCSV, describedColumnHeader, describedColumnRows(description))
+	default:
+		printTableDescription(description)
+		return nil
+	}
+}
+
+// Read the description of a table from pg_catalog
+// @param ctx context.Context
+// @param db *pgxpool.Pool
+// @param table TableName
+// @return *TableDescription, error
+func describeTable(ctx context.Context, db *pgxpool.Pool, table TableName) (*TableDescription, error) {
+	description := &TableDescription{Schema: table.Schema, Name: table.Name}
+
+	var oid uint32
+	var kind string
+	err := db.QueryRow(ctx, `
+		SELECT c.oid, c.relkind::text,
+		       CASE WHEN c.reltuples < 0 THEN NULL ELSE c.reltuples::bigint END,
+		       pg_total_relation_size(c.oid), pg_relation_size(c.oid), pg_indexes_size(c.oid)
+		FROM pg_class c
+		JOIN pg_namespace n ON n.oid = c.relnamespace
+		WHERE n.nspname = $1 AND c.relname = $2 AND c.relkind IN ('r', 'p', 'f', 'v', 'm')`,
+		table.Schema, table.Name).
+		Scan(&oid, &kind, &description.RowEstimate, &description.TotalBytes, &description.TableBytes, &description.IndexBytes)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("❌ table '%s' not found", table)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("❌ failed to read table %s: %w", table, err)
+	}
+	description.Kind = relationKinds[kind]
+
+	if description.Columns, err = describeColumns(ctx, db, oid); err != nil {
+		return nil, err
+	}
+	if description.Indexes, err = describeIndexes(ctx, db, oid); err != nil {
+		return nil, err
+	}
+	if description.Checks, err = describeChecks(ctx, db, oid); err != nil {
+		return nil, err
+	}
+	if description.Triggers, err = describeTriggers(ctx, db, oid); err != nil {
+		return nil, err
+	}
+	return description, nil
+}
+
+// Read columns with their primary key, unique and foreign key membership
+// @param ctx context.Context
+// @param db *pgxpool.Pool
+// @param oid uint32
+// @return []DescribedColumn, error
+func describeColumns(ctx context.Context, db *pgxpool.Pool, oid uint32) ([]DescribedColumn, error) {
+	rows, err := db.Query(ctx, `
+		SELECT a.attname, format_type(a.atttypid, a.atttypmod), NOT a.attnotnull,
+		       CASE
+		           WHEN a.attidentity = 'a' THEN 'GENERATED ALWAYS AS IDENTITY'
+		           WHEN a.attidentity = 'd' THEN 'GENERATED BY DEFAULT AS IDENTITY'
+		           WHEN a.attgenerated = 's' THEN 'GENERATED ALWAYS AS (' || pg_get_expr(d.adbin, d.adrelid) || ') STORED'
+		           ELSE pg_get_expr(d.adbin, d.adrelid)
+		       END
+		FROM pg_attribute a
+		LEFT JOIN pg_attrdef d ON d.adrelid = a.attrelid AND d.adnum = a.attnum
+		WHERE a.attrelid = $1 AND a.attnum > 0 AND NOT a.attisdropped
+		ORDER BY a.attnum`, oid)
+	if err != nil {
+		return nil, fmt.Errorf("❌ failed to read columns: %w", err)
+	}
+	columns, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (DescribedColumn, error) {
+		var column DescribedColumn
+		err := row.Scan(&column.Name, &column.Type, &column.Nullable, &column.Default)
+		return column, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("❌ failed to read columns: %w", err)
+	}
+
+	// One row per column of each primary key, unique and foreign key constraint
+	rows, err = db.Query(ctx, `
+		SELECT a.attname, con.contype::text,
+		       CASE WHEN con.contype = 'f' THEN format('%I.%I(%I)', fn.nspname, fc.relname, fa.attname) END
+		FROM pg_constraint con
+		CROSS JOIN LATERAL unnest(con.conkey) WITH ORDINALITY AS k(attnum, ord)
+		JOIN pg_attribute a ON a.attrelid = con.conrelid AND a.attnum = k.attnum
+		LEFT JOIN pg_class fc ON fc.oid = con.confrelid
+		LEFT JOIN pg_namespace fn ON fn.oid = fc.relnamespace
+		LEFT JOIN pg_attribute fa ON fa.attrelid = con.confrelid AND fa.attnum = con.confkey[k.ord]
+		WHERE con.conrelid = $1 AND con.contype IN ('p', 'u', 'f')
+		ORDER BY con.conname, k.ord`, oid)
+	if err != nil {
+		return nil, fmt.Errorf("❌ failed to read constraints: %w", err)
+	}
+	defer rows.Close()
+
+	byName := make(map[string]*DescribedColumn, len(columns))
+	for i := range columns {
+		byName[columns[i].Name] = &columns[i]
+	}
+	for rows.Next() {
+		var name, contype string
+		var reference *string
+		if err := rows.Scan(&name, &contype, &reference); err != nil {
+			return nil, fmt.Errorf("❌ failed to read constraints: %w", err)
+		}
+		column, ok := byName[name]
+		if !ok {
+			continue
+		}
+		switch contype {
+		case "p":
+			column.PrimaryKey = true
+		case "u":
+			column.Unique = true
+		case "f":
+			if reference != nil {
+				column.References = append(column.References, *reference)
+			}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("❌ failed to read constraints: %w", err)
+	}
+	return columns, nil
+}
+
+// Read all indexes of a table
+// @param ctx context.Context
+// @param db *pgxpool.Pool
+// @param oid uint32
+// @return []DescribedIndex, error
+func describeIndexes(ctx context.Context, db *pgxpool.Pool, oid uint32) ([]DescribedIndex, error) {
+	rows, err := db.Query(ctx, `
+		SELECT i.relname, pg_get_indexdef(x.indexrelid), x.indisprimary, x.indisunique
+		FROM pg_index x
+		JOIN pg_class i ON i.oid = x.indexrelid
+		WHERE x.indrelid = $1
+		ORDER BY x.indisprimary DESC, i.relname`, oid)
+	if err != nil {
+		return nil, fmt.Errorf("❌ failed to read indexes: %w", err)
+	}
+	indexes, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (DescribedIndex, error) {
+		var index DescribedIndex
+		err := row.Scan(&index.Name, &index.Definition, &index.Primary, &index.Unique)
+		return index, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("❌ failed to read indexes: %w", err)
+	}
+	return indexes, nil
+}
+
+// Read the check constraints of a table
+// @param ctx context.Context
+// @param db *pgxpool.Pool
+// @param oid uint32
+// @return []DescribedCheck, error
+func describeChecks(ctx context.Context, db *pgxpool.Pool, oid uint32) ([]DescribedCheck, error) {
+	rows, err := db.Query(ctx, `
+		SELECT conname, pg_get_constraintdef(oid)
+		FROM pg_constraint
+		WHERE conrelid = $1 AND contype = 'c'
+		ORDER BY conname`, oid)
+	if err != nil {
+		return nil, fmt.Errorf("❌ failed to read check constraints: %w", err)
+	}
+	checks, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (DescribedCheck, error) {
+		var check DescribedCheck
+		err := row.Scan(&check.Name, &check.Definition)
+		return check, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("❌ failed to read check constraints: %w", err)
+	}
+	return checks, nil
+}
+
+// Read the user triggers of a table (not the internal ones of foreign keys)
+// @param ctx context.Context
+// @param db *pgxpool.Pool
+// @param oid uint32
+// @return []DescribedTrigger, error
+func describeTriggers(ctx context.Context, db *pgxpool.Pool, oid uint32) ([]DescribedTrigger, error) {
+	rows, err := db.Query(ctx, `
+		SELECT tgname, pg_get_triggerdef(oid), tgenabled <> 'D'
+		FROM pg_trigger
+		WHERE tgrelid = $1 AND NOT tgisinternal
+		ORDER BY tgname`, oid)
+	if err != nil {
+		return nil, fmt.Errorf("❌ failed to read triggers: %w", err)
+	}
+	triggers, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (DescribedTrigger, error) {
+		var trigger DescribedTrigger
+		err := row.Scan(&trigger.Name, &trigger.Definition, &trigger.Enabled)
+		return trigger, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("❌ failed to read triggers: %w", err)
+	}
+	return triggers, nil
+}
+
+// Header of the column rows of a description
+var describedColumnHeader = []string{"column", "type", "nullable", "default", "key"}
+
+// Flatten the columns of a description into rows
+// @param description *TableDescription
+// @return [][]interface{}
+func describedColumnRows(description *TableDescription) [][]interface{} {
+	rows := make([][]interface{}, len(description.Columns))
+	for i, column := range description.Columns {
+		var keys []string
+		if column.PrimaryKey {
+			keys = append(keys, "PK")
+		}
+		if column.Unique {
+			keys = append(keys, "UNIQUE")
+		}
+		for _, reference := range column.References {
+			keys = append(keys, "FK → "+reference)
+		}
+		var columnDefault interface{}
+		if column.Default != nil {
+			columnDefault = *column.Default
+		}
+		rows[i] = []interface{}{column.Name, column.Type, column.Nullable, columnDefault, strings.Join(keys, ", ")}
+	}
+	return rows
+}
+
+// Print a description as text tables
+// @param description *TableDescription
+func printTableDescription(description *TableDescription) {
+	fmt.Printf("📋 %s %s\n\n", strings.ToUpper(description.Kind[:1])+description.Kind[1:],
+		TableName{Schema: description.Schema, Name: description.Name})
+	printTable(describedColumnHeader, describedColumnRows(description))
+
+	if len(description.Indexes) > 0 {
+		fmt.Println("\n🔎 Indexes")
+		for _, index := range description.Indexes {
+			fmt.Printf("   %s: %s\n", index.Name, index.Definition)
+		}
+	}
+	if len(description.Checks) > 0 {
+		fmt.Println("\n✔️  Check constraints")
+		for _, check := range description.Checks {
+			fmt.Printf("   %s: %s\n", check.Name, check.Definition)
+		}
+	}
+	if len(description.Triggers) > 0 {
+		fmt.Println("\n⚡ Triggers")
+		for _, trigger := range description.Triggers {
+			disabled := ""
+			if !trigger.Enabled {
+				disabled = " (disabled)"
+			}
+			fmt.Printf("   %s%s: %s\n", trigger.Name, disabled, trigger.Definition)
+		}
+	}
+
+	rowEstimate := "unknown (not analyzed yet)"
+	if description.RowEstimate != nil {
+		rowEstimate = fmt.Sprintf("~%d", *description.RowEstimate)
+	}
+	fmt.Printf("\n📊 Rows: %s, size: %s (table %s, indexes %s)\n", rowEstimate,
+		formatBytes(description.TotalBytes), formatBytes(description.TableBytes), formatBytes(description.IndexBytes))
+}
+
+// Format a size in bytes like pg_size_pretty
+// @param bytes int64
+// @return string
+func formatBytes(bytes int64) string {
+	units := []string{"bytes", "kB", "MB", "GB", "TB"}
+	size := float64(bytes)
+	unit := 0
+	for size >= 10240 && unit < len(units)-1 {
+		size /= 1024
+		unit++
+	}
+	if unit == 0 {
+		return fmt.Sprintf("%d %s", bytes, units[0])
+	}
+	return fmt.Sprintf("%.0f %s", size, units[unit])
+}
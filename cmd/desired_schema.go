@@ -0,0 +1,199 @@
+package migroCMD
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"gopkg.in/yaml.v3"
+)
+
+// DesiredSchema is the yaml form of a desired schema for migro diff. It
+// only covers the common cases; anything else is written as schema.sql.
+//
+//	schemas: [billing]
+//	enums:
+//	  - name: order_status
+//	    values: [pending, paid]
+//	tables:
+//	  - name: billing.invoices
+//	    columns:
+//	      - {name: invoice_id, type: bigserial, primary_key: true}
+//	      - {name: user_id, type: bigint, not_null: true, references: users(user_id)}
+//	      - {name: status, type: order_status, default: "'pending'"}
+//	    indexes:
+//	      - {columns: [user_id, status]}
+type DesiredSchema struct {
+	Schemas []string       `yaml:"schemas"`
+	Enums   []DesiredEnum  `yaml:"enums"`
+	Tables  []DesiredTable `yaml:"tables"`
+}
+
+// DesiredEnum is an enum type; name may be schema-qualified
+type DesiredEnum struct {
+	Name   string   `yaml:"name"`
+	Values []string `yaml:"values"`
+}
+
+// DesiredTable is a table; name may be schema-qualified
+type DesiredTable struct {
+	Name    string          `yaml:"name"`
+	Columns []DesiredColumn `yaml:"columns"`
+	Indexes []DesiredIndex  `yaml:"indexes"`
+}
+
+// DesiredColumn is a table column
+type DesiredColumn struct {
+	Name       string `yaml:"name"`
+	Type       string `yaml:"type"`
+	PrimaryKey bool   `yaml:"primary_key"`
+	NotNull    bool   `yaml:"not_null"`
+	Unique     bool   `yaml:"unique"`
+	Default    string `yaml:"default"`    // SQL expression, e.g. now() or 'pending'
+	References string `yaml:"references"` // table(column) or schema.table(column)
+}
+
+// DesiredIndex is an index; PostgreSQL names it when name is empty
+type DesiredIndex struct {
+	Name    string   `yaml:"name"`
+	Columns []string `yaml:"columns"`
+	Unique  bool     `yaml:"unique"`
+}
+
+var referenceRegex = regexp.MustCompile(`^\s*(` + qualifiedNamePattern + `)\s*\(\s*(` + identifierPattern + `)\s*\)\s*$`)
+
+// Read a desired schema file as SQL. .sql files are used as they are,
+// .yaml and .yml files are rendered into CREATE statements.
+// @param path string
+// @param defaultSchema string - schema of unqualified names in yaml files
+// @return string, error
+func loadDesiredSchemaSQL(path, defaultSchema string) (string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("❌ failed to read desired schema: %w", err)
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".sql":
+		return string(content), nil
+	case ".yaml", ".yml":
+		var desired DesiredSchema
+		decoder := yaml.NewDecoder(strings.NewReader(string(content)))
+		decoder.KnownFields(true)
+		if err := decoder.Decode(&desired); err != nil {
+			return "", fmt.Errorf("❌ failed to parse %s: %w", path, err)
+		}
+		statements, err := renderDesiredSchema(&desired, defaultSchema)
+		if err != nil {
+			return "", fmt.Errorf("❌ %s: %w", path, err)
+		}
+		return strings.Join(statements, "\n"), nil
+	default:
+		return "", fmt.Errorf("❌ unsupported desired schema file %s (expected .sql, .yaml or .yml)", path)
+	}
+}
+
+// Render a yaml desired schema as CREATE statements
+// @param desired *DesiredSchema
+// @param defaultSchema string
+// @return []string, error
+func renderDesiredSchema(desired *DesiredSchema, defaultSchema string) ([]string, error) {
+	var statements []string
+
+	var schemas []string
+	for _, schema := range desired.Schemas {
+		schemas = append(schemas, normalizeIdentifier(schema))
+	}
+	if defaultSchema != defaultSchemaName {
+		schemas = append(schemas, defaultSchema)
+	}
+	for _, table := range desired.Tables {
+		if name, err := parseTableName(table.Name, defaultSchema); err == nil && name.Schema != defaultSchemaName {
+			schemas = append(schemas, name.Schema)
+		}
+	}
+	for _, schema := range uniqueStrings(schemas) {
+		statements = append(statements, fmt.Sprintf("CREATE SCHEMA IF NOT EXISTS %s;", pgx.Identifier{schema}.Sanitize()))
+	}
+
+	for _, enum := range desired.Enums {
+		name, err := parseTableName(enum.Name, defaultSchema)
+		if err != nil {
+			return nil, fmt.Errorf("enum: %w", err)
+		}
+		if len(enum.Values) == 0 {
+			return nil, fmt.Errorf("enum %s has no values", name)
+		}
+		labels := make([]string, len(enum.Values))
+		for i, value := range enum.Values {
+			labels[i] = quoteLiteral(value)
+		}
+		statements = append(statements, fmt.Sprintf("CREATE TYPE %s AS ENUM (%s);", name.Quoted(), strings.Join(labels, ", ")))
+	}
+
+	// Foreign keys are added after all tables exist, so tables can be listed in any order
+	var foreignKeys []string
+	for _, table := range desired.Tables {
+		name, err := parseTableName(table.Name, defaultSchema)
+		if err != nil {
+			return nil, fmt.Errorf("table: %w", err)
+		}
+		if len(table.Columns) == 0 {
+			return nil, fmt.Errorf("table %s has no columns", name)
+		}
+
+		var columns []string
+		for _, column := range table.Columns {
+			if column.Name == "" || column.Type == "" {
+				return nil, fmt.Errorf("table %s: every column needs a name and a type", name)
+			}
+			definition := []string{quoteColumnName(column.Name), column.Type}
+			if column.PrimaryKey {
+				definition = append(definition, "PRIMARY KEY")
+			}
+			if column.NotNull {
+				definition = append(definition, "NOT NULL")
+			}
+			if column.Unique {
+				definition = append(definition, "UNIQUE")
+			}
+			if column.Default != "" {
+				definition = append(definition, "DEFAULT "+column.Default)
+			}
+			columns = append(columns, "    "+strings.Join(definition, " "))
+
+			if column.References != "" {
+				match := referenceRegex.FindStringSubmatch(column.References)
+				if match == nil {
+					return nil, fmt.Errorf("table %s: invalid reference '%s' (expected table(column) or schema.table(column))", name, column.References)
+				}
+				referenced, err := parseTableName(match[1], defaultSchema)
+				if err != nil {
+					return nil, fmt.Errorf("table %s: %w", name, err)
+				}
+				foreignKeys = append(foreignKeys, fmt.Sprintf("ALTER TABLE %s ADD FOREIGN KEY (%s) REFERENCES %s (%s);",
+					name.Quoted(), quoteColumnName(column.Name), referenced.Quoted(), quoteColumnName(match[2])))
+			}
+		}
+		statements = append(statements, fmt.Sprintf("CREATE TABLE %s (\n%s\n);", name.Quoted(), strings.Join(columns, ",\n")))
+
+		for _, index := range table.Indexes {
+			if len(index.Columns) == 0 {
+				return nil, fmt.Errorf("table %s: every index needs columns", name)
+			}
+			keyword := "INDEX"
+			if index.Unique {
+				keyword = "UNIQUE INDEX"
+			}
+			if index.Name != "" {
+				keyword += " " + quoteColumnName(index.Name)
+			}
+			statements = append(statements, fmt.Sprintf("CREATE %s ON %s (%s);", keyword, name.Quoted(), strings.Join(quoteColumnNames(index.Columns), ", ")))
+		}
+	}
+
+	return append(statements, foreignKeys...), nil
+}
@@ -0,0 +1,153 @@
+package migroCMD
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// DiffOptions configures migro diff
+type DiffOptions struct {
+	FromDB     bool   // compare from the live database (the only source supported)
+	To         string // desired schema file: .sql, .yaml or .yml
+	Name       string // name of the migration to write
+	ScratchURL string // empty database to load the desired schema in, instead of a temporary one
+	DryRun     bool   // print the migration instead of writing it
+}
+
+// GenerateDiffMigration compares the live database with a desired schema
+// file and writes a migration whose Up turns the database into the desired
+// schema and whose Down reverts it. The desired schema is loaded into a
+// scratch database first, so PostgreSQL itself resolves types, defaults and
+// constraint names the same way on both sides.
+// @param config *CONFIG
+// @param db *pgxpool.Pool
+// @param opts DiffOptions
+// @return error
+func GenerateDiffMigration(config *CONFIG, db *pgxpool.Pool, opts DiffOptions) error {
+	if !opts.FromDB {
+		return fmt.Errorf("❌ --from-db is required, migro diff compares the live database with --to")
+	}
+
+	desiredSQL, err := loadDesiredSchemaSQL(opts.To, config.DEFAULT_SCHEMA)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := commandContext(config)
+	defer cancel()
+
+	infof("🔍 Reading the live schema...\n")
+	current, err := introspectMigratedSchema(ctx, NewMigrator(config, db))
+	if err != nil {
+		return err
+	}
+
+	scratch, cleanup, err := openScratchDatabase(ctx, db, opts.ScratchURL)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	infof("🧪 Loading %s into a scratch database...\n", opts.To)
+	if _, err := scratch.Exec(ctx, desiredSQL); err != nil {
+		return fmt.Errorf("❌ failed to load %s: %w", opts.To, err)
+	}
+	desired, err := introspectMigratedSchema(ctx, NewMigrator(config, scratch))
+	if err != nil {
+		return err
+	}
+
+	up, upWarnings := renderSchemaChanges(current, desired)
+	down, downWarnings := renderSchemaChanges(desired, current)
+	if len(up) == 0 && len(upWarnings) == 0 {
+		infof("✅ The database already matches %s, no migration written\n", opts.To)
+		return nil
+	}
+
+	header := fmt.Sprintf("-- Generated by migro diff from the database to %s on %s\n-- Review the statements before applying them.",
+		opts.To, time.Now().UTC().Format("2006-01-02 15:04:05 UTC"))
+	var warnings []string
+	for _, warning := range upWarnings {
+		warnings = append(warnings, "Up: "+warning)
+	}
+	for _, warning := range downWarnings {
+		warnings = append(warnings, "Down: "+warning)
+	}
+
+	// A label added with ALTER TYPE ... ADD VALUE cannot be used before the
+	// transaction that added it commits, e.g. by a column default or check
+	content := &Migration{Up: up, Down: down}
+	if addsEnumLabels(up) {
+		content.NoTransaction = true
+		// NoTransaction applies to both sections
+		content.Up = sessionFunctionBodyChecks(up)
+		content.Down = sessionFunctionBodyChecks(down)
+		warnings = append(warnings, "Up: enum labels are added, the migration runs without a transaction so later statements can use them; "+
+			"if a statement fails, the ones before it stay applied")
+	}
+	for _, warning := range warnings {
+		header += "\n-- ⚠️  " + warning
+	}
+
+	if opts.DryRun {
+		infof("📋 Dry run, %s would contain:\n\n", opts.Name)
+		fmt.Print(renderMigrationSQL(header, content))
+		printDiffWarnings(warnings)
+		return nil
+	}
+
+	migration, err := writeMigrationFile(config.MIGRATION_DIR, opts.Name, header, content)
+	if err != nil {
+		return fmt.Errorf("❌ failed to write migration: %w", err)
+	}
+	infof("✅ Created migration file: %s (%d Up, %d Down statement(s))\n", migration.Path, len(up), len(down))
+	printDiffWarnings(warnings)
+	return nil
+}
+
+// Print changes that lose data or need a manual migration step
+// @param warnings []string
+func printDiffWarnings(warnings []string) {
+	if len(warnings) == 0 {
+		return
+	}
+	infof("⚠️  Changes to review before applying:\n")
+	infof("   %s\n", strings.Join(warnings, "\n   "))
+}
+
+// Turn off function body checks for the session instead of the
+// transaction, since SET LOCAL has no effect outside a transaction, and
+// turn them back on after the last statement
+// @param statements []string
+// @return []string
+func sessionFunctionBodyChecks(statements []string) []string {
+	var result []string
+	disabled := false
+	for _, statement := range statements {
+		if statement == disableFunctionBodyChecks {
+			result = append(result, "SET check_function_bodies = false;")
+			disabled = true
+			continue
+		}
+		result = append(result, statement)
+	}
+	if disabled {
+		result = append(result, "RESET check_function_bodies;")
+	}
+	return result
+}
+
+// Report whether any statement adds a label to an existing enum
+// @param statements []string
+// @return bool
+func addsEnumLabels(statements []string) bool {
+	for _, statement := range statements {
+		if strings.HasPrefix(statement, "ALTER TYPE ") && strings.Contains(statement, " ADD VALUE ") {
+			return true
+		}
+	}
+	return false
+}
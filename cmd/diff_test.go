@@ -0,0 +1,56 @@
+package migroCMD
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAddsEnumLabels(t *testing.T) {
+	added, _ := renderEnumChanges(
+		SchemaEnum{Schema: "public", Name: "status", Labels: []string{"new", "done"}},
+		SchemaEnum{Schema: "public", Name: "status", Labels: []string{"new", "active", "done"}},
+	)
+	if len(added) != 1 {
+		t.Fatalf("renderEnumChanges() = %q, want one ADD VALUE statement", added)
+	}
+
+	tests := []struct {
+		name       string
+		statements []string
+		want       bool
+	}{
+		{name: "added label", statements: append([]string{"CREATE TABLE a (id int);"}, added...), want: true},
+		{name: "new enum type", statements: []string{"CREATE TYPE \"public\".\"s\" AS ENUM ('a');"}},
+		{name: "no statements"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := addsEnumLabels(tt.statements); got != tt.want {
+				t.Errorf("addsEnumLabels() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSessionFunctionBodyChecks(t *testing.T) {
+	statements := []string{
+		disableFunctionBodyChecks,
+		"CREATE FUNCTION f() RETURNS int LANGUAGE plpgsql AS $$ BEGIN RETURN 1; END $$;",
+		"ALTER TYPE \"public\".\"status\" ADD VALUE IF NOT EXISTS 'active';",
+	}
+	want := []string{
+		"SET check_function_bodies = false;",
+		statements[1],
+		statements[2],
+		"RESET check_function_bodies;",
+	}
+	if got := sessionFunctionBodyChecks(statements); !reflect.DeepEqual(got, want) {
+		t.Errorf("sessionFunctionBodyChecks() = %q, want %q", got, want)
+	}
+
+	plain := []string{"ALTER TYPE \"public\".\"status\" ADD VALUE IF NOT EXISTS 'active';"}
+	if got := sessionFunctionBodyChecks(plain); !reflect.DeepEqual(got, plain) {
+		t.Errorf("sessionFunctionBodyChecks() without functions = %q, want %q", got, plain)
+	}
+}
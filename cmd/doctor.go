@@ -0,0 +1,341 @@
+package migroCMD
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Minimum supported PostgreSQL version (server_version_num)
+const minServerVersionNum = 120000
+
+// Result of a single doctor check
+const (
+	checkPass = "pass"
+	checkWarn = "warn"
+	checkFail = "fail"
+)
+
+// DoctorOptions selects the config the doctor inspects
+type DoctorOptions struct {
+	ConfigPath string // empty if no config file was found
+	Env        string
+	Wait       int // overrides DATABASE_WAIT_SECONDS when >= 0
+}
+
+// DoctorCheck is one line of the doctor report
+type DoctorCheck struct {
+	Name   string
+	Status string
+	Detail string
+	Hint   string
+}
+
+// RunDoctor checks the config, database, tools and migration directory and
+// prints a pass/fail report with hints
+// @param opts DoctorOptions
+// @return error - non-nil if any check failed
+func RunDoctor(opts DoctorOptions) error {
+	infof("🩺 Running migro doctor...\n")
+
+	var checks []DoctorCheck
+	config, configCheck := doctorCheckConfig(opts)
+	checks = append(checks, configCheck)
+
+	checks = append(checks, doctorCheckTool("goose", "-version",
+		"Optional: migro applies migrations itself, goose is only needed to run the files by hand"))
+	checks = append(checks, doctorCheckTool("sqlc", "version",
+		"Needed by migro sqlc: go install github.com/sqlc-dev/sqlc/cmd/sqlc@latest"))
+
+	if config != nil {
+		checks = append(checks, doctorCheckMigrationDir(config.MIGRATION_DIR))
+		checks = append(checks, doctorCheckMigrationFiles(config.MIGRATION_DIR))
+		if configCheck.Status == checkFail {
+			// Connecting with placeholder credentials only adds a misleading failure
+			checks = append(checks, DoctorCheck{Name: "Database", Status: checkWarn,
+				Detail: "skipped, the config check failed", Hint: "Fix the config first"})
+		} else {
+			checks = append(checks, doctorCheckDatabase(config)...)
+		}
+	}
+
+	failed := printDoctorReport(checks)
+	if failed > 0 {
+		return fmt.Errorf("❌ doctor found %d problem(s)", failed)
+	}
+	return nil
+}
+
+// Check that the config file loads without placeholder values
+// @param opts DoctorOptions
+// @return *CONFIG, DoctorCheck (config is nil if it cannot be used)
+func doctorCheckConfig(opts DoctorOptions) (*CONFIG, DoctorCheck) {
+	check := DoctorCheck{Name: "Config"}
+	if opts.ConfigPath == "" {
+		check.Status = checkFail
+		check.Detail = "no config file found"
+		check.Hint = "Run migro init, or pass --config"
+		return nil, check
+	}
+
+	config, err := LoadConfig(opts.ConfigPath, opts.Env)
+	if err != nil {
+		check.Status = checkFail
+		check.Detail = fmt.Sprintf("%s: %v", opts.ConfigPath, err)
+		check.Hint = "Fix the YAML syntax, the --env name or the referenced environment variables"
+		return nil, check
+	}
+	if opts.Wait >= 0 {
+		config.DATABASE_WAIT_SECONDS = opts.Wait
+	}
+
+	if placeholder := findPlaceholderValue(config); placeholder != "" {
+		check.Status = checkFail
+		check.Detail = fmt.Sprintf("%s still contains the placeholder '%s'", opts.ConfigPath, placeholder)
+		check.Hint = "Set DATABASE_USERNAME, DATABASE_PASSWORD and DATABASE_NAME to real values"
+		return config, check
+	}
+
+	env := config.ENV
+	if env == "" {
+		env = "default"
+	}
+	check.Status = checkPass
+	check.Detail = fmt.Sprintf("%s (environment: %s, %s)", opts.ConfigPath, env, describeDatabaseTarget(config))
+	return config, check
+}
+
+// Check that a tool is on PATH and report its version
+// @param name string
+// @param versionArg string
+// @param hint string
+// @return DoctorCheck
+func doctorCheckTool(name, versionArg, hint string) DoctorCheck {
+	check := DoctorCheck{Name: name}
+	path, err := exec.LookPath(name)
+	if err != nil {
+		check.Status = checkWarn
+		check.Detail = "not found on PATH"
+		check.Hint = hint
+		return check
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	output, err := exec.CommandContext(ctx, path, versionArg).CombinedOutput()
+	version := strings.TrimSpace(string(output))
+	if err != nil || version == "" {
+		version = "unknown version"
+	}
+
+	check.Status = checkPass
+	check.Detail = fmt.Sprintf("%s (%s)", path, strings.Split(version, "\n")[0])
+	return check
+}
+
+// Check that the migration directory exists and is writable
+// @param migrationDir string
+// @return DoctorCheck
+func doctorCheckMigrationDir(migrationDir string) DoctorCheck {
+	check := DoctorCheck{Name: "Migration dir"}
+	if migrationDir == "" {
+		check.Status = checkFail
+		check.Detail = "MIGRATION_DIR is not configured"
+		check.Hint = "Set MIGRATION_DIR in the config file"
+		return check
+	}
+
+	info, err := os.Stat(migrationDir)
+	if err != nil {
+		check.Status = checkFail
+		check.Detail = fmt.Sprintf("%s: %v", migrationDir, err)
+		check.Hint = fmt.Sprintf("Create it: mkdir -p %s", migrationDir)
+		return check
+	}
+	if !info.IsDir() {
+		check.Status = checkFail
+		check.Detail = fmt.Sprintf("%s is not a directory", migrationDir)
+		check.Hint = "Point MIGRATION_DIR to a directory"
+		return check
+	}
+
+	probe, err := os.CreateTemp(migrationDir, ".migro-doctor-*")
+	if err != nil {
+		check.Status = checkFail
+		check.Detail = fmt.Sprintf("%s is not writable: %v", migrationDir, err)
+		check.Hint = "Fix the directory permissions"
+		return check
+	}
+	probe.Close()
+	os.Remove(probe.Name())
+
+	check.Status = checkPass
+	check.Detail = fmt.Sprintf("%s exists and is writable", migrationDir)
+	return check
+}
+
+// Check migration files for duplicate versions, temp leftovers and
+// content that does not parse
+// @param migrationDir string
+// @return DoctorCheck
+func doctorCheckMigrationFiles(migrationDir string) DoctorCheck {
+	check := DoctorCheck{Name: "Migration files"}
+	matches, err := filepath.Glob(filepath.Join(migrationDir, "*.sql"))
+	if err != nil {
+		check.Status = checkFail
+		check.Detail = err.Error()
+		return check
+	}
+
+	files := make(map[int64][]string)
+	var problems, hints []string
+	count := 0
+	for _, file := range matches {
+		version, name, ok := parseMigrationFileName(file)
+		if !ok {
+			continue
+		}
+		count++
+		files[version] = append(files[version], filepath.Base(file))
+
+		if strings.HasPrefix(name, "temp_") {
+			problems = append(problems, fmt.Sprintf("temp placeholder %s", filepath.Base(file)))
+			hints = append(hints, "Run migro reconcile to remove temp placeholders")
+		}
+		if _, err := readMigrationFile(file); err != nil {
+			problems = append(problems, err.Error())
+			hints = append(hints, "Fix the -- +goose annotations of the file")
+		}
+	}
+	if duplicates := duplicateVersionProblems(files); len(duplicates) > 0 {
+		problems = append(problems, duplicates...)
+		hints = append(hints, "Rename one of the files to a new version")
+	}
+
+	if len(problems) > 0 {
+		check.Status = checkFail
+		check.Detail = strings.Join(problems, "; ")
+		check.Hint = strings.Join(uniqueStrings(hints), "; ")
+		return check
+	}
+
+	check.Status = checkPass
+	check.Detail = fmt.Sprintf("%d migration file(s), no duplicates or temp leftovers", count)
+	return check
+}
+
+// Describe every version used by more than one file, in version order
+// @param files map[int64][]string - file names by version
+// @return []string
+func duplicateVersionProblems(files map[int64][]string) []string {
+	var versions []int64
+	for version, names := range files {
+		if len(names) > 1 {
+			versions = append(versions, version)
+		}
+	}
+	sort.Slice(versions, func(i, j int) bool { return versions[i] < versions[j] })
+
+	var problems []string
+	for _, version := range versions {
+		problems = append(problems, fmt.Sprintf("duplicate version %d: %s", version, strings.Join(files[version], ", ")))
+	}
+	return problems
+}
+
+// Check the database is reachable, its version is supported and the
+// version table exists
+// @param config *CONFIG
+// @return []DoctorCheck
+func doctorCheckDatabase(config *CONFIG) []DoctorCheck {
+	connection := DoctorCheck{Name: "Database"}
+	db, err := DBConnection(config)
+	if err != nil {
+		connection.Status = checkFail
+		connection.Detail = strings.TrimPrefix(err.Error(), "❌ ")
+		connection.Hint = "Check host, port, credentials and DATABASE_SSLMODE; use --wait if the database is still starting"
+		return []DoctorCheck{connection}
+	}
+	defer db.Close()
+
+	ctx, cancel := commandContext(config)
+	defer cancel()
+
+	var versionNum int
+	var version string
+	err = db.QueryRow(ctx, "SELECT current_setting('server_version_num')::int, current_setting('server_version')").Scan(&versionNum, &version)
+	if err != nil {
+		connection.Status = checkFail
+		connection.Detail = fmt.Sprintf("connected but failed to read the server version: %v", err)
+		return []DoctorCheck{connection}
+	}
+	connection.Detail = fmt.Sprintf("PostgreSQL %s", version)
+	if versionNum < minServerVersionNum {
+		connection.Status = checkFail
+		connection.Hint = "migro needs PostgreSQL 12 or newer"
+	} else {
+		connection.Status = checkPass
+	}
+
+	table := DoctorCheck{Name: "Version table"}
+	migrator := NewMigrator(config, db)
+	var exists bool
+	if err := db.QueryRow(ctx, "SELECT to_regclass($1) IS NOT NULL", migrator.table).Scan(&exists); err != nil {
+		table.Status = checkFail
+		table.Detail = err.Error()
+	} else if !exists {
+		table.Status = checkWarn
+		table.Detail = fmt.Sprintf("%s does not exist yet", migrator.table)
+		table.Hint = "It is created by the first migro migrate"
+	} else {
+		table.Status = checkPass
+		table.Detail = fmt.Sprintf("%s exists", migrator.table)
+	}
+
+	return []DoctorCheck{connection, table}
+}
+
+// Print the doctor report to stderr
+// @param checks []DoctorCheck
+// @return int - number of failed checks
+func printDoctorReport(checks []DoctorCheck) int {
+	failed := 0
+	infof("\n")
+	for _, check := range checks {
+		icon := "✅"
+		switch check.Status {
+		case checkWarn:
+			icon = "⚠️ "
+		case checkFail:
+			icon = "❌"
+			failed++
+		}
+		infof("%s %-16s %s\n", icon, check.Name, check.Detail)
+		if check.Hint != "" && check.Status != checkPass {
+			infof("   💡 %s\n", check.Hint)
+		}
+	}
+	infof("\n")
+	if failed == 0 {
+		infof("✅ All checks passed\n")
+	}
+	return failed
+}
+
+// Remove duplicate strings, keeping the first occurrence
+// @param values []string
+// @return []string
+func uniqueStrings(values []string) []string {
+	var unique []string
+	for _, value := range values {
+		if !contains(unique, value) {
+			unique = append(unique, value)
+		}
+	}
+	return unique
+}
@@ -0,0 +1,54 @@
+package migroCMD
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestDuplicateVersionProblems(t *testing.T) {
+	files := map[int64][]string{
+		20240103120000: {"20240103120000_c.sql", "20240103120000_d.sql"},
+		20240101120000: {"20240101120000_a.sql"},
+		20240102120000: {"20240102120000_b.sql", "20240102120000_b2.sql"},
+		20240104120000: {"20240104120000_e.sql", "20240104120000_f.sql"},
+	}
+	want := []string{
+		"duplicate version 20240102120000: 20240102120000_b.sql, 20240102120000_b2.sql",
+		"duplicate version 20240103120000: 20240103120000_c.sql, 20240103120000_d.sql",
+		"duplicate version 20240104120000: 20240104120000_e.sql, 20240104120000_f.sql",
+	}
+
+	// Map iteration order is random, repeat to catch unstable output
+	for i := 0; i < 20; i++ {
+		if got := duplicateVersionProblems(files); !reflect.DeepEqual(got, want) {
+			t.Fatalf("duplicateVersionProblems() = %v, want %v", got, want)
+		}
+	}
+}
+
+func TestDoctorCheckMigrationFiles(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{
+		"20240102120000_b.sql",
+		"20240102120000_b2.sql",
+		"20240101120000_a.sql",
+		"20240101120000_a2.sql",
+	} {
+		content := "-- +goose Up\nSELECT 1;\n-- +goose Down\n"
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	check := doctorCheckMigrationFiles(dir)
+	if check.Status != checkFail {
+		t.Fatalf("Status = %v, want %v", check.Status, checkFail)
+	}
+	want := "duplicate version 20240101120000: 20240101120000_a.sql, 20240101120000_a2.sql; " +
+		"duplicate version 20240102120000: 20240102120000_b.sql, 20240102120000_b2.sql"
+	if check.Detail != want {
+		t.Errorf("Detail = %q, want %q", check.Detail, want)
+	}
+}
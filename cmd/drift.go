@@ -0,0 +1,90 @@
+package migroCMD
+
+import (
+	"fmt"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// DriftOptions configures migro drift
+type DriftOptions struct {
+	ScratchURL string // empty database to replay migrations in, instead of a temporary one
+}
+
+// DetectDrift replays all migration files into a scratch database and
+// compares the resulting schema (tables, columns, types, constraints,
+// indexes, views, sequences and enums) with the live database. Any
+// difference is returned as an error, so CI fails on drift.
+// @param config *CONFIG
+// @param db *pgxpool.Pool
+// @param opts DriftOptions
+// @return error
+func DetectDrift(config *CONFIG, db *pgxpool.Pool, opts DriftOptions) error {
+	ctx, cancel := commandContext(config)
+	defer cancel()
+
+	migrations, err := loadMigrations(config.MIGRATION_DIR)
+	if err != nil {
+		return fmt.Errorf("❌ failed to load migrations: %w", err)
+	}
+
+	live := NewMigrator(config, db)
+	pending, _, err := live.pendingMigrations(ctx)
+	if err != nil {
+		return fmt.Errorf("❌ failed to read applied migrations: %w", err)
+	}
+
+	infof("🔍 Reading the live schema...\n")
+	actual, err := introspectMigratedSchema(ctx, live)
+	if err != nil {
+		return err
+	}
+
+	scratch, cleanup, err := openScratchDatabase(ctx, db, opts.ScratchURL)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	infof("🧪 Replaying %d migration(s) into a scratch database...\n", len(migrations))
+	replayed, err := replayMigrations(ctx, config, scratch, migrations)
+	if err != nil {
+		return fmt.Errorf("❌ failed to replay migrations: %w", err)
+	}
+	expected, err := introspectMigratedSchema(ctx, replayed)
+	if err != nil {
+		return err
+	}
+
+	differences := diffSchemas(expected, actual)
+	if err := printSchemaDifferences(config.OUTPUT_FORMAT, differences); err != nil {
+		return err
+	}
+
+	if len(pending) > 0 {
+		infof("⚠️  %d migration(s) are not applied to the database yet, their objects show up as missing\n", len(pending))
+		infof("💡 Run: migro status to check for pending migrations\n")
+	}
+	if len(differences) > 0 {
+		return fmt.Errorf("❌ schema drift detected: %d difference(s) between the migration files and the database", len(differences))
+	}
+	return nil
+}
+
+// Print schema differences; missing means created by the migrations but
+// not in the database, extra means in the database only
+// @param format string
+// @param differences []SchemaDifference
+// @return error
+func printSchemaDifferences(format string, differences []SchemaDifference) error {
+	if format == OutputTable && len(differences) == 0 {
+		infof("✅ No drift: the database matches the migration files\n")
+		return nil
+	}
+
+	rows := make([][]interface{}, len(differences))
+	for i, difference := range differences {
+		rows[i] = []interface{}{difference.Change, difference.Object, difference.Name, difference.Detail}
+	}
+	return printRecords(format, []string{"change", "object", "name", "detail"}, rows)
+}
@@ -0,0 +1,156 @@
+package migroCMD
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// InitOptions are the answers migro init writes into a new config file.
+// Empty fields are asked interactively unless Yes is set.
+type InitOptions struct {
+	ConfigPath       string
+	Host             string
+	Port             string
+	User             string
+	Password         string
+	Database         string
+	SSLMode          string
+	MigrationDir     string
+	QueryDir         string
+	InitialMigration string // create an empty migration with this name
+	Baseline         bool   // write a baseline migration from the existing database
+	Force            bool   // overwrite an existing config file
+	Yes              bool   // accept defaults instead of prompting
+}
+
+// InitProject writes a config file, creates the migration and query
+// directories and optionally an initial or baseline migration
+// @param opts InitOptions
+// @return error
+func InitProject(opts InitOptions) error {
+	if opts.ConfigPath == "" {
+		opts.ConfigPath = "migro.yaml"
+	}
+	if _, err := os.Stat(opts.ConfigPath); err == nil && !opts.Force {
+		return fmt.Errorf("❌ %s already exists (use --force to overwrite it)", opts.ConfigPath)
+	}
+
+	infof("🚀 Initializing migro project...\n")
+	reader := bufio.NewReader(os.Stdin)
+	ask := func(value *string, question, defaultValue string) {
+		if *value != "" {
+			return
+		}
+		if opts.Yes {
+			*value = defaultValue
+			return
+		}
+		infof("   %s [%s]: ", question, defaultValue)
+		answer, _ := reader.ReadString('\n')
+		if answer = strings.TrimSpace(answer); answer != "" {
+			*value = answer
+		} else {
+			*value = defaultValue
+		}
+	}
+
+	ask(&opts.Host, "Database host", "localhost")
+	ask(&opts.Port, "Database port", "5432")
+	ask(&opts.User, "Database user", "postgres")
+	ask(&opts.Password, "Database password (${VAR} reads it from the environment)", "${DB_PASSWORD}")
+	ask(&opts.Database, "Database name", "postgres")
+	ask(&opts.SSLMode, "SSL mode (disable, require, verify-ca, verify-full)", "disable")
+	ask(&opts.MigrationDir, "Migration directory", "./db/migrations")
+	ask(&opts.QueryDir, "Query directory", "./db/queries")
+
+	if err := os.WriteFile(opts.ConfigPath, []byte(renderInitConfig(opts)), 0600); err != nil {
+		return fmt.Errorf("❌ failed to write %s: %w", opts.ConfigPath, err)
+	}
+	infof("✅ Wrote %s\n", opts.ConfigPath)
+
+	for _, dir := range []string{opts.MigrationDir, opts.QueryDir} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("❌ failed to create %s: %w", dir, err)
+		}
+		infof("📁 Created %s\n", dir)
+	}
+
+	if opts.InitialMigration != "" {
+		if err := CreateEmptyMigration(opts.MigrationDir, opts.InitialMigration); err != nil {
+			return err
+		}
+	}
+
+	if opts.Baseline {
+		if err := initBaseline(opts.ConfigPath); err != nil {
+			return err
+		}
+	}
+
+	infof("\n🎉 Done! Next steps:\n")
+	infof("   migro doctor                           # check the setup\n")
+	infof("   migro status                           # show migration status\n")
+	infof("   migro create-migration --name=<name>   # write your first migration\n")
+	return nil
+}
+
+// Load the new config and write a baseline migration from the database
+// @param configPath string
+// @return error
+func initBaseline(configPath string) error {
+	config, err := LoadConfig(configPath, "")
+	if err != nil {
+		return fmt.Errorf("❌ failed to load %s: %w", configPath, err)
+	}
+
+	db, err := DBConnection(config)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	return CreateBaseline(config, db)
+}
+
+// Render the config file written by migro init
+// @param opts InitOptions
+// @return string
+func renderInitConfig(opts InitOptions) string {
+	return fmt.Sprintf(`# Migro configuration, generated by migro init
+# Values may reference environment variables: "${VAR}" or "${VAR:-default}"
+
+# Default environment; select another with --env or MIGRO_ENV
+ENV: "development"
+
+# Database Configuration
+DATABASE_DRIVER: "postgres"
+DATABASE_HOST: %s
+DATABASE_PORT: %s
+DATABASE_USERNAME: %s
+DATABASE_PASSWORD: %s
+DATABASE_NAME: %s
+DATABASE_SSLMODE: %s
+
+# Deadline in seconds for a whole command (0 = no deadline)
+TIMEOUT_SECONDS: 300
+# Fail fast instead of queueing behind live traffic
+LOCK_TIMEOUT: "5s"
+
+# Directory Paths (relative to project root)
+MIGRATION_DIR: %s
+QUERY_DIR: %s
+
+# Version tracking table (goose compatible)
+MIGRATION_TABLE: "goose_db_version"
+
+# Per-environment overrides of the keys above
+# environments:
+#   production:
+#     DATABASE_HOST: "prod-db.example.com"
+#     DATABASE_SSLMODE: "verify-full"
+`, strconv.Quote(opts.Host), strconv.Quote(opts.Port), strconv.Quote(opts.User), strconv.Quote(opts.Password),
+		strconv.Quote(opts.Database), strconv.Quote(opts.SSLMode), strconv.Quote(opts.MigrationDir), strconv.Quote(opts.QueryDir))
+}
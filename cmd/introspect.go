@@ -0,0 +1,529 @@
+package migroCMD
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// SchemaSnapshot is the structure of a live database read from pg_catalog
+type SchemaSnapshot struct {
+	Schemas   []string // user schemas other than public
+	Enums     []SchemaEnum
+	Sequences []SchemaSequence
+	Functions []SchemaFunction
+	Tables    []SchemaTable
+	Views     []SchemaView
+}
+
+// SchemaEnum is an enum type
+type SchemaEnum struct {
+	Schema string
+	Name   string
+	Labels []string
+}
+
+// SchemaSequence is a standalone or serial sequence (identity sequences are
+// part of their column)
+type SchemaSequence struct {
+	Schema    string
+	Name      string
+	DataType  string
+	Start     int64
+	Increment int64
+	Min       int64
+	Max       int64
+	Cache     int64
+	Cycle     bool
+	OwnedBy   string // quoted schema.table.column for serial sequences
+}
+
+// SchemaFunction is a function or procedure
+type SchemaFunction struct {
+	Schema     string
+	Name       string
+	Arguments  string // identity arguments, as in pg_get_function_identity_arguments
+	Procedure  bool
+	Language   string // as in pg_language.lanname
+	RowTypes   bool   // a table row type appears in its arguments or result
+	Definition string // CREATE OR REPLACE statement, as in pg_get_functiondef
+}
+
+// SchemaTable is a table with its columns, constraints, indexes and
+// triggers. A partition only holds what it does not inherit from its parent.
+type SchemaTable struct {
+	OID            uint32
+	Schema         string
+	Name           string
+	PartitionKey   string // partitioned tables, as in pg_get_partkeydef, e.g. RANGE (created_at)
+	PartitionOf    string // partitions, quoted schema.table of the parent
+	PartitionBound string // partitions, e.g. FOR VALUES FROM (...) TO (...) or DEFAULT
+	Columns        []SchemaColumn
+	Constraints    []SchemaConstraint
+	Indexes        []SchemaIndex
+	Triggers       []SchemaTrigger
+}
+
+// SchemaColumn is a table column
+type SchemaColumn struct {
+	Name      string
+	Type      string
+	NotNull   bool
+	Default   string // default or generation expression
+	Identity  string // "a" (ALWAYS), "d" (BY DEFAULT) or empty
+	Generated bool   // stored generated column, Default holds the expression
+}
+
+// SchemaConstraint is a table constraint
+type SchemaConstraint struct {
+	Name       string
+	Type       string // p, u, c, f or x as in pg_constraint.contype
+	Definition string
+}
+
+// SchemaIndex is an index that does not back a constraint
+type SchemaIndex struct {
+	Name       string
+	Definition string
+	Parent     string // quoted schema.index of the partitioned index it is attached to
+}
+
+// SchemaTrigger is a user trigger (not the internal ones of foreign keys)
+type SchemaTrigger struct {
+	Name       string
+	Definition string
+}
+
+// SchemaView is a view or materialized view
+type SchemaView struct {
+	Schema       string
+	Name         string
+	Definition   string
+	Materialized bool
+}
+
+// QualifiedName returns the quoted schema.table name
+func (t *SchemaTable) QualifiedName() string {
+	return pgx.Identifier{t.Schema, t.Name}.Sanitize()
+}
+
+// Signature returns the quoted name with its argument types, as used by
+// DROP FUNCTION
+func (f *SchemaFunction) Signature() string {
+	return fmt.Sprintf("%s(%s)", pgx.Identifier{f.Schema, f.Name}.Sanitize(), f.Arguments)
+}
+
+// Objects created by extensions are recreated by CREATE EXTENSION, not by migrations
+const notExtensionMember = "NOT EXISTS (SELECT 1 FROM pg_depend dep WHERE dep.objid = %s AND dep.deptype = 'e')"
+
+// Read the structure of all user schemas. Tables listed in exclude (migro's
+// own version and history tables, as schema.table) are skipped.
+// @param ctx context.Context
+// @param db *pgxpool.Pool
+// @param exclude []string
+// @return *SchemaSnapshot, error
+func introspectSchema(ctx context.Context, db *pgxpool.Pool, exclude []string) (*SchemaSnapshot, error) {
+	snapshot := &SchemaSnapshot{}
+
+	schemas, err := queryUserSchemas(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	for _, schema := range schemas {
+		if schema != "public" {
+			snapshot.Schemas = append(snapshot.Schemas, schema)
+		}
+	}
+
+	if snapshot.Enums, err = queryEnums(ctx, db, schemas); err != nil {
+		return nil, err
+	}
+	if snapshot.Sequences, err = querySequences(ctx, db, schemas, exclude); err != nil {
+		return nil, err
+	}
+	if snapshot.Functions, err = queryFunctions(ctx, db, schemas); err != nil {
+		return nil, err
+	}
+	if snapshot.Tables, err = queryTables(ctx, db, schemas, exclude); err != nil {
+		return nil, err
+	}
+	if snapshot.Views, err = queryViews(ctx, db, schemas); err != nil {
+		return nil, err
+	}
+
+	return snapshot, nil
+}
+
+// Get user schema names, public first
+// @param ctx context.Context
+// @param db *pgxpool.Pool
+// @return []string, error
+func queryUserSchemas(ctx context.Context, db *pgxpool.Pool) ([]string, error) {
+	rows, err := db.Query(ctx, `SELECT n.nspname FROM pg_namespace n
+		WHERE n.nspname NOT LIKE 'pg\_%' AND n.nspname <> 'information_schema'
+			AND `+fmt.Sprintf(notExtensionMember, "n.oid")+`
+		ORDER BY n.nspname <> 'public', n.nspname`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query schemas: %w", err)
+	}
+	schemas, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schemas: %w", err)
+	}
+	return schemas, nil
+}
+
+// Get enum types with their labels in sort order
+// @param ctx context.Context
+// @param db *pgxpool.Pool
+// @param schemas []string
+// @return []SchemaEnum, error
+func queryEnums(ctx context.Context, db *pgxpool.Pool, schemas []string) ([]SchemaEnum, error) {
+	rows, err := db.Query(ctx, `SELECT n.nspname, t.typname, array_agg(e.enumlabel::text ORDER BY e.enumsortorder)
+		FROM pg_type t
+		JOIN pg_enum e ON e.enumtypid = t.oid
+		JOIN pg_namespace n ON n.oid = t.typnamespace
+		WHERE n.nspname = ANY($1) AND `+fmt.Sprintf(notExtensionMember, "t.oid")+`
+		GROUP BY n.nspname, t.typname
+		ORDER BY n.nspname, t.typname`, schemas)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query enums: %w", err)
+	}
+	defer rows.Close()
+
+	var enums []SchemaEnum
+	for rows.Next() {
+		var enum SchemaEnum
+		if err := rows.Scan(&enum.Schema, &enum.Name, &enum.Labels); err != nil {
+			return nil, fmt.Errorf("failed to scan enum: %w", err)
+		}
+		enums = append(enums, enum)
+	}
+	return enums, rows.Err()
+}
+
+// Get sequences that are not identity sequences, leaving out serial
+// sequences of excluded tables
+// @param ctx context.Context
+// @param db *pgxpool.Pool
+// @param schemas []string
+// @param exclude []string - schema.table names to skip
+// @return []SchemaSequence, error
+func querySequences(ctx context.Context, db *pgxpool.Pool, schemas []string, exclude []string) ([]SchemaSequence, error) {
+	rows, err := db.Query(ctx, `SELECT s.schemaname, s.sequencename, s.data_type::text,
+			s.start_value, s.increment_by, s.min_value, s.max_value, s.cache_size, s.cycle,
+			COALESCE(owner.column_name, ''), COALESCE(owner.table_name, '')
+		FROM pg_sequences s
+		JOIN pg_namespace n ON n.nspname = s.schemaname
+		JOIN pg_class c ON c.relnamespace = n.oid AND c.relname = s.sequencename
+		LEFT JOIN LATERAL (
+			SELECT quote_ident(tn.nspname) || '.' || quote_ident(tc.relname) || '.' || quote_ident(a.attname) AS column_name,
+			       tn.nspname || '.' || tc.relname AS table_name
+			FROM pg_depend d
+			JOIN pg_class tc ON tc.oid = d.refobjid
+			JOIN pg_namespace tn ON tn.oid = tc.relnamespace
+			JOIN pg_attribute a ON a.attrelid = d.refobjid AND a.attnum = d.refobjsubid
+			WHERE d.objid = c.oid AND d.classid = 'pg_class'::regclass AND d.deptype = 'a'
+			LIMIT 1
+		) owner ON true
+		WHERE s.schemaname = ANY($1)
+			AND NOT EXISTS (SELECT 1 FROM pg_depend dep WHERE dep.objid = c.oid AND dep.deptype IN ('i', 'e'))
+		ORDER BY s.schemaname, s.sequencename`, schemas)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query sequences: %w", err)
+	}
+	defer rows.Close()
+
+	var sequences []SchemaSequence
+	for rows.Next() {
+		var seq SchemaSequence
+		var ownerTable string
+		if err := rows.Scan(&seq.Schema, &seq.Name, &seq.DataType, &seq.Start, &seq.Increment,
+			&seq.Min, &seq.Max, &seq.Cache, &seq.Cycle, &seq.OwnedBy, &ownerTable); err != nil {
+			return nil, fmt.Errorf("failed to scan sequence: %w", err)
+		}
+		if contains(exclude, ownerTable) {
+			continue
+		}
+		sequences = append(sequences, seq)
+	}
+	return sequences, rows.Err()
+}
+
+// Get functions and procedures; aggregates and window functions are left out
+// @param ctx context.Context
+// @param db *pgxpool.Pool
+// @param schemas []string
+// @return []SchemaFunction, error
+func queryFunctions(ctx context.Context, db *pgxpool.Pool, schemas []string) ([]SchemaFunction, error) {
+	rows, err := db.Query(ctx, `SELECT n.nspname, p.proname, pg_get_function_identity_arguments(p.oid),
+			p.prokind = 'p', l.lanname,
+			EXISTS (SELECT 1 FROM pg_type t JOIN pg_class tc ON tc.oid = t.typrelid
+				WHERE tc.relkind IN ('r', 'p', 'v', 'm')
+					AND (t.oid = p.prorettype OR t.typarray = p.prorettype
+						OR t.oid = ANY(COALESCE(p.proallargtypes, p.proargtypes::oid[]))
+						OR t.typarray = ANY(COALESCE(p.proallargtypes, p.proargtypes::oid[])))),
+			pg_get_functiondef(p.oid)
+		FROM pg_proc p
+		JOIN pg_namespace n ON n.oid = p.pronamespace
+		JOIN pg_language l ON l.oid = p.prolang
+		WHERE p.prokind IN ('f', 'p') AND n.nspname = ANY($1) AND `+fmt.Sprintf(notExtensionMember, "p.oid")+`
+		ORDER BY n.nspname, p.proname, p.oid`, schemas)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query functions: %w", err)
+	}
+	defer rows.Close()
+
+	var functions []SchemaFunction
+	for rows.Next() {
+		var function SchemaFunction
+		if err := rows.Scan(&function.Schema, &function.Name, &function.Arguments, &function.Procedure, &function.Language, &function.RowTypes, &function.Definition); err != nil {
+			return nil, fmt.Errorf("failed to scan function: %w", err)
+		}
+		functions = append(functions, function)
+	}
+	return functions, rows.Err()
+}
+
+// Get tables with their columns, constraints, indexes and triggers
+// @param ctx context.Context
+// @param db *pgxpool.Pool
+// @param schemas []string
+// @param exclude []string - schema.table names to skip
+// @return []SchemaTable, error
+func queryTables(ctx context.Context, db *pgxpool.Pool, schemas []string, exclude []string) ([]SchemaTable, error) {
+	rows, err := db.Query(ctx, `SELECT c.oid, n.nspname, c.relname, c.relispartition,
+			COALESCE(pg_get_partkeydef(c.oid), ''),
+			COALESCE(pn.nspname, ''), COALESCE(pc.relname, ''),
+			COALESCE(pg_get_expr(c.relpartbound, c.oid), '')
+		FROM pg_class c
+		JOIN pg_namespace n ON n.oid = c.relnamespace
+		LEFT JOIN pg_inherits i ON i.inhrelid = c.oid AND c.relispartition
+		LEFT JOIN pg_class pc ON pc.oid = i.inhparent
+		LEFT JOIN pg_namespace pn ON pn.oid = pc.relnamespace
+		WHERE c.relkind IN ('r', 'p')
+			AND n.nspname = ANY($1) AND `+fmt.Sprintf(notExtensionMember, "c.oid")+`
+		ORDER BY n.nspname, c.relname`, schemas)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query tables: %w", err)
+	}
+
+	var tables []SchemaTable
+	partitions := make(map[uint32]bool)
+	for rows.Next() {
+		var table SchemaTable
+		var partition bool
+		var parentSchema, parentName string
+		if err := rows.Scan(&table.OID, &table.Schema, &table.Name, &partition,
+			&table.PartitionKey, &parentSchema, &parentName, &table.PartitionBound); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan table: %w", err)
+		}
+		if contains(exclude, table.Schema+"."+table.Name) {
+			continue
+		}
+		if partition {
+			table.PartitionOf = pgx.Identifier{parentSchema, parentName}.Sanitize()
+			partitions[table.OID] = true
+		}
+		tables = append(tables, table)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read tables: %w", err)
+	}
+	tables = orderPartitions(tables)
+
+	byOID := make(map[uint32]*SchemaTable)
+	var oids []uint32
+	for i := range tables {
+		byOID[tables[i].OID] = &tables[i]
+		oids = append(oids, tables[i].OID)
+	}
+
+	// Columns
+	rows, err = db.Query(ctx, `SELECT a.attrelid, a.attname, format_type(a.atttypid, a.atttypmod), a.attnotnull,
+			COALESCE(pg_get_expr(d.adbin, d.adrelid), ''), a.attidentity::text, a.attgenerated = 's'
+		FROM pg_attribute a
+		LEFT JOIN pg_attrdef d ON d.adrelid = a.attrelid AND d.adnum = a.attnum
+		WHERE a.attrelid = ANY($1::oid[]) AND a.attnum > 0 AND NOT a.attisdropped
+		ORDER BY a.attrelid, a.attnum`, oids)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query columns: %w", err)
+	}
+	for rows.Next() {
+		var oid uint32
+		var column SchemaColumn
+		if err := rows.Scan(&oid, &column.Name, &column.Type, &column.NotNull, &column.Default, &column.Identity, &column.Generated); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan column: %w", err)
+		}
+		if partitions[oid] {
+			continue // a partition has the columns of its parent
+		}
+		byOID[oid].Columns = append(byOID[oid].Columns, column)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read columns: %w", err)
+	}
+
+	// Constraints (NOT NULL is part of the column), without the ones a
+	// partition inherits or clones from its parent
+	rows, err = db.Query(ctx, `SELECT conrelid, conname, contype::text, pg_get_constraintdef(oid, true)
+		FROM pg_constraint
+		WHERE conrelid = ANY($1::oid[]) AND contype IN ('p', 'u', 'c', 'f', 'x')
+			AND conislocal AND conparentid = 0
+		ORDER BY conrelid, array_position(ARRAY['p', 'u', 'x', 'c', 'f'], contype::text), conname`, oids)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query constraints: %w", err)
+	}
+	for rows.Next() {
+		var oid uint32
+		var constraint SchemaConstraint
+		if err := rows.Scan(&oid, &constraint.Name, &constraint.Type, &constraint.Definition); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan constraint: %w", err)
+		}
+		byOID[oid].Constraints = append(byOID[oid].Constraints, constraint)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read constraints: %w", err)
+	}
+
+	// Indexes that are not created by a constraint
+	rows, err = db.Query(ctx, `SELECT i.indrelid, ic.relname, pg_get_indexdef(i.indexrelid),
+			COALESCE(pn.nspname, ''), COALESCE(pc.relname, '')
+		FROM pg_index i
+		JOIN pg_class ic ON ic.oid = i.indexrelid
+		LEFT JOIN pg_inherits ih ON ih.inhrelid = i.indexrelid
+		LEFT JOIN pg_class pc ON pc.oid = ih.inhparent
+		LEFT JOIN pg_namespace pn ON pn.oid = pc.relnamespace
+		WHERE i.indrelid = ANY($1::oid[])
+			AND NOT EXISTS (SELECT 1 FROM pg_constraint co WHERE co.conindid = i.indexrelid AND co.contype IN ('p', 'u', 'x'))
+		ORDER BY i.indrelid, ic.relname`, oids)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query indexes: %w", err)
+	}
+	for rows.Next() {
+		var oid uint32
+		var index SchemaIndex
+		var parentSchema, parentName string
+		if err := rows.Scan(&oid, &index.Name, &index.Definition, &parentSchema, &parentName); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan index: %w", err)
+		}
+		if parentName != "" {
+			index.Parent = pgx.Identifier{parentSchema, parentName}.Sanitize()
+		}
+		byOID[oid].Indexes = append(byOID[oid].Indexes, index)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read indexes: %w", err)
+	}
+
+	// User triggers, without the clones of a parent's triggers on partitions
+	var versionNum int
+	if err := db.QueryRow(ctx, "SELECT current_setting('server_version_num')::int").Scan(&versionNum); err != nil {
+		return nil, fmt.Errorf("failed to get server version: %w", err)
+	}
+	rows, err = db.Query(ctx, triggerQuery(versionNum), oids)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query triggers: %w", err)
+	}
+	for rows.Next() {
+		var oid uint32
+		var trigger SchemaTrigger
+		if err := rows.Scan(&oid, &trigger.Name, &trigger.Definition); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan trigger: %w", err)
+		}
+		byOID[oid].Triggers = append(byOID[oid].Triggers, trigger)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read triggers: %w", err)
+	}
+
+	return tables, nil
+}
+
+// Build the query reading user triggers. PostgreSQL 13 marks the clones of
+// a parent's triggers on partitions with tgparentid; before, clones are
+// internal triggers and NOT tgisinternal already skips them.
+// @param versionNum int - server_version_num
+// @return string
+func triggerQuery(versionNum int) string {
+	filter := "NOT tgisinternal"
+	if versionNum >= 130000 {
+		filter += " AND tgparentid = 0"
+	}
+	return `SELECT tgrelid, tgname, pg_get_triggerdef(oid)
+		FROM pg_trigger
+		WHERE tgrelid = ANY($1::oid[]) AND ` + filter + `
+		ORDER BY tgrelid, tgname`
+}
+
+// Order tables so every partition follows its parent, keeping the name
+// order otherwise
+// @param tables []SchemaTable
+// @return []SchemaTable
+func orderPartitions(tables []SchemaTable) []SchemaTable {
+	listed := make(map[string]bool, len(tables))
+	for _, table := range tables {
+		listed[table.QualifiedName()] = true
+	}
+
+	ordered := make([]SchemaTable, 0, len(tables))
+	placed := make(map[string]bool, len(tables))
+	for len(ordered) < len(tables) {
+		progress := false
+		for _, table := range tables {
+			name := table.QualifiedName()
+			if placed[name] {
+				continue
+			}
+			if table.PartitionOf != "" && listed[table.PartitionOf] && !placed[table.PartitionOf] {
+				continue
+			}
+			ordered = append(ordered, table)
+			placed[name] = true
+			progress = true
+		}
+		if !progress {
+			break
+		}
+	}
+	return ordered
+}
+
+// Get views and materialized views in creation order
+// @param ctx context.Context
+// @param db *pgxpool.Pool
+// @param schemas []string
+// @return []SchemaView, error
+func queryViews(ctx context.Context, db *pgxpool.Pool, schemas []string) ([]SchemaView, error) {
+	rows, err := db.Query(ctx, `SELECT n.nspname, c.relname, pg_get_viewdef(c.oid, true), c.relkind = 'm'
+		FROM pg_class c
+		JOIN pg_namespace n ON n.oid = c.relnamespace
+		WHERE c.relkind IN ('v', 'm') AND n.nspname = ANY($1) AND `+fmt.Sprintf(notExtensionMember, "c.oid")+`
+		ORDER BY c.oid`, schemas)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query views: %w", err)
+	}
+	defer rows.Close()
+
+	var views []SchemaView
+	for rows.Next() {
+		var view SchemaView
+		if err := rows.Scan(&view.Schema, &view.Name, &view.Definition, &view.Materialized); err != nil {
+			return nil, fmt.Errorf("failed to scan view: %w", err)
+		}
+		views = append(views, view)
+	}
+	return views, rows.Err()
+}
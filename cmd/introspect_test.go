@@ -0,0 +1,30 @@
+package migroCMD
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTriggerQuery(t *testing.T) {
+	tests := []struct {
+		name         string
+		versionNum   int
+		wantParentID bool
+	}{
+		{name: "PostgreSQL 12 has no tgparentid", versionNum: 120015},
+		{name: "PostgreSQL 13 skips cloned triggers", versionNum: 130000, wantParentID: true},
+		{name: "PostgreSQL 16", versionNum: 160004, wantParentID: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query := triggerQuery(tt.versionNum)
+			if !strings.Contains(query, "NOT tgisinternal") {
+				t.Errorf("triggerQuery(%d) = %q, want it to skip internal triggers", tt.versionNum, query)
+			}
+			if got := strings.Contains(query, "tgparentid"); got != tt.wantParentID {
+				t.Errorf("triggerQuery(%d) uses tgparentid = %v, want %v", tt.versionNum, got, tt.wantParentID)
+			}
+		})
+	}
+}
@@ -0,0 +1,182 @@
+package migroCMD
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Provenance of a listed relation
+const (
+	provenanceTracked   = "tracked"   // in the database and created by a migration
+	provenanceUntracked = "untracked" // in the database, but in no migration file
+	provenanceMissing   = "missing"   // in a migration file, but not in the database
+)
+
+// ListedRelation is a table, view or materialized view shown by list-tables
+type ListedRelation struct {
+	Schema      string
+	Name        string
+	Kind        string
+	RowEstimate *int64
+	SizeBytes   *int64
+	Migration   string
+	Status      string
+}
+
+// ListTables prints the tables, views and materialized views of the user
+// schemas with their row estimate, size and the migration that created them.
+// Relations that exist only in the database or only in migration files are
+// flagged.
+// @param config *CONFIG
+// @param db *pgxpool.Pool
+// @return error
+func ListTables(config *CONFIG, db *pgxpool.Pool) error {
+	ctx, cancel := commandContext(config)
+	defer cancel()
+
+	relations, err := listRelations(ctx, NewMigrator(config, db))
+	if err != nil {
+		return err
+	}
+
+	schema, err := loadMigrationSchema(config.MIGRATION_DIR, config.DEFAULT_SCHEMA)
+	if err != nil {
+		return fmt.Errorf("❌ failed to parse migration files: %w", err)
+	}
+	origins := schema.origins()
+
+	untracked, missing := 0, 0
+	seen := make(map[TableName]bool)
+	for i := range relations {
+		relation := &relations[i]
+		name := TableName{Schema: relation.Schema, Name: relation.Name}
+		seen[name] = true
+		if origin, ok := origins[name]; ok {
+			relation.Migration = origin.File
+			relation.Status = provenanceTracked
+		} else {
+			relation.Status = provenanceUntracked
+			untracked++
+		}
+	}
+	for name, origin := range origins {
+		if seen[name] {
+			continue
+		}
+		relations = append(relations, ListedRelation{
+			Schema:    name.Schema,
+			Name:      name.Name,
+			Kind:      origin.Kind,
+			Migration: origin.File,
+			Status:    provenanceMissing,
+		})
+		missing++
+	}
+	sort.SliceStable(relations, func(i, j int) bool {
+		if relations[i].Schema != relations[j].Schema {
+			return relations[i].Schema < relations[j].Schema
+		}
+		return relations[i].Name < relations[j].Name
+	})
+
+	if err := printListedRelations(config.OUTPUT_FORMAT, relations); err != nil {
+		return err
+	}
+
+	if untracked > 0 {
+		infof("⚠️  %d relation(s) exist in the database but in no migration file\n", untracked)
+	}
+	if missing > 0 {
+		infof("⚠️  %d relation(s) are created by migration files but missing in the database\n", missing)
+		infof("💡 Run: migro status to check for pending migrations\n")
+	}
+	return nil
+}
+
+// Read the tables, views and materialized views of all user schemas,
+// leaving out migro's own tables and partitions
+// @param ctx context.Context
+// @param migrator *Migrator
+// @return []ListedRelation, error
+func listRelations(ctx context.Context, migrator *Migrator) ([]ListedRelation, error) {
+	schemas, err := queryUserSchemas(ctx, migrator.db)
+	if err != nil {
+		return nil, err
+	}
+	exclude, err := migrator.ownTables(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := migrator.db.Query(ctx, `
+		SELECT n.nspname, c.relname, c.relkind::text,
+		       CASE WHEN c.reltuples < 0 OR c.relkind = 'v' THEN NULL ELSE c.reltuples::bigint END,
+		       CASE WHEN c.relkind = 'v' THEN NULL ELSE pg_total_relation_size(c.oid) END
+		FROM pg_class c
+		JOIN pg_namespace n ON n.oid = c.relnamespace
+		WHERE n.nspname = ANY($1) AND c.relkind IN ('r', 'p', 'f', 'v', 'm') AND NOT c.relispartition
+			AND `+fmt.Sprintf(notExtensionMember, "c.oid")+`
+		ORDER BY n.nspname, c.relname`, schemas)
+	if err != nil {
+		return nil, fmt.Errorf("❌ failed to list tables: %w", err)
+	}
+	relations, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (ListedRelation, error) {
+		var relation ListedRelation
+		var kind string
+		err := row.Scan(&relation.Schema, &relation.Name, &kind, &relation.RowEstimate, &relation.SizeBytes)
+		relation.Kind = relationKinds[kind]
+		return relation, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("❌ failed to list tables: %w", err)
+	}
+
+	var listed []ListedRelation
+	for _, relation := range relations {
+		if !contains(exclude, relation.Schema+"."+relation.Name) {
+			listed = append(listed, relation)
+		}
+	}
+	return listed, nil
+}
+
+// Print listed relations; the table format shows sizes like pg_size_pretty,
+// the other formats in bytes
+// @param format string
+// @param relations []ListedRelation
+// @return error
+func printListedRelations(format string, relations []ListedRelation) error {
+	sizeColumn := "size_bytes"
+	if format == OutputTable {
+		sizeColumn = "size"
+	}
+	columns := []string{"schema", "name", "kind", "row_estimate", sizeColumn, "migration", "status"}
+
+	rows := make([][]interface{}, len(relations))
+	for i, relation := range relations {
+		var rowEstimate, size, migration interface{}
+		if relation.RowEstimate != nil {
+			rowEstimate = *relation.RowEstimate
+		}
+		if relation.SizeBytes != nil {
+			size = *relation.SizeBytes
+			if format == OutputTable {
+				size = formatBytes(*relation.SizeBytes)
+			}
+		}
+		if relation.Migration != "" {
+			migration = relation.Migration
+		}
+		rows[i] = []interface{}{relation.Schema, relation.Name, relation.Kind, rowEstimate, size, migration, relation.Status}
+	}
+
+	if format == OutputTable && len(rows) == 0 {
+		infof("📭 No tables found\n")
+		return nil
+	}
+	return printRecords(format, columns, rows)
+}
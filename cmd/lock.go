@@ -0,0 +1,158 @@
+package migroCMD
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	defaultLockTimeoutSeconds = 60
+	lockPollInterval          = 500 * time.Millisecond
+)
+
+// Acquire a session-level advisory lock so only one migro process can
+// migrate or rollback a database at a time. The lock lives on a dedicated
+// connection and is released by the returned function.
+// @param ctx context.Context
+// @param db *pgxpool.Pool
+// @param config *CONFIG
+// @return func(), error
+func acquireMigrationLock(ctx context.Context, db *pgxpool.Pool, config *CONFIG) (func(), error) {
+	conn, err := db.Acquire(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to acquire connection for migration lock: %w", err)
+	}
+
+	var database string
+	if err := conn.QueryRow(ctx, "SELECT current_database()").Scan(&database); err != nil {
+		conn.Release()
+		return nil, fmt.Errorf("failed to get current database: %w", err)
+	}
+
+	table := config.MIGRATION_TABLE
+	if table == "" {
+		table = defaultMigrationTable
+	}
+	key := migrationLockKey(database, table)
+
+	timeoutSeconds := config.MIGRATION_LOCK_TIMEOUT_SECONDS
+	if timeoutSeconds <= 0 {
+		timeoutSeconds = defaultLockTimeoutSeconds
+	}
+	deadline := time.Now().Add(time.Duration(timeoutSeconds) * time.Second)
+
+	waiting := false
+	for {
+		var locked bool
+		if err := conn.QueryRow(ctx, "SELECT pg_try_advisory_lock($1)", key).Scan(&locked); err != nil {
+			conn.Release()
+			return nil, fmt.Errorf("failed to acquire migration lock: %w", err)
+		}
+		if locked {
+			break
+		}
+
+		holder := describeLockHolder(ctx, conn.Conn(), key)
+		if time.Now().After(deadline) {
+			conn.Release()
+			return nil, fmt.Errorf("❌ could not acquire migration lock on %s.%s within %ds: %s", database, table, timeoutSeconds, holder)
+		}
+		if !waiting {
+			infof("⏳ Another migration is running (%s), waiting up to %ds...\n", holder, timeoutSeconds)
+			waiting = true
+		}
+
+		select {
+		case <-ctx.Done():
+			conn.Release()
+			return nil, fmt.Errorf("waiting for migration lock: %w", ctx.Err())
+		case <-time.After(lockPollInterval):
+		}
+	}
+
+	if waiting {
+		infof("🔓 Migration lock acquired\n")
+	}
+
+	release := func() {
+		// Use a fresh context so the lock is released even after cancellation
+		_, err := conn.Exec(context.Background(), "SELECT pg_advisory_unlock($1)", key)
+		if err != nil {
+			infof("⚠️  Failed to release migration lock: %v\n", err)
+		}
+		conn.Release()
+	}
+
+	return release, nil
+}
+
+// Derive the advisory lock key from the database and version table names
+// @param database string
+// @param table string
+// @return int64
+func migrationLockKey(database, table string) int64 {
+	h := fnv.New64a()
+	h.Write([]byte("migro:" + database + ":" + table))
+	return int64(h.Sum64())
+}
+
+// Describe the session currently holding the advisory lock
+// @param ctx context.Context
+// @param conn *pgx.Conn
+// @param key int64
+// @return string
+func describeLockHolder(ctx context.Context, conn *pgx.Conn, key int64) string {
+	classID, objID := advisoryLockIDs(key)
+
+	var pid int
+	var user, application, clientAddr, state string
+	var since *time.Time
+	err := conn.QueryRow(ctx, `
+		SELECT l.pid,
+			COALESCE(a.usename::text, ''),
+			COALESCE(a.application_name, ''),
+			COALESCE(host(a.client_addr), 'local'),
+			COALESCE(a.state, ''),
+			a.backend_start
+		FROM pg_locks l
+		LEFT JOIN pg_stat_activity a ON a.pid = l.pid
+		WHERE l.locktype = 'advisory' AND l.granted
+			AND l.classid::bigint = $1 AND l.objid::bigint = $2 AND l.objsubid = 1
+		LIMIT 1`,
+		classID, objID,
+	).Scan(&pid, &user, &application, &clientAddr, &state, &since)
+	if err != nil {
+		return "lock holder unknown"
+	}
+
+	return formatLockHolder(pid, user, application, clientAddr, state, since)
+}
+
+// Split a bigint advisory lock key the way pg_locks stores it: classid
+// holds the high 32 bits and objid the low 32 bits
+// @param key int64
+// @return int64, int64 (classid, objid)
+func advisoryLockIDs(key int64) (int64, int64) {
+	return int64(uint64(key) >> 32), int64(uint64(key) & 0xffffffff)
+}
+
+// Format the session holding the lock for wait and timeout messages
+// @param pid int
+// @param user string
+// @param application string
+// @param clientAddr string
+// @param state string
+// @param since *time.Time - backend start, nil if unknown
+// @return string
+func formatLockHolder(pid int, user, application, clientAddr, state string, since *time.Time) string {
+	description := fmt.Sprintf("held by PID %d (user=%s, application=%s, client=%s, state=%s", pid, user, application, clientAddr, state)
+	if since != nil {
+		description += fmt.Sprintf(", connected since %s", since.Format("2006-01-02 15:04:05"))
+	}
+	return description + ")"
+}
@@ -0,0 +1,59 @@
+package migroCMD
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMigrationLockKey(t *testing.T) {
+	key := migrationLockKey("app", "schema_migrations")
+	if key != migrationLockKey("app", "schema_migrations") {
+		t.Error("migrationLockKey() is not stable for the same database and table")
+	}
+	if key == migrationLockKey("other", "schema_migrations") {
+		t.Error("migrationLockKey() is the same for different databases")
+	}
+	if key == migrationLockKey("app", "audit.schema_migrations") {
+		t.Error("migrationLockKey() is the same for different version tables")
+	}
+	// The separator keeps names that concatenate the same apart
+	if migrationLockKey("ab", "c") == migrationLockKey("a", "bc") {
+		t.Error("migrationLockKey() collides for shifted names")
+	}
+}
+
+func TestAdvisoryLockIDs(t *testing.T) {
+	tests := []struct {
+		key       int64
+		wantClass int64
+		wantObj   int64
+	}{
+		{key: 0, wantClass: 0, wantObj: 0},
+		{key: 0x0000000100000002, wantClass: 1, wantObj: 2},
+		{key: -1, wantClass: 0xffffffff, wantObj: 0xffffffff},
+		{key: -0x7fffffff00000000, wantClass: 0x80000001, wantObj: 0},
+	}
+
+	for _, tt := range tests {
+		classID, objID := advisoryLockIDs(tt.key)
+		if classID != tt.wantClass || objID != tt.wantObj {
+			t.Errorf("advisoryLockIDs(%d) = (%d, %d), want (%d, %d)", tt.key, classID, objID, tt.wantClass, tt.wantObj)
+		}
+	}
+}
+
+func TestFormatLockHolder(t *testing.T) {
+	since := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	got := formatLockHolder(42, "deploy", "migro", "10.0.0.5", "active", &since)
+	want := "held by PID 42 (user=deploy, application=migro, client=10.0.0.5, state=active, connected since 2024-01-02 03:04:05)"
+	if got != want {
+		t.Errorf("formatLockHolder() = %q, want %q", got, want)
+	}
+
+	got = formatLockHolder(7, "", "", "local", "idle", nil)
+	want = "held by PID 7 (user=, application=, client=local, state=idle)"
+	if got != want {
+		t.Errorf("formatLockHolder() without start time = %q, want %q", got, want)
+	}
+}
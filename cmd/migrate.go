@@ -2,256 +2,200 @@ package migroCMD
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
-	"regexp"
-	"strconv"
 	"strings"
 
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
-func MigrateUp(config *CONFIG, db *pgxpool.Pool) error {
-	fmt.Println("🚀 Starting database migration up...")
-
-	// Clear temp files tracker
-	createdTempFiles = []string{}
+// Run all pending migrations
+// @param config *CONFIG
+// @param db *pgxpool.Pool
+// @param opts MigrateOptions
+// @return error
+func MigrateUp(config *CONFIG, db *pgxpool.Pool, opts MigrateOptions) error {
+	ctx, cancel := commandContext(config)
+	defer cancel()
 
-	// First attempt - run migration normally
-	output, err := executeGoose(config, MigrationScriptUp)
+	migrator := NewMigrator(config, db)
+	if opts.AllowOutOfOrder {
+		migrator.outOfOrder = true
+	}
 
-	if err != nil {
-		// Check if it's a missing migration error
-		if strings.Contains(string(output), "missing migration") && strings.Contains(string(output), "before current version") {
-			fmt.Println("🔧 Found missing migration, handling...")
-
-			// Handle missing migration ONCE
-			handled := handleMissingMigrationForMigrate(config, string(output))
-			if !handled {
-				return fmt.Errorf("failed to handle missing migration: %w\nOutput: %s", err, string(output))
-			}
+	var target int64
+	if opts.Target != "" {
+		migration, err := resolveTargetVersion(config.MIGRATION_DIR, opts.Target)
+		if err != nil {
+			return err
+		}
+		target = migration.Version
+	}
 
-			fmt.Println("✅ Created temp files, running migration again...")
+	if opts.DryRun {
+		return dryRunMigrateUp(ctx, migrator, target, opts.Output)
+	}
 
-			output, err = executeGoose(config, MigrationScriptUp)
+	if target > 0 {
+		infof("🚀 Starting database migration up to version %d...\n", target)
+	} else {
+		infof("🚀 Starting database migration up...\n")
+	}
 
-			if err != nil {
-				return fmt.Errorf("migration up failed even after creating temp files: %w\nOutput: %s", err, string(output))
-			}
-		} else {
-			// Other error, not related to missing migration
-			return fmt.Errorf("migration up failed: %w\nOutput: %s", err, string(output))
-		}
+	// Make sure no other process is migrating this database
+	unlock, err := acquireMigrationLock(ctx, db, config)
+	if err != nil {
+		return err
 	}
+	defer unlock()
 
-	// Success
-	fmt.Println("✅ Migration up completed successfully!")
-	if len(strings.TrimSpace(string(output))) > 0 {
-		fmt.Println("📋 Migration output:")
-		fmt.Print(string(output))
+	if err := migrator.ensureVersionTable(ctx); err != nil {
+		return fmt.Errorf("migration up failed: %w", err)
 	}
 
-	// Show migration status
-	fmt.Println("\n📊 Current migration status:")
-	err = showMigrationStatus(config)
+	// Refuse to migrate if applied migrations were edited afterwards
+	report, err := migrator.verifyChecksums(ctx)
 	if err != nil {
-		fmt.Printf("⚠️  Failed to show migration status: %v\n", err)
+		return fmt.Errorf("migration up failed: %w", err)
 	}
-
-	// Auto cleanup temp files if any were created
-	if len(createdTempFiles) > 0 {
-		fmt.Println("\n🧹 Auto cleanup temp migration files...")
-		fmt.Printf("🔍 Tìm thấy %d temp files được tạo trong quá trình migration\n", len(createdTempFiles))
-		cleanupTempFiles()
-	} else {
-		// Also check for any existing temp files
-		fmt.Println("\n🧹 Auto cleanup temp migration files...")
-		err = autoCleanupTempFiles(config)
-		if err != nil {
-			fmt.Printf("⚠️  Failed to cleanup temp files: %v\n", err)
+	if len(report.Mismatches) > 0 {
+		infof("🚨 Applied migrations were modified:\n")
+		printChecksumReport(report)
+		return fmt.Errorf("migration up aborted: %d applied migration(s) changed after being applied\n%s",
+			len(report.Mismatches), mismatchHint(report.Mismatches))
+	}
+	if len(report.Outdated) > 0 {
+		if err := migrator.repairChecksums(ctx, report.Outdated); err != nil {
+			return fmt.Errorf("migration up failed: %w", err)
 		}
+		infof("🔧 Re-recorded the checksums of %d migration(s) in the current format\n", len(report.Outdated))
 	}
 
-	return nil
-}
-
-// Auto cleanup temp files without user interaction (for automated workflows)
-func autoCleanupTempFiles(config *CONFIG) error {
-	// Look for files with temp_ prefix in their name
-	pattern := fmt.Sprintf("%s/*_temp_*.sql", config.MIGRATION_DIR)
-	matches, err := filepath.Glob(pattern)
+	previousVersion, err := migrator.currentVersion(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to find temp files: %w", err)
+		return fmt.Errorf("migration up failed: %w", err)
 	}
 
-	if len(matches) == 0 {
-		return nil
+	applied, err := migrator.Up(ctx, target)
+	printOutOfOrderSummary(applied, previousVersion)
+	if err != nil {
+		var missingErr *MissingMigrationsError
+		if errors.As(err, &missingErr) {
+			infof("🔧 Found missing migration, handling...\n")
+			handleMissingMigrationForMigrate(ctx, migrator, missingErr.Versions)
+		}
+		return fmt.Errorf("migration up failed: %w", err)
 	}
 
-	removedCount := 0
-	for _, file := range matches {
-		err := os.Remove(file)
-		if err != nil {
-			fmt.Printf("⚠️  Failed to remove %s: %v\n", file, err)
-		} else {
-			removedCount++
-		}
+	// Success
+	infof("✅ Migration up completed successfully!\n")
+	if len(applied) == 0 {
+		infof("📋 No pending migrations, database is up to date\n")
+	} else {
+		infof("📋 Applied %d migration(s)\n", len(applied))
 	}
 
-	if removedCount > 0 {
-		fmt.Printf("✅ Auto cleaned up %d/%d temp files\n", removedCount, len(matches))
+	// Show migration status
+	err = showMigrationStatus(ctx, migrator, OutputTable)
+	if err != nil {
+		infof("⚠️  Failed to show migration status: %v\n", err)
 	}
 
 	return nil
 }
 
-// Handle missing migration specifically for migrate up operation
-// Returns true if handled successfully, false otherwise
-// @param config *CONFIG
-// @param errorOutput string
-// @return bool
-func handleMissingMigrationForMigrate(config *CONFIG, errorOutput string) bool {
-	fmt.Println("🔍 Analyzing missing migration error...")
-
-	// First try to extract directly from error output (most accurate)
-	version, migrationName := extractMissingMigrationInfo(config.MIGRATION_DIR, errorOutput)
-
-	var missingVersions []int64
-	var migrationNames []string
-
-	if version > 0 {
-		fmt.Printf("🎯 Extracted from error: version=%d, name=%s\n", version, migrationName)
-		missingVersions = []int64{version}
-		migrationNames = []string{migrationName}
-	} else {
-		// Fallback to database query
-		fmt.Println("⚠️  Could not extract from error, trying database query...")
-		dbVersions, err := findMissingMigrations(config)
-		if err != nil {
-			fmt.Printf("❌ Database query also failed: %v\n", err)
-			return false
-		}
-
-		if len(dbVersions) == 0 {
-			fmt.Println("❌ No missing migrations found")
-			return false
-		}
-
-		missingVersions = dbVersions
-		// Use default names for database-sourced versions
-		migrationNames = make([]string, len(dbVersions))
-		for i := range migrationNames {
-			migrationNames[i] = "temp_migration"
+// Print a prominent summary of migrations applied below the version the
+// database was at before migrating
+// @param applied []*Migration
+// @param previousVersion int64
+func printOutOfOrderSummary(applied []*Migration, previousVersion int64) {
+	var outOfOrder []*Migration
+	for _, migration := range applied {
+		if migration.Version < previousVersion {
+			outOfOrder = append(outOfOrder, migration)
 		}
 	}
-
-	fmt.Printf("🎯 Found %d missing migration(s): %v\n", len(missingVersions), missingVersions)
-
-	// Check if migration files exist before creating temp files
-	var existingFiles []string
-	var needTempFiles []int64
-	var needTempNames []string
-
-	for i, version := range missingVersions {
-		migrationName := migrationNames[i]
-
-		// Check if actual migration file exists
-		actualFileName := fmt.Sprintf("db/migrations/%d_%s.sql", version, migrationName)
-		if _, err := os.Stat(actualFileName); err == nil {
-			existingFiles = append(existingFiles, actualFileName)
-		} else {
-			// File doesn't exist, need temp file
-			needTempFiles = append(needTempFiles, version)
-			needTempNames = append(needTempNames, migrationName)
-		}
+	if len(outOfOrder) == 0 {
+		return
 	}
 
-	// If some files exist, suggest rollback instead
-	if len(existingFiles) > 0 {
-		fmt.Printf("🚨 MIGRATION FILES ĐÃ TỒN TẠI:\n")
-		for _, file := range existingFiles {
-			fmt.Printf("   📁 %s\n", file)
-		}
-
-		// Calculate rollback count
-		rollbackCount, err := calculateRollbackCount(config, missingVersions)
-		if err != nil {
-			fmt.Printf("⚠️  Không thể tính rollback count: %v\n", err)
-			fmt.Println("💡 Gợi ý: Bạn cần ROLLBACK về version missing thay vì migrate up!")
-			fmt.Println("   Chạy: go run cmd/cmd.go rollback [count]")
-		} else {
-			fmt.Printf("💡 GỢI Ý ROLLBACK:\n")
-			fmt.Printf("   Cần rollback %d migration(s) để về version missing\n", rollbackCount)
-			fmt.Printf("   Chạy: go run cmd/cmd.go rollback %d\n", rollbackCount)
-		}
-
-		return false // Don't create temp files, user should rollback instead
+	infof("\n🚨 ================= OUT-OF-ORDER MIGRATIONS APPLIED =================\n")
+	infof("🚨 %d migration(s) older than version %d were applied:\n", len(outOfOrder), previousVersion)
+	for _, migration := range outOfOrder {
+		infof("🚨   - %s\n", migration.FileName())
 	}
+	infof("🚨 =====================================================================\n")
+}
 
-	// If no files exist, create temp files as before
-	if len(needTempFiles) == 0 {
-		fmt.Println("✅ Tất cả missing migrations đã có file")
-		return true
+// Print the migrations MigrateUp would apply without running them
+// @param ctx context.Context
+// @param migrator *Migrator
+// @param target int64 - stop at this version, 0 for latest
+// @param output string
+// @return error
+func dryRunMigrateUp(ctx context.Context, migrator *Migrator, target int64, output string) error {
+	if err := validatePlanOutput(output); err != nil {
+		return err
 	}
 
-	fmt.Printf("📝 Tạo temp files cho %d missing migration(s)...\n", len(needTempFiles))
-
-	// Create temp files for missing migrations (only if not already created)
-	createdCount := 0
-	for i, version := range needTempFiles {
-		migrationName := needTempNames[i]
-
-		// Use the actual migration name if available
-		var tempName string
-		if migrationName != "temp_migration" {
-			tempName = fmt.Sprintf("temp_%s", migrationName)
-		} else {
-			tempName = "temp_migration"
-		}
+	pending, err := migrator.planUp(ctx, target)
+	if err != nil {
+		return fmt.Errorf("migration up plan failed: %w", err)
+	}
 
-		// Check if temp file already exists
-		tempFileName := fmt.Sprintf("db/migrations/%d_%s.sql", version, tempName)
-		if _, err := os.Stat(tempFileName); err == nil {
-			fmt.Printf("⏭️  Temp file already exists: %s\n", tempFileName)
-			continue // Skip if already exists
-		}
+	return printMigrationPlan(buildMigrationPlan("up", pending), output)
+}
 
-		tempFile := createTempMigration(config.MIGRATION_DIR, version, tempName)
-		if tempFile == "" {
-			fmt.Printf("❌ Failed to create temp migration file for version %d\n", version)
-			return false
+// Handle missing migrations for migrate up operation.
+// Local migrations older than the current version cannot be applied in
+// order, so suggest rolling back to the earliest missing version instead.
+// @param ctx context.Context
+// @param migrator *Migrator
+// @param missingVersions []int64
+func handleMissingMigrationForMigrate(ctx context.Context, migrator *Migrator, missingVersions []int64) {
+	infof("🎯 Found %d missing migration(s): %v\n", len(missingVersions), missingVersions)
+
+	migrations, err := loadMigrations(migrator.dir)
+	if err == nil {
+		infof("🚨 MIGRATION FILES ĐÃ TỒN TẠI:\n")
+		for _, version := range missingVersions {
+			if migration := findMigration(migrations, version); migration != nil {
+				infof("   📁 %s\n", migration.Path)
+			}
 		}
-
-		fmt.Printf("📝 Created temp file: %s\n", tempFile)
-		createdTempFiles = append(createdTempFiles, tempFile)
-		createdCount++
 	}
 
-	if createdCount == 0 {
-		fmt.Println("⚠️  No new temp files created (all already exist)")
-		return false // Might be stuck in loop
+	// Calculate rollback count
+	rollbackCount, err := calculateRollbackCount(ctx, migrator, missingVersions)
+	if err != nil {
+		infof("⚠️  Không thể tính rollback count: %v\n", err)
+		infof("💡 Gợi ý: Bạn cần ROLLBACK về version missing thay vì migrate up!\n")
+		infof("   Chạy: migro rollback --count [count]\n")
+		return
 	}
 
-	fmt.Printf("✅ Successfully created %d temp migration files\n", createdCount)
-	return true
+	infof("💡 GỢI Ý ROLLBACK:\n")
+	infof("   Cần rollback %d migration(s) để về version missing\n", rollbackCount)
+	infof("   Chạy: migro rollback --count %d\n", rollbackCount)
+	infof("💡 Or apply them out of order: migro migrate --allow-out-of-order\n")
 }
 
 // Find missing migrations by comparing database versions with local files
-// @param config *CONFIG
+// @param ctx context.Context
+// @param migrator *Migrator
 // @return []int64, error
-func findMissingMigrations(config *CONFIG) ([]int64, error) {
-	ctx := context.Background()
-
+func findMissingMigrations(ctx context.Context, migrator *Migrator) ([]int64, error) {
 	// Get all migration versions from database
-	dbVersions, err := getDatabaseMigrationVersions(config, ctx)
+	dbVersions, err := migrator.appliedVersions(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get database versions: %w", err)
 	}
 
 	// Get all local migration file versions
-	localVersions, err := getLocalMigrationVersions(config.MIGRATION_DIR)
+	localVersions, err := getLocalMigrationVersions(migrator.dir)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get local versions: %w", err)
 	}
@@ -259,14 +203,7 @@ func findMissingMigrations(config *CONFIG) ([]int64, error) {
 	// Find versions that exist in database but not in local files
 	var missingVersions []int64
 	for _, dbVersion := range dbVersions {
-		found := false
-		for _, localVersion := range localVersions {
-			if dbVersion == localVersion {
-				found = true
-				break
-			}
-		}
-		if !found {
+		if !contains64(localVersions, dbVersion) {
 			missingVersions = append(missingVersions, dbVersion)
 		}
 	}
@@ -274,42 +211,6 @@ func findMissingMigrations(config *CONFIG) ([]int64, error) {
 	return missingVersions, nil
 }
 
-// Get migration versions from database using goose
-func getDatabaseMigrationVersions(config *CONFIG, ctx context.Context) ([]int64, error) {
-	output, err := executeGoose(config, MigrationScriptStatus)
-
-	if err != nil {
-		return nil, fmt.Errorf("goose status failed: %w\nOutput: %s", err, string(output))
-	}
-
-	return parseVersionsFromStatus(string(output)), nil
-}
-
-// Parse versions from goose status output
-// @param output string
-// @return []int64
-func parseVersionsFromStatus(output string) []int64 {
-	var versions []int64
-	lines := strings.Split(output, "\n")
-
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-		// Look for lines with timestamp and migration version
-		// Format: "2025-07-07 17:17:59 UTC -- 20250707100918_create_table.sql"
-		re := regexp.MustCompile(`(\d{4}-\d{2}-\d{2}\s+\d{2}:\d{2}:\d{2}\s+\w+\s+--\s+)?(\d{14})_.*\.sql`)
-		matches := re.FindStringSubmatch(line)
-
-		if len(matches) > 2 {
-			version, err := strconv.ParseInt(matches[2], 10, 64)
-			if err == nil {
-				versions = append(versions, version)
-			}
-		}
-	}
-
-	return versions
-}
-
 // Generate SQLC code from database
 // @param config *CONFIG
 // @return error
@@ -339,7 +240,9 @@ func GenerateSQLC(config *CONFIG) error {
 
 	// Run sqlc generate
 	fmt.Println("🔄 Generating SQLC code...")
-	cmd := exec.Command("sqlc", "generate", "-f", "sqlc.yaml")
+	ctx, cancel := commandContext(config)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, "sqlc", "generate", "-f", "sqlc.yaml")
 	cmd.Dir = config.MIGRATION_DIR
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
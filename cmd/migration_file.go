@@ -0,0 +1,538 @@
+package migroCMD
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Migration is a single versioned migration file in goose SQL format
+type Migration struct {
+	Version int64
+	Name    string
+	Path    string
+	Up      []string
+	Down    []string
+	Stored  bool // rebuilt from the history table, the file no longer exists
+
+	NoTransaction bool // run statements outside a transaction
+}
+
+// FileName returns the base name of the migration file
+func (m *Migration) FileName() string {
+	return filepath.Base(m.Path)
+}
+
+// UpChecksum returns the SHA-256 of the Up statements
+func (m *Migration) UpChecksum() string {
+	return sqlChecksum(m.Up)
+}
+
+// DownChecksum returns the SHA-256 of the Down statements
+func (m *Migration) DownChecksum() string {
+	return sqlChecksum(m.Down)
+}
+
+// Format of the checksums recorded in the history table. Format 1 split SQL
+// outside statement blocks at lines ending with ';', format 2 splits it
+// with the SQL tokenizer.
+const checksumFormat = 2
+
+// Compute the hex SHA-256 of a list of statements
+// @param statements []string
+// @return string
+func sqlChecksum(statements []string) string {
+	sum := sha256.Sum256([]byte(strings.Join(statements, "\n")))
+	return hex.EncodeToString(sum[:])
+}
+
+var migrationFileRegex = regexp.MustCompile(`^(\d+)_(.+)\.sql$`)
+
+const migrationVersionFormat = "20060102150405"
+
+// Load all migration files from a directory, sorted by version
+// @param migrationDir string
+// @return []*Migration, error
+func loadMigrations(migrationDir string) ([]*Migration, error) {
+	if migrationDir == "" {
+		return nil, fmt.Errorf("MIGRATION_DIR is not configured. Please check your migro.yaml file")
+	}
+
+	matches, err := filepath.Glob(filepath.Join(migrationDir, "[0-9]*.sql"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to glob migration files: %w", err)
+	}
+
+	var migrations []*Migration
+	seen := make(map[int64]string)
+	for _, file := range matches {
+		version, name, ok := parseMigrationFileName(file)
+		if !ok {
+			continue
+		}
+		if other, ok := seen[version]; ok {
+			return nil, fmt.Errorf("duplicate migration version %d: %s and %s", version, other, file)
+		}
+		seen[version] = file
+
+		migration, err := readMigrationFile(file)
+		if err != nil {
+			return nil, err
+		}
+		migration.Version = version
+		migration.Name = name
+		migrations = append(migrations, migration)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
+
+// Split a migration file name into version and name
+// @param filePath string
+// @return int64, string, bool (false if the name is not <version>_<name>.sql)
+func parseMigrationFileName(filePath string) (int64, string, bool) {
+	parts := migrationFileRegex.FindStringSubmatch(filepath.Base(filePath))
+	if len(parts) < 3 {
+		return 0, "", false
+	}
+	version, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return 0, "", false
+	}
+	return version, parts[2], true
+}
+
+// Write a new migration file with the given statements and load it back
+// @param migrationDir string
+// @param name string
+// @param header string - comment lines written above the Up section
+// @param content *Migration - Up, Down and NoTransaction to write
+// @return *Migration, error
+func writeMigrationFile(migrationDir, name, header string, content *Migration) (*Migration, error) {
+	filePath, err := newMigrationFilePath(migrationDir, name)
+	if err != nil {
+		return nil, err
+	}
+	return writeMigrationFileAt(filePath, header, content)
+}
+
+// Write a migration file to a path that already carries its version, and
+// load it back
+// @param filePath string
+// @param header string - comment lines written above the Up section
+// @param content *Migration - Up, Down and NoTransaction to write
+// @return *Migration, error
+func writeMigrationFileAt(filePath, header string, content *Migration) (*Migration, error) {
+	if err := os.WriteFile(filePath, []byte(renderMigrationSQL(header, content)), 0644); err != nil {
+		return nil, fmt.Errorf("failed to write migration file: %w", err)
+	}
+
+	migration, err := readMigrationFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	migration.Version, migration.Name, _ = parseMigrationFileName(filePath)
+	return migration, nil
+}
+
+// Resolve a --to target against local migration files. The target may be
+// a full version or a unique version prefix; temp placeholder files are
+// never valid targets.
+// @param migrationDir string
+// @param target string
+// @return *Migration, error
+func resolveTargetVersion(migrationDir, target string) (*Migration, error) {
+	target = strings.TrimSpace(target)
+	if _, err := strconv.ParseInt(target, 10, 64); err != nil {
+		return nil, fmt.Errorf("❌ invalid target version '%s': must be numeric", target)
+	}
+
+	migrations, err := loadMigrations(migrationDir)
+	if err != nil {
+		return nil, err
+	}
+
+	var matches []*Migration
+	for _, migration := range migrations {
+		version := strconv.FormatInt(migration.Version, 10)
+		if version == target {
+			matches = []*Migration{migration}
+			break
+		}
+		if strings.HasPrefix(version, target) {
+			matches = append(matches, migration)
+		}
+	}
+
+	if len(matches) == 0 {
+		return nil, fmt.Errorf("❌ target version %s does not exist in %s", target, migrationDir)
+	}
+	if len(matches) > 1 {
+		var files []string
+		for _, migration := range matches {
+			files = append(files, migration.FileName())
+		}
+		return nil, fmt.Errorf("❌ target version %s is ambiguous, it matches: %s", target, strings.Join(files, ", "))
+	}
+	if isTempMigration(matches[0]) {
+		return nil, fmt.Errorf("❌ target %s is a temp placeholder file, not a real migration", matches[0].FileName())
+	}
+
+	return matches[0], nil
+}
+
+// Check if a migration is a temp placeholder that older migro versions
+// created for missing files
+// @param migration *Migration
+// @return bool
+func isTempMigration(migration *Migration) bool {
+	return strings.HasPrefix(migration.Name, "temp_")
+}
+
+// Read and parse a single migration file
+// @param filePath string
+// @return *Migration, error
+func readMigrationFile(filePath string) (*Migration, error) {
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migration %s: %w", filePath, err)
+	}
+
+	migration, err := parseMigrationSQL(string(content))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse migration %s: %w", filePath, err)
+	}
+
+	migration.Path = filePath
+	return migration, nil
+}
+
+// Parse goose-style migration content into Up and Down statements.
+// Supports -- +goose Up/Down and -- +goose StatementBegin/StatementEnd;
+// outside a statement block, SQL is split into statements by the tokenizer.
+// -- +migro NoTransaction (or goose's -- +goose NO TRANSACTION) anywhere in
+// the file makes it run outside a transaction.
+// @param content string
+// @return *Migration, error (Up, Down and NoTransaction are set)
+func parseMigrationSQL(content string) (*Migration, error) {
+	return parseMigrationContent(content, func(sql string, firstLine int) ([]string, error) {
+		// Pad with the preceding lines so errors carry file line numbers
+		return splitPlainStatements(strings.Repeat("\n", firstLine) + sql)
+	})
+}
+
+// Parse migration content the way checksum format 1 did: outside a
+// statement block, a statement ends at a line ending with ';'. Only used to
+// recognise checksums recorded in that format.
+// @param content string
+// @return *Migration, error
+func parseLegacyMigrationSQL(content string) (*Migration, error) {
+	return parseMigrationContent(content, func(sql string, firstLine int) ([]string, error) {
+		return splitStatementsAtLineEnds(sql), nil
+	})
+}
+
+// Parse migration content, splitting SQL outside statement blocks with split
+// @param content string
+// @param split func(string, int) ([]string, error) - gets the SQL and the index of its first line
+// @return *Migration, error
+func parseMigrationContent(content string, split func(string, int) ([]string, error)) (*Migration, error) {
+	const (
+		sectionNone = iota
+		sectionUp
+		sectionDown
+	)
+
+	var up, down []string
+	var buffer strings.Builder
+	bufferLine := 0 // line index of the first buffered line
+	section := sectionNone
+	inBlock := false
+	foundUp := false
+	noTransaction := false
+
+	flush := func() error {
+		text := buffer.String()
+		buffer.Reset()
+
+		var statements []string
+		if inBlock {
+			if statement := strings.TrimSpace(text); !isBlankSQL(statement) {
+				statements = append(statements, statement)
+			}
+		} else {
+			var err error
+			statements, err = split(text, bufferLine)
+			if err != nil {
+				return err
+			}
+		}
+
+		if section == sectionUp {
+			up = append(up, statements...)
+		} else {
+			down = append(down, statements...)
+		}
+		return nil
+	}
+
+	lines := strings.Split(strings.ReplaceAll(content, "\r\n", "\n"), "\n")
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+
+		if strings.HasPrefix(trimmed, "--") {
+			directive := strings.TrimSpace(strings.TrimPrefix(trimmed, "--"))
+			if strings.HasPrefix(directive, "+migro") {
+				if strings.EqualFold(strings.Join(strings.Fields(strings.TrimPrefix(directive, "+migro")), ""), "notransaction") {
+					noTransaction = true
+				}
+				continue
+			}
+			if strings.HasPrefix(directive, "+goose") {
+				switch strings.ToLower(strings.Join(strings.Fields(strings.TrimPrefix(directive, "+goose")), "")) {
+				case "up":
+					if inBlock {
+						return nil, fmt.Errorf("line %d: -- +goose Up inside a statement block", i+1)
+					}
+					if err := flush(); err != nil {
+						return nil, err
+					}
+					section = sectionUp
+					foundUp = true
+				case "down":
+					if inBlock {
+						return nil, fmt.Errorf("line %d: -- +goose Down inside a statement block", i+1)
+					}
+					if err := flush(); err != nil {
+						return nil, err
+					}
+					section = sectionDown
+				case "statementbegin":
+					if section == sectionNone {
+						return nil, fmt.Errorf("line %d: StatementBegin before -- +goose Up", i+1)
+					}
+					if err := flush(); err != nil {
+						return nil, err
+					}
+					inBlock = true
+				case "statementend":
+					if !inBlock {
+						return nil, fmt.Errorf("line %d: StatementEnd without StatementBegin", i+1)
+					}
+					if err := flush(); err != nil {
+						return nil, err
+					}
+					inBlock = false
+				case "notransaction":
+					noTransaction = true
+				}
+				continue
+			}
+		}
+
+		if section == sectionNone {
+			if trimmed != "" && !strings.HasPrefix(trimmed, "--") {
+				return nil, fmt.Errorf("line %d: SQL found before -- +goose Up annotation", i+1)
+			}
+			continue
+		}
+
+		if buffer.Len() == 0 {
+			bufferLine = i
+		}
+		buffer.WriteString(line)
+		buffer.WriteString("\n")
+	}
+
+	if inBlock {
+		return nil, fmt.Errorf("missing -- +goose StatementEnd")
+	}
+	if !foundUp {
+		return nil, fmt.Errorf("missing -- +goose Up annotation")
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+
+	return &Migration{Up: up, Down: down, NoTransaction: noTransaction}, nil
+}
+
+// Render statements as goose-format migration content. Every statement
+// gets its own StatementBegin/StatementEnd block so that parsing the
+// content yields exactly the same statements.
+// @param header string - comment lines written above the Up section
+// @param migration *Migration - Up, Down and NoTransaction are rendered
+// @return string
+func renderMigrationSQL(header string, migration *Migration) string {
+	var sb strings.Builder
+	if header != "" {
+		sb.WriteString(header)
+		sb.WriteString("\n\n")
+	}
+	if migration.NoTransaction {
+		sb.WriteString("-- +migro NoTransaction\n\n")
+	}
+
+	writeSection := func(annotation string, statements []string) {
+		sb.WriteString(annotation)
+		sb.WriteString("\n")
+		for _, statement := range statements {
+			sb.WriteString("-- +goose StatementBegin\n")
+			sb.WriteString(strings.TrimSpace(statement))
+			sb.WriteString("\n-- +goose StatementEnd\n")
+		}
+	}
+
+	writeSection("-- +goose Up", migration.Up)
+	sb.WriteString("\n")
+	writeSection("-- +goose Down", migration.Down)
+	return sb.String()
+}
+
+// Split SQL outside statement blocks into statements. Each statement keeps
+// its text as written, up to its semicolon and a comment on the same line,
+// so checksums match those of files split at lines ending with ';'.
+// @param sql string
+// @return []string, error
+func splitPlainStatements(sql string) ([]string, error) {
+	parsed, err := splitSQLStatements(sql)
+	if err != nil {
+		return nil, err
+	}
+
+	statements := make([]string, len(parsed))
+	for i, statement := range parsed {
+		start := statement.Tokens[0].Start
+		end := statement.Tokens[len(statement.Tokens)-1].End
+		rest := strings.TrimLeft(sql[end:], " \t\r\n")
+		if strings.HasPrefix(rest, ";") {
+			end = len(sql) - len(rest) + 1
+			line := sql[end:]
+			if newline := strings.IndexByte(line, '\n'); newline >= 0 {
+				line = line[:newline]
+			}
+			if strings.HasPrefix(strings.TrimSpace(line), "--") {
+				end += len(line)
+			}
+		}
+		statements[i] = strings.TrimSpace(sql[start:end])
+	}
+	return statements, nil
+}
+
+// Split SQL at lines ending with ';' as checksum format 1 did. Comment
+// lines before the first line of a statement are skipped, later ones are
+// part of the statement.
+// @param sql string
+// @return []string
+func splitStatementsAtLineEnds(sql string) []string {
+	var statements []string
+	var buffer strings.Builder
+	flush := func() {
+		statement := strings.TrimSpace(buffer.String())
+		buffer.Reset()
+		if !isBlankSQL(statement) {
+			statements = append(statements, statement)
+		}
+	}
+
+	for _, line := range strings.Split(strings.TrimSuffix(sql, "\n"), "\n") {
+		if buffer.Len() == 0 && strings.HasPrefix(strings.TrimSpace(line), "--") {
+			continue
+		}
+		buffer.WriteString(line)
+		buffer.WriteString("\n")
+
+		code := line
+		if idx := strings.Index(code, "--"); idx >= 0 {
+			code = code[:idx]
+		}
+		if strings.HasSuffix(strings.TrimSpace(code), ";") {
+			flush()
+		}
+	}
+	flush()
+	return statements
+}
+
+// Check if SQL text contains only whitespace and comments
+// @param sql string
+// @return bool
+func isBlankSQL(sql string) bool {
+	for _, line := range strings.Split(sql, "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "--") {
+			return false
+		}
+	}
+	return true
+}
+
+// Build the path for a new timestamped migration file.
+// The version is moved past any existing version so two files created
+// within the same second never collide.
+// @param migrationDir string
+// @param name string
+// @return string, error
+func newMigrationFilePath(migrationDir, name string) (string, error) {
+	if migrationDir == "" {
+		return "", fmt.Errorf("MIGRATION_DIR is not configured. Please check your migro.yaml file")
+	}
+	if err := os.MkdirAll(migrationDir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create migration directory: %w", err)
+	}
+
+	versions, err := getLocalMigrationVersions(migrationDir)
+	if err != nil {
+		return "", err
+	}
+
+	version := nextMigrationVersion(time.Now(), versions)
+	fileName := filepath.Join(migrationDir, fmt.Sprintf("%d_%s.sql", version, name))
+	if _, err := os.Stat(fileName); err == nil {
+		return "", fmt.Errorf("migration file %s already exists", fileName)
+	}
+
+	return fileName, nil
+}
+
+// Pick the version for a new migration: the current UTC timestamp, moved
+// forward one second at a time while it is taken so it stays a valid
+// timestamp (20240101120059 is followed by 20240101120100, not ...120060)
+// @param now time.Time
+// @param versions []int64 - existing versions
+// @return int64
+func nextMigrationVersion(now time.Time, versions []int64) int64 {
+	now = now.UTC()
+	for {
+		version, _ := strconv.ParseInt(now.Format(migrationVersionFormat), 10, 64)
+		if !contains64(versions, version) {
+			return version
+		}
+		now = now.Add(time.Second)
+	}
+}
+
+// Helper function to check if slice contains int64
+// @param slice []int64
+// @param item int64
+// @return bool
+func contains64(slice []int64, item int64) bool {
+	for _, s := range slice {
+		if s == item {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,211 @@
+package migroCMD
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseMigrationSQL(t *testing.T) {
+	tests := []struct {
+		name              string
+		content           string
+		wantUp            []string
+		wantDown          []string
+		wantNoTransaction bool
+	}{
+		{
+			name: "statements keep their semicolon and trailing comment",
+			content: "-- header comment\n\n-- +goose Up\n-- users\nCREATE TABLE users (\n  id int -- key\n); -- table\n\nINSERT INTO users VALUES (1);\n" +
+				"-- +goose Down\nDROP TABLE users;\n",
+			wantUp:   []string{"CREATE TABLE users (\n  id int -- key\n); -- table", "INSERT INTO users VALUES (1);"},
+			wantDown: []string{"DROP TABLE users;"},
+		},
+		{
+			name:     "several statements on one line",
+			content:  "-- +goose Up\nCREATE TABLE a (id int); CREATE TABLE b (id int);\n-- +goose Down\nDROP TABLE b; DROP TABLE a\n",
+			wantUp:   []string{"CREATE TABLE a (id int);", "CREATE TABLE b (id int);"},
+			wantDown: []string{"DROP TABLE b;", "DROP TABLE a"},
+		},
+		{
+			name: "semicolons at line ends inside strings and dollar quotes",
+			content: "-- +goose Up\nCOMMENT ON TABLE a IS 'one;\ntwo';\nCREATE FUNCTION f() RETURNS int AS $$\n  SELECT 1;\n$$ LANGUAGE sql;\n" +
+				"-- +goose Down\n",
+			wantUp: []string{"COMMENT ON TABLE a IS 'one;\ntwo';", "CREATE FUNCTION f() RETURNS int AS $$\n  SELECT 1;\n$$ LANGUAGE sql;"},
+		},
+		{
+			name: "statement blocks are kept whole",
+			content: "-- +goose Up\n-- +goose StatementBegin\nSELECT 1;\nSELECT 2;\n-- +goose StatementEnd\nSELECT 3;\n" +
+				"-- +goose Down\n-- +goose StatementBegin\n-- only a comment\n-- +goose StatementEnd\n",
+			wantUp: []string{"SELECT 1;\nSELECT 2;", "SELECT 3;"},
+		},
+		{
+			name:              "no transaction directive",
+			content:           "-- +migro NoTransaction\n-- +goose Up\nCREATE INDEX CONCURRENTLY i ON a (id);\r\n-- +goose Down\nDROP INDEX i;\n",
+			wantUp:            []string{"CREATE INDEX CONCURRENTLY i ON a (id);"},
+			wantDown:          []string{"DROP INDEX i;"},
+			wantNoTransaction: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			migration, err := parseMigrationSQL(tt.content)
+			if err != nil {
+				t.Fatalf("parseMigrationSQL() error = %v", err)
+			}
+			if !reflect.DeepEqual(migration.Up, tt.wantUp) {
+				t.Errorf("Up = %q, want %q", migration.Up, tt.wantUp)
+			}
+			if !reflect.DeepEqual(migration.Down, tt.wantDown) {
+				t.Errorf("Down = %q, want %q", migration.Down, tt.wantDown)
+			}
+			if migration.NoTransaction != tt.wantNoTransaction {
+				t.Errorf("NoTransaction = %v, want %v", migration.NoTransaction, tt.wantNoTransaction)
+			}
+		})
+	}
+}
+
+func TestParseMigrationSQLErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{"missing Up", "CREATE TABLE a (id int);\n", "before -- +goose Up"},
+		{"unterminated block", "-- +goose Up\n-- +goose StatementBegin\nSELECT 1;\n", "StatementEnd"},
+		{"unterminated dollar quote reports the file line", "-- +goose Up\n\nSELECT 1;\nSELECT $$oops;\n", "line 4"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseMigrationSQL(tt.content)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("parseMigrationSQL() error = %v, want it to mention %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRenderMigrationSQLRoundTrip(t *testing.T) {
+	migration := &Migration{
+		Up:   []string{"CREATE TABLE a (id int);", "CREATE FUNCTION f() RETURNS int AS $$ SELECT 1; $$ LANGUAGE sql;"},
+		Down: []string{"DROP FUNCTION f();", "DROP TABLE a;"},
+	}
+	parsed, err := parseMigrationSQL(renderMigrationSQL("-- header", migration))
+	if err != nil {
+		t.Fatalf("parseMigrationSQL() error = %v", err)
+	}
+	if parsed.UpChecksum() != migration.UpChecksum() || parsed.DownChecksum() != migration.DownChecksum() {
+		t.Errorf("round trip changed the statements: %q / %q", parsed.Up, parsed.Down)
+	}
+}
+
+func TestResolveTargetVersion(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{
+		"20240101120000_create_users.sql",
+		"20240102120000_add_email.sql",
+		"20240102130000_add_phone.sql",
+		"20240103120000_temp_placeholder.sql",
+	} {
+		content := "-- +goose Up\nSELECT 1;\n-- +goose Down\n"
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		target  string
+		want    int64
+		wantErr string
+	}{
+		{name: "full version", target: "20240102120000", want: 20240102120000},
+		{name: "unique prefix", target: "20240101", want: 20240101120000},
+		{name: "surrounding spaces", target: " 2024010213 ", want: 20240102130000},
+		{name: "ambiguous prefix", target: "20240102", wantErr: "ambiguous"},
+		{name: "unknown version", target: "20990101000000", wantErr: "does not exist"},
+		{name: "not numeric", target: "latest", wantErr: "must be numeric"},
+		{name: "temp placeholder", target: "20240103120000", wantErr: "temp placeholder"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			migration, err := resolveTargetVersion(dir, tt.target)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("resolveTargetVersion() error = %v, want it to mention %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("resolveTargetVersion() error = %v", err)
+			}
+			if migration.Version != tt.want {
+				t.Errorf("resolveTargetVersion() = %d, want %d", migration.Version, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseLegacyMigrationSQL(t *testing.T) {
+	content := "-- +goose Up\n\n-- users\nCREATE TABLE a (id int); CREATE TABLE b (id int);\nCOMMENT ON TABLE a IS 'one;\ntwo';\n" +
+		"-- +goose StatementBegin\nSELECT 1;\n-- +goose StatementEnd\n-- +goose Down\n-- drop\nDROP TABLE b;\n"
+
+	migration, err := parseLegacyMigrationSQL(content)
+	if err != nil {
+		t.Fatalf("parseLegacyMigrationSQL() error = %v", err)
+	}
+	wantUp := []string{"-- users\nCREATE TABLE a (id int); CREATE TABLE b (id int);", "COMMENT ON TABLE a IS 'one;", "two';", "SELECT 1;"}
+	wantDown := []string{"DROP TABLE b;"}
+	if !reflect.DeepEqual(migration.Up, wantUp) {
+		t.Errorf("Up = %q, want %q", migration.Up, wantUp)
+	}
+	if !reflect.DeepEqual(migration.Down, wantDown) {
+		t.Errorf("Down = %q, want %q", migration.Down, wantDown)
+	}
+}
+
+func TestNextMigrationVersion(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 59, 0, time.UTC)
+	tests := []struct {
+		name     string
+		now      time.Time
+		versions []int64
+		want     int64
+	}{
+		{name: "no collision", now: now, want: 20240101120059},
+		{name: "unrelated versions", now: now, versions: []int64{1, 2, 20231231235959}, want: 20240101120059},
+		{name: "collision moves to the next minute", now: now, versions: []int64{20240101120059}, want: 20240101120100},
+		{
+			name:     "several collisions",
+			now:      now,
+			versions: []int64{20240101120059, 20240101120100, 20240101120101},
+			want:     20240101120102,
+		},
+		{
+			name:     "collision at the end of the year",
+			now:      time.Date(2024, 12, 31, 23, 59, 59, 0, time.UTC),
+			versions: []int64{20241231235959},
+			want:     20250101000000,
+		},
+		{
+			name: "local time is converted to UTC",
+			now:  time.Date(2024, 1, 1, 19, 0, 59, 0, time.FixedZone("UTC+7", 7*60*60)),
+			want: 20240101120059,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := nextMigrationVersion(tt.now, tt.versions); got != tt.want {
+				t.Errorf("nextMigrationVersion() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
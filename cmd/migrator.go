@@ -0,0 +1,839 @@
+package migroCMD
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const defaultMigrationTable = "goose_db_version"
+
+// Migrator applies migration files directly through the connection pool.
+// The version table layout is compatible with goose, so databases migrated
+// by goose can be managed by migro and vice versa. Checksums of applied
+// migrations, together with their SQL, are kept in a separate migro-owned
+// history table.
+type Migrator struct {
+	db         *pgxpool.Pool
+	dir        string
+	table      string
+	history    string
+	outOfOrder bool // apply migrations older than the current version instead of failing
+
+	lockTimeout      string // lock_timeout while running a migration
+	statementTimeout string // statement_timeout while running a migration
+}
+
+// AppliedMigration is a version recorded in the version table
+type AppliedMigration struct {
+	Version   int64
+	AppliedAt time.Time
+}
+
+// RecordedMigration is the copy of an applied migration kept in the history table
+type RecordedMigration struct {
+	Version   int64
+	Name      string
+	Up        []string
+	Down      []string
+	AppliedAt time.Time
+
+	NoTransaction bool
+}
+
+// HasSQL reports whether the SQL of the migration was recorded
+func (r *RecordedMigration) HasSQL() bool {
+	return r.Up != nil || r.Down != nil
+}
+
+// MigrationStatus describes the state of a single migration
+type MigrationStatus struct {
+	Version   int64
+	Name      string
+	File      string
+	AppliedAt *time.Time
+	Missing   bool
+}
+
+// MissingMigrationsError is returned when local migrations older than the
+// current database version have not been applied
+type MissingMigrationsError struct {
+	Versions       []int64
+	CurrentVersion int64
+}
+
+func (e *MissingMigrationsError) Error() string {
+	return fmt.Sprintf("found %d missing migration(s) before current version %d: %v", len(e.Versions), e.CurrentVersion, e.Versions)
+}
+
+// MissingFileError is returned when an applied version has no local file
+type MissingFileError struct {
+	Version int64
+}
+
+func (e *MissingFileError) Error() string {
+	return fmt.Sprintf("migration %d: no migration file or recorded SQL found for applied version", e.Version)
+}
+
+// NewMigrator creates a migrator for the configured migration directory
+// @param config *CONFIG
+// @param db *pgxpool.Pool
+// @return *Migrator
+func NewMigrator(config *CONFIG, db *pgxpool.Pool) *Migrator {
+	table := config.MIGRATION_TABLE
+	if table == "" {
+		table = defaultMigrationTable
+	}
+	return &Migrator{
+		db:         db,
+		dir:        config.MIGRATION_DIR,
+		table:      table,
+		history:    table + "_history",
+		outOfOrder: config.MIGRATION_ALLOW_OUT_OF_ORDER,
+
+		lockTimeout:      config.LOCK_TIMEOUT,
+		statementTimeout: config.STATEMENT_TIMEOUT,
+	}
+}
+
+// quotedTable returns the version table name quoted for use in SQL
+func (m *Migrator) quotedTable() string {
+	return pgx.Identifier(strings.Split(m.table, ".")).Sanitize()
+}
+
+// quotedHistoryTable returns the history table name quoted for use in SQL
+func (m *Migrator) quotedHistoryTable() string {
+	return pgx.Identifier(strings.Split(m.history, ".")).Sanitize()
+}
+
+// Create the version and history tables if they do not exist yet
+// @param ctx context.Context
+// @return error
+func (m *Migrator) ensureVersionTable(ctx context.Context) error {
+	_, err := m.db.Exec(ctx, fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+		version_id bigint PRIMARY KEY,
+		name text NOT NULL,
+		up_checksum text NOT NULL,
+		down_checksum text NOT NULL,
+		up_sql text[],
+		down_sql text[],
+		no_transaction boolean NOT NULL DEFAULT false,
+		checksum_format smallint NOT NULL DEFAULT 1,
+		applied_at timestamp NOT NULL DEFAULT now()
+	)`, m.quotedHistoryTable()))
+	if err != nil {
+		return fmt.Errorf("failed to create history table: %w", err)
+	}
+
+	// History tables created before SQL and the checksum format were
+	// recorded lack these columns; their checksums are in format 1
+	_, err = m.db.Exec(ctx, fmt.Sprintf(`ALTER TABLE %s
+		ADD COLUMN IF NOT EXISTS up_sql text[],
+		ADD COLUMN IF NOT EXISTS down_sql text[],
+		ADD COLUMN IF NOT EXISTS no_transaction boolean NOT NULL DEFAULT false,
+		ADD COLUMN IF NOT EXISTS checksum_format smallint NOT NULL DEFAULT 1`, m.quotedHistoryTable()))
+	if err != nil {
+		return fmt.Errorf("failed to upgrade history table: %w", err)
+	}
+
+	exists, err := m.tableExists(ctx, m.table)
+	if err != nil || exists {
+		return err
+	}
+
+	tx, err := m.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, fmt.Sprintf(`CREATE TABLE %s (
+		id serial NOT NULL,
+		version_id bigint NOT NULL,
+		is_applied boolean NOT NULL,
+		tstamp timestamp NULL DEFAULT now(),
+		PRIMARY KEY(id)
+	)`, m.quotedTable()))
+	if err != nil {
+		return fmt.Errorf("failed to create version table: %w", err)
+	}
+
+	_, err = tx.Exec(ctx, fmt.Sprintf("INSERT INTO %s (version_id, is_applied) VALUES (0, true)", m.quotedTable()))
+	if err != nil {
+		return fmt.Errorf("failed to initialize version table: %w", err)
+	}
+
+	return tx.Commit(ctx)
+}
+
+// Check whether a table exists without creating anything
+// @param ctx context.Context
+// @param name string - table or schema.table
+// @return bool, error
+func (m *Migrator) tableExists(ctx context.Context, name string) (bool, error) {
+	var exists bool
+	err := m.db.QueryRow(ctx, "SELECT to_regclass($1) IS NOT NULL", name).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("failed to check table %s: %w", name, err)
+	}
+	return exists, nil
+}
+
+// Get applied migrations from the version table, sorted by version.
+// Read-only: a missing version table means nothing was applied yet.
+// @param ctx context.Context
+// @return []AppliedMigration, error
+func (m *Migrator) appliedMigrations(ctx context.Context) ([]AppliedMigration, error) {
+	exists, err := m.tableExists(ctx, m.table)
+	if err != nil || !exists {
+		return nil, err
+	}
+
+	rows, err := m.db.Query(ctx, fmt.Sprintf("SELECT version_id, is_applied, tstamp FROM %s ORDER BY id DESC", m.quotedTable()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query version table: %w", err)
+	}
+	defer rows.Close()
+
+	// The latest row of each version decides whether it is applied
+	seen := make(map[int64]bool)
+	var applied []AppliedMigration
+	for rows.Next() {
+		var version int64
+		var isApplied bool
+		var tstamp *time.Time
+		if err := rows.Scan(&version, &isApplied, &tstamp); err != nil {
+			return nil, fmt.Errorf("failed to scan version row: %w", err)
+		}
+		if version == 0 || seen[version] {
+			continue
+		}
+		seen[version] = true
+		if isApplied {
+			record := AppliedMigration{Version: version}
+			if tstamp != nil {
+				record.AppliedAt = *tstamp
+			}
+			applied = append(applied, record)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read version table: %w", err)
+	}
+
+	sort.Slice(applied, func(i, j int) bool {
+		return applied[i].Version < applied[j].Version
+	})
+
+	return applied, nil
+}
+
+// Get applied migration versions, sorted ascending
+// @param ctx context.Context
+// @return []int64, error
+func (m *Migrator) appliedVersions(ctx context.Context) ([]int64, error) {
+	applied, err := m.appliedMigrations(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	versions := make([]int64, len(applied))
+	for i, record := range applied {
+		versions[i] = record.Version
+	}
+	return versions, nil
+}
+
+// Get the highest applied version, 0 if nothing is applied
+// @param ctx context.Context
+// @return int64, error
+func (m *Migrator) currentVersion(ctx context.Context) (int64, error) {
+	versions, err := m.appliedVersions(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if len(versions) == 0 {
+		return 0, nil
+	}
+	return versions[len(versions)-1], nil
+}
+
+// Get local migrations that have not been applied yet
+// @param ctx context.Context
+// @return []*Migration, []int64, error (pending migrations, applied versions)
+func (m *Migrator) pendingMigrations(ctx context.Context) ([]*Migration, []int64, error) {
+	migrations, err := loadMigrations(m.dir)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	applied, err := m.appliedVersions(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return filterPending(migrations, applied), applied, nil
+}
+
+// Keep the migrations whose version is not applied
+// @param migrations []*Migration
+// @param applied []int64
+// @return []*Migration
+func filterPending(migrations []*Migration, applied []int64) []*Migration {
+	var pending []*Migration
+	for _, migration := range migrations {
+		if !contains64(applied, migration.Version) {
+			pending = append(pending, migration)
+		}
+	}
+	return pending
+}
+
+// Resolve the pending migrations Up would apply, in version order.
+// A target > 0 stops at that version. Pending migrations older than the
+// current version fail with MissingMigrationsError unless out-of-order
+// mode is enabled.
+// @param ctx context.Context
+// @param target int64
+// @return []*Migration, error
+func (m *Migrator) planUp(ctx context.Context, target int64) ([]*Migration, error) {
+	pending, applied, err := m.pendingMigrations(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return planPending(pending, applied, target, m.outOfOrder)
+}
+
+// Select the pending migrations to apply, see planUp
+// @param pending []*Migration - in version order
+// @param applied []int64 - sorted ascending
+// @param target int64 - 0 for latest
+// @param outOfOrder bool
+// @return []*Migration, error
+func planPending(pending []*Migration, applied []int64, target int64, outOfOrder bool) ([]*Migration, error) {
+	var current int64
+	if len(applied) > 0 {
+		current = applied[len(applied)-1]
+	}
+
+	var missing []int64
+	for _, migration := range pending {
+		if migration.Version < current {
+			missing = append(missing, migration.Version)
+		}
+	}
+	if len(missing) > 0 && !outOfOrder {
+		return nil, &MissingMigrationsError{Versions: missing, CurrentVersion: current}
+	}
+
+	if target > 0 {
+		var upTo []*Migration
+		for _, migration := range pending {
+			if migration.Version <= target {
+				upTo = append(upTo, migration)
+			}
+		}
+		pending = upTo
+	}
+
+	return pending, nil
+}
+
+// Up applies pending migrations in version order, up to target if > 0
+// @param ctx context.Context
+// @param target int64
+// @return []*Migration, error (applied migrations)
+func (m *Migrator) Up(ctx context.Context, target int64) ([]*Migration, error) {
+	pending, err := m.planUp(ctx, target)
+	if err != nil {
+		return nil, err
+	}
+
+	var done []*Migration
+	for _, migration := range pending {
+		infof("⬆️  Applying %s\n", migration.FileName())
+		if err := m.applyUp(ctx, migration); err != nil {
+			return done, err
+		}
+		done = append(done, migration)
+	}
+
+	return done, nil
+}
+
+// Resolve the migrations that rolling back count steps would revert,
+// newest first. A count <= 0 means all applied migrations.
+// @param ctx context.Context
+// @param count int
+// @return []*Migration, error
+func (m *Migrator) planDown(ctx context.Context, count int) ([]*Migration, error) {
+	migrations, err := loadMigrations(m.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	applied, err := m.appliedVersions(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return planRollbacks(migrations, applied, count, func(version int64) (*Migration, error) {
+		return m.storedMigration(ctx, version)
+	})
+}
+
+// Select the applied migrations to revert, newest first, see planDown
+// @param migrations []*Migration - local migration files
+// @param applied []int64 - sorted ascending
+// @param count int - 0 or less for all
+// @param stored func(int64) (*Migration, error) - rebuilds a version without a file
+// @return []*Migration, error
+func planRollbacks(migrations []*Migration, applied []int64, count int, stored func(int64) (*Migration, error)) ([]*Migration, error) {
+	var plan []*Migration
+	for i := len(applied) - 1; i >= 0; i-- {
+		if count > 0 && len(plan) == count {
+			break
+		}
+		migration := findMigration(migrations, applied[i])
+		if migration == nil {
+			var err error
+			if migration, err = stored(applied[i]); err != nil {
+				return nil, err
+			}
+		}
+		plan = append(plan, migration)
+	}
+
+	return plan, nil
+}
+
+// Down reverts the most recently applied migration
+// @param ctx context.Context
+// @return *Migration, error (reverted migration, nil if nothing is applied)
+func (m *Migrator) Down(ctx context.Context) (*Migration, error) {
+	current, err := m.currentVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if current == 0 {
+		return nil, nil
+	}
+
+	migrations, err := loadMigrations(m.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	migration := findMigration(migrations, current)
+	if migration == nil {
+		if migration, err = m.storedMigration(ctx, current); err != nil {
+			return nil, err
+		}
+		infof("⚠️  %s is missing, using the Down SQL recorded when it was applied\n", migration.FileName())
+	}
+
+	infof("⬇️  Reverting %s\n", migration.FileName())
+	if err := m.applyDown(ctx, migration); err != nil {
+		return nil, err
+	}
+
+	return migration, nil
+}
+
+// Status lists local and applied migrations in version order
+// @param ctx context.Context
+// @return []MigrationStatus, error
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	migrations, err := loadMigrations(m.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	applied, err := m.appliedMigrations(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	appliedRecords := make(map[int64]AppliedMigration)
+	for _, record := range applied {
+		appliedRecords[record.Version] = record
+	}
+
+	var statuses []MigrationStatus
+	for _, migration := range migrations {
+		status := MigrationStatus{Version: migration.Version, Name: migration.Name, File: migration.FileName()}
+		if record, ok := appliedRecords[migration.Version]; ok {
+			status.AppliedAt = &record.AppliedAt
+			delete(appliedRecords, migration.Version)
+		}
+		statuses = append(statuses, status)
+	}
+
+	// Applied versions without a local file; the name comes from the history table
+	for version, record := range appliedRecords {
+		status := MigrationStatus{Version: version, AppliedAt: &record.AppliedAt, Missing: true}
+		recorded, err := m.recordedMigration(ctx, version)
+		if err != nil {
+			return nil, err
+		}
+		if recorded != nil {
+			status.Name = recorded.Name
+		}
+		statuses = append(statuses, status)
+	}
+
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Version < statuses[j].Version
+	})
+
+	return statuses, nil
+}
+
+// Run the Up statements of a migration and record its version
+// @param ctx context.Context
+// @param migration *Migration
+// @return error
+func (m *Migrator) applyUp(ctx context.Context, migration *Migration) error {
+	err := m.runMigration(ctx, migration, migration.Up, func(tx pgx.Tx) error {
+		_, err := tx.Exec(ctx, fmt.Sprintf("INSERT INTO %s (version_id, is_applied) VALUES ($1, true)", m.quotedTable()), migration.Version)
+		if err != nil {
+			return fmt.Errorf("failed to record version %d: %w", migration.Version, err)
+		}
+		return m.recordHistory(ctx, tx, migration)
+	})
+	if err != nil {
+		return fmt.Errorf("migration %s failed: %w", migration.FileName(), err)
+	}
+	return nil
+}
+
+// Run the Down statements of a migration and remove its version
+// @param ctx context.Context
+// @param migration *Migration
+// @return error
+func (m *Migrator) applyDown(ctx context.Context, migration *Migration) error {
+	err := m.runMigration(ctx, migration, migration.Down, func(tx pgx.Tx) error {
+		_, err := tx.Exec(ctx, fmt.Sprintf("DELETE FROM %s WHERE version_id = $1", m.quotedTable()), migration.Version)
+		if err != nil {
+			return fmt.Errorf("failed to remove version %d: %w", migration.Version, err)
+		}
+
+		_, err = tx.Exec(ctx, fmt.Sprintf("DELETE FROM %s WHERE version_id = $1", m.quotedHistoryTable()), migration.Version)
+		if err != nil {
+			return fmt.Errorf("failed to remove history of version %d: %w", migration.Version, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("rollback of %s failed: %w", migration.FileName(), err)
+	}
+	return nil
+}
+
+// Run statements of a migration and update the version bookkeeping on a
+// single connection. Statements and bookkeeping share one transaction so a
+// failed statement never leaves a half-applied file, unless the migration
+// is marked NoTransaction; then statements run one by one in autocommit
+// mode and only the bookkeeping is transactional. LOCK_TIMEOUT and
+// STATEMENT_TIMEOUT apply to every statement of the migration.
+// @param ctx context.Context
+// @param migration *Migration
+// @param statements []string
+// @param bookkeeping func(pgx.Tx) error
+// @return error
+func (m *Migrator) runMigration(ctx context.Context, migration *Migration, statements []string, bookkeeping func(pgx.Tx) error) error {
+	conn, err := m.db.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to acquire connection: %w", err)
+	}
+	defer conn.Release()
+
+	if migration.NoTransaction {
+		infof("   ⚠️  Running outside a transaction (NoTransaction)\n")
+		if err := m.applyTimeouts(ctx, conn, false); err != nil {
+			return err
+		}
+		// The connection goes back to the pool, so undo the session settings,
+		// including a check_function_bodies left off by a failed statement
+		defer conn.Exec(context.Background(), "RESET lock_timeout; RESET statement_timeout; RESET check_function_bodies")
+
+		if err := execStatements(ctx, conn, statements); err != nil {
+			return fmt.Errorf("%w\n⚠️  Statements before the failing one were NOT rolled back (NoTransaction)", err)
+		}
+	}
+
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err := m.applyTimeouts(ctx, tx, true); err != nil {
+		return err
+	}
+
+	if !migration.NoTransaction {
+		if err := execStatements(ctx, tx, statements); err != nil {
+			return err
+		}
+	}
+
+	if err := bookkeeping(tx); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit: %w", err)
+	}
+	return nil
+}
+
+// Set lock_timeout and statement_timeout from the config. local limits the
+// settings to the current transaction, like SET LOCAL.
+// @param ctx context.Context
+// @param executor sqlExecutor
+// @param local bool
+// @return error
+func (m *Migrator) applyTimeouts(ctx context.Context, executor sqlExecutor, local bool) error {
+	settings := []struct{ name, key, value string }{
+		{"lock_timeout", "LOCK_TIMEOUT", m.lockTimeout},
+		{"statement_timeout", "STATEMENT_TIMEOUT", m.statementTimeout},
+	}
+	for _, setting := range settings {
+		if setting.value == "" {
+			continue
+		}
+		if _, err := executor.Exec(ctx, "SELECT set_config($1, $2, $3)", setting.name, setting.value, local); err != nil {
+			return fmt.Errorf("invalid %s '%s': %w", setting.key, setting.value, err)
+		}
+	}
+	return nil
+}
+
+// Record the checksums and SQL of an applied migration in the history table
+// @param ctx context.Context
+// @param tx pgx.Tx
+// @param migration *Migration
+// @return error
+func (m *Migrator) recordHistory(ctx context.Context, tx pgx.Tx, migration *Migration) error {
+	up, down := migration.Up, migration.Down
+	if up == nil {
+		up = []string{}
+	}
+	if down == nil {
+		down = []string{}
+	}
+
+	_, err := tx.Exec(ctx, fmt.Sprintf(`INSERT INTO %s (version_id, name, up_checksum, down_checksum, up_sql, down_sql, no_transaction, checksum_format)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (version_id) DO UPDATE SET
+			name = EXCLUDED.name,
+			up_checksum = EXCLUDED.up_checksum,
+			down_checksum = EXCLUDED.down_checksum,
+			up_sql = EXCLUDED.up_sql,
+			down_sql = EXCLUDED.down_sql,
+			no_transaction = EXCLUDED.no_transaction,
+			checksum_format = EXCLUDED.checksum_format`, m.quotedHistoryTable()),
+		migration.Version, migration.Name, migration.UpChecksum(), migration.DownChecksum(), up, down, migration.NoTransaction, checksumFormat)
+	if err != nil {
+		return fmt.Errorf("failed to record history of version %d: %w", migration.Version, err)
+	}
+	return nil
+}
+
+// Get the recorded copy of an applied migration, nil if it has no history row.
+// Read-only: works on missing history tables and on ones without SQL columns.
+// @param ctx context.Context
+// @param version int64
+// @return *RecordedMigration, error
+func (m *Migrator) recordedMigration(ctx context.Context, version int64) (*RecordedMigration, error) {
+	var hasHistory, hasSQL bool
+	err := m.db.QueryRow(ctx, `SELECT to_regclass($1) IS NOT NULL, EXISTS (
+		SELECT 1 FROM pg_attribute
+		WHERE attrelid = to_regclass($1) AND attname = 'no_transaction' AND NOT attisdropped
+	)`, m.history).Scan(&hasHistory, &hasSQL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check history table: %w", err)
+	}
+	if !hasHistory {
+		return nil, nil
+	}
+
+	// History tables created before SQL was recorded lack these columns
+	columns := "up_sql, down_sql, no_transaction"
+	if !hasSQL {
+		columns = "NULL::text[], NULL::text[], false"
+	}
+
+	record := &RecordedMigration{Version: version}
+	err = m.db.QueryRow(ctx, fmt.Sprintf("SELECT name, %s, applied_at FROM %s WHERE version_id = $1", columns, m.quotedHistoryTable()), version).
+		Scan(&record.Name, &record.Up, &record.Down, &record.NoTransaction, &record.AppliedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read history of version %d: %w", version, err)
+	}
+	return record, nil
+}
+
+// Rebuild an applied migration whose file is missing from its recorded SQL.
+// Returns a MissingFileError when no SQL was recorded for the version.
+// @param ctx context.Context
+// @param version int64
+// @return *Migration, error
+func (m *Migrator) storedMigration(ctx context.Context, version int64) (*Migration, error) {
+	recorded, err := m.recordedMigration(ctx, version)
+	if err != nil {
+		return nil, err
+	}
+	if recorded == nil || !recorded.HasSQL() {
+		return nil, &MissingFileError{Version: version}
+	}
+
+	return &Migration{
+		Version: version,
+		Name:    recorded.Name,
+		Path:    filepath.Join(m.dir, fmt.Sprintf("%d_%s.sql", version, recorded.Name)),
+		Up:      recorded.Up,
+		Down:    recorded.Down,
+		Stored:  true,
+
+		NoTransaction: recorded.NoTransaction,
+	}, nil
+}
+
+// Record a migration as applied without running its statements
+// @param ctx context.Context
+// @param migration *Migration
+// @return error
+func (m *Migrator) markApplied(ctx context.Context, migration *Migration) error {
+	if err := m.ensureVersionTable(ctx); err != nil {
+		return err
+	}
+
+	tx, err := m.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, fmt.Sprintf("INSERT INTO %s (version_id, is_applied) VALUES ($1, true)", m.quotedTable()), migration.Version)
+	if err != nil {
+		return fmt.Errorf("failed to record version %d: %w", migration.Version, err)
+	}
+	if err := m.recordHistory(ctx, tx, migration); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// Get the schema-qualified names of the version and history tables, so
+// schema introspection can leave them out
+// @param ctx context.Context
+// @return []string, error
+func (m *Migrator) ownTables(ctx context.Context) ([]string, error) {
+	var tables []string
+	for _, name := range []string{m.table, m.history} {
+		var qualified *string
+		err := m.db.QueryRow(ctx, `SELECT n.nspname || '.' || c.relname
+			FROM pg_class c JOIN pg_namespace n ON n.oid = c.relnamespace
+			WHERE c.oid = to_regclass($1)`, name).Scan(&qualified)
+		if errors.Is(err, pgx.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve table %s: %w", name, err)
+		}
+		if qualified != nil {
+			tables = append(tables, *qualified)
+		}
+	}
+	return tables, nil
+}
+
+// Remove an applied version from the version and history tables without
+// running any SQL
+// @param ctx context.Context
+// @param version int64
+// @return error
+func (m *Migrator) forgetVersion(ctx context.Context, version int64) error {
+	tx, err := m.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, fmt.Sprintf("DELETE FROM %s WHERE version_id = $1", m.quotedTable()), version)
+	if err != nil {
+		return fmt.Errorf("failed to remove version %d: %w", version, err)
+	}
+
+	_, err = tx.Exec(ctx, fmt.Sprintf("DELETE FROM %s WHERE version_id = $1", m.quotedHistoryTable()), version)
+	if err != nil {
+		return fmt.Errorf("failed to remove history of version %d: %w", version, err)
+	}
+
+	return tx.Commit(ctx)
+}
+
+// sqlExecutor runs SQL; satisfied by pgx.Tx and *pgxpool.Conn
+type sqlExecutor interface {
+	Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error)
+}
+
+// Execute statements one by one, reporting the failing statement
+// @param ctx context.Context
+// @param executor sqlExecutor
+// @param statements []string
+// @return error
+func execStatements(ctx context.Context, executor sqlExecutor, statements []string) error {
+	for _, statement := range statements {
+		if _, err := executor.Exec(ctx, statement); err != nil {
+			return fmt.Errorf("%w\nStatement:\n%s%s", err, statement, timeoutHint(err))
+		}
+	}
+	return nil
+}
+
+// Explain which setting stopped a statement that timed out
+// @param err error
+// @return string - empty if err is not a timeout
+func timeoutHint(err error) string {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return "\n💡 The command exceeded TIMEOUT_SECONDS"
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch pgErr.Code {
+		case "55P03": // lock_not_available
+			return "\n💡 Gave up waiting for a table lock (LOCK_TIMEOUT); retry when traffic on the table is lower"
+		case "57014": // query_canceled
+			return "\n💡 The statement ran longer than STATEMENT_TIMEOUT"
+		}
+	}
+	return ""
+}
+
+// Find a migration by version
+// @param migrations []*Migration
+// @param version int64
+// @return *Migration
+func findMigration(migrations []*Migration, version int64) *Migration {
+	for _, migration := range migrations {
+		if migration.Version == version {
+			return migration
+		}
+	}
+	return nil
+}
@@ -0,0 +1,196 @@
+package migroCMD
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// Build migrations with the given versions
+func testMigrations(versions ...int64) []*Migration {
+	migrations := make([]*Migration, len(versions))
+	for i, version := range versions {
+		migrations[i] = &Migration{Version: version, Name: "m"}
+	}
+	return migrations
+}
+
+// Versions of a list of migrations
+func migrationVersions(migrations []*Migration) []int64 {
+	var versions []int64
+	for _, migration := range migrations {
+		versions = append(versions, migration.Version)
+	}
+	return versions
+}
+
+func TestPlanPending(t *testing.T) {
+	tests := []struct {
+		name        string
+		local       []int64
+		applied     []int64
+		target      int64
+		outOfOrder  bool
+		want        []int64
+		wantMissing []int64
+	}{
+		{
+			name:  "nothing applied",
+			local: []int64{1, 2, 3},
+			want:  []int64{1, 2, 3},
+		},
+		{
+			name:    "pending after the current version in version order",
+			local:   []int64{1, 2, 3, 4},
+			applied: []int64{1, 2},
+			want:    []int64{3, 4},
+		},
+		{
+			name:    "up to date",
+			local:   []int64{1, 2},
+			applied: []int64{1, 2},
+		},
+		{
+			name:    "stops at the target",
+			local:   []int64{1, 2, 3, 4},
+			applied: []int64{1},
+			target:  3,
+			want:    []int64{2, 3},
+		},
+		{
+			name:        "older pending migration fails without out-of-order mode",
+			local:       []int64{1, 2, 3, 4},
+			applied:     []int64{1, 3},
+			wantMissing: []int64{2},
+		},
+		{
+			name:       "older pending migration is applied in out-of-order mode",
+			local:      []int64{1, 2, 3, 4},
+			applied:    []int64{1, 3},
+			outOfOrder: true,
+			want:       []int64{2, 4},
+		},
+		{
+			name:       "out-of-order mode respects the target",
+			local:      []int64{1, 2, 3, 4, 5},
+			applied:    []int64{3},
+			target:     4,
+			outOfOrder: true,
+			want:       []int64{1, 2, 4},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pending := filterPending(testMigrations(tt.local...), tt.applied)
+			plan, err := planPending(pending, tt.applied, tt.target, tt.outOfOrder)
+			if tt.wantMissing != nil {
+				var missingErr *MissingMigrationsError
+				if !errors.As(err, &missingErr) {
+					t.Fatalf("planPending() error = %v, want MissingMigrationsError", err)
+				}
+				if !reflect.DeepEqual(missingErr.Versions, tt.wantMissing) {
+					t.Errorf("missing versions = %v, want %v", missingErr.Versions, tt.wantMissing)
+				}
+				if missingErr.CurrentVersion != tt.applied[len(tt.applied)-1] {
+					t.Errorf("current version = %d, want %d", missingErr.CurrentVersion, tt.applied[len(tt.applied)-1])
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("planPending() error = %v", err)
+			}
+			if got := migrationVersions(plan); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("planPending() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPlanRollbacks(t *testing.T) {
+	tests := []struct {
+		name       string
+		local      []int64
+		applied    []int64
+		count      int
+		stored     []int64 // versions with recorded SQL but no file
+		want       []int64
+		wantStored []int64
+		wantErr    bool
+	}{
+		{
+			name:    "newest first",
+			local:   []int64{1, 2, 3},
+			applied: []int64{1, 2, 3},
+			count:   2,
+			want:    []int64{3, 2},
+		},
+		{
+			name:    "count above the applied migrations",
+			local:   []int64{1, 2, 3},
+			applied: []int64{1, 2},
+			count:   5,
+			want:    []int64{2, 1},
+		},
+		{
+			name:    "zero count means all",
+			local:   []int64{1, 2, 3},
+			applied: []int64{1, 2, 3},
+			want:    []int64{3, 2, 1},
+		},
+		{
+			name:    "out-of-order applied versions",
+			local:   []int64{1, 2, 3},
+			applied: []int64{1, 3},
+			count:   2,
+			want:    []int64{3, 1},
+		},
+		{
+			name:       "missing file uses the recorded SQL",
+			local:      []int64{1, 3},
+			applied:    []int64{1, 2, 3},
+			stored:     []int64{2},
+			count:      2,
+			want:       []int64{3, 2},
+			wantStored: []int64{2},
+		},
+		{
+			name:    "missing file without recorded SQL",
+			local:   []int64{1},
+			applied: []int64{1, 2},
+			count:   1,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var usedStored []int64
+			stored := func(version int64) (*Migration, error) {
+				if !contains64(tt.stored, version) {
+					return nil, &MissingFileError{Version: version}
+				}
+				usedStored = append(usedStored, version)
+				return &Migration{Version: version, Stored: true}, nil
+			}
+
+			plan, err := planRollbacks(testMigrations(tt.local...), tt.applied, tt.count, stored)
+			if tt.wantErr {
+				var missingErr *MissingFileError
+				if !errors.As(err, &missingErr) {
+					t.Fatalf("planRollbacks() error = %v, want MissingFileError", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("planRollbacks() error = %v", err)
+			}
+			if got := migrationVersions(plan); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("planRollbacks() = %v, want %v", got, tt.want)
+			}
+			if !reflect.DeepEqual(usedStored, tt.wantStored) {
+				t.Errorf("recorded SQL used for %v, want %v", usedStored, tt.wantStored)
+			}
+		})
+	}
+}
@@ -0,0 +1,294 @@
+package migroCMD
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/jackc/pgx/v5"
+	"gopkg.in/yaml.v3"
+)
+
+// Output formats selected with --output or OUTPUT_FORMAT
+const (
+	OutputTable = "table"
+	OutputJSON  = "json"
+	OutputYAML  = "yaml"
+	OutputCSV   = "csv"
+)
+
+// ValidateOutputFormat checks the --output value
+// @param format string
+// @return error
+func ValidateOutputFormat(format string) error {
+	switch format {
+	case OutputTable, OutputJSON, OutputYAML, OutputCSV:
+		return nil
+	default:
+		return fmt.Errorf("❌ unsupported output format '%s' (expected table, json, yaml or csv)", format)
+	}
+}
+
+// Record is one row of structured output. Unlike a map it keeps the
+// column order when encoded as json or yaml.
+type Record struct {
+	Columns []string
+	Values  []interface{}
+}
+
+// MarshalJSON encodes the record as an object in column order
+func (r Record) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, column := range r.Columns {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, err := json.Marshal(column)
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(r.Values[i])
+		if err != nil {
+			return nil, fmt.Errorf("column %s: %w", column, err)
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// MarshalYAML encodes the record as a mapping in column order
+func (r Record) MarshalYAML() (interface{}, error) {
+	node := &yaml.Node{Kind: yaml.MappingNode}
+	for i, column := range r.Columns {
+		value := &yaml.Node{}
+		if err := value.Encode(r.Values[i]); err != nil {
+			return nil, fmt.Errorf("column %s: %w", column, err)
+		}
+		node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: column}, value)
+	}
+	return node, nil
+}
+
+// Print human decoration such as progress and emoji messages to stderr,
+// so stdout only carries the command's data
+// @param format string
+// @param args ...interface{}
+func infof(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, format, args...)
+}
+
+// Print rows in the given output format
+// @param format string - table, json, yaml or csv
+// @param columns []string
+// @param rows [][]interface{}
+// @return error
+func printRecords(format string, columns []string, rows [][]interface{}) error {
+	records := make([]Record, len(rows))
+	for i, row := range rows {
+		records[i] = Record{Columns: columns, Values: row}
+	}
+
+	switch format {
+	case OutputJSON, OutputYAML:
+		return printDocument(format, records)
+	case OutputCSV:
+		writer := csv.NewWriter(os.Stdout)
+		if err := writer.Write(columns); err != nil {
+			return err
+		}
+		for _, row := range rows {
+			line := make([]string, len(row))
+			for i, value := range row {
+				if value != nil {
+					line[i] = formatValue(value)
+				}
+			}
+			if err := writer.Write(line); err != nil {
+				return err
+			}
+		}
+		writer.Flush()
+		return writer.Error()
+	case OutputTable, "":
+		printTable(columns, rows)
+		return nil
+	default:
+		return ValidateOutputFormat(format)
+	}
+}
+
+// Print a value as an indented json or yaml document
+// @param format string - json or yaml
+// @param value interface{}
+// @return error
+func printDocument(format string, value interface{}) error {
+	if format == OutputYAML {
+		encoder := yaml.NewEncoder(os.Stdout)
+		encoder.SetIndent(2)
+		if err := encoder.Encode(value); err != nil {
+			return err
+		}
+		return encoder.Close()
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(value)
+}
+
+// Print rows as an aligned text table without truncating values
+// @param columns []string
+// @param rows [][]interface{}
+func printTable(columns []string, rows [][]interface{}) {
+	widths := make([]int, len(columns))
+	for i, column := range columns {
+		widths[i] = utf8.RuneCountInString(column)
+	}
+	cells := make([][]string, len(rows))
+	for r, row := range rows {
+		cells[r] = make([]string, len(row))
+		for i, value := range row {
+			cells[r][i] = formatValue(value)
+			if width := utf8.RuneCountInString(cells[r][i]); width > widths[i] {
+				widths[i] = width
+			}
+		}
+	}
+
+	printRow := func(values []string) {
+		for i, value := range values {
+			if i > 0 {
+				fmt.Print(" | ")
+			}
+			if i == len(values)-1 {
+				fmt.Print(value)
+				continue
+			}
+			fmt.Print(value + strings.Repeat(" ", widths[i]-utf8.RuneCountInString(value)))
+		}
+		fmt.Println()
+	}
+
+	printRow(columns)
+	separator := make([]string, len(columns))
+	for i := range columns {
+		separator[i] = strings.Repeat("-", widths[i])
+	}
+	fmt.Println(strings.Join(separator, "-+-"))
+	for _, row := range cells {
+		printRow(row)
+	}
+}
+
+// Read all rows of a query result with values converted for output
+// @param rows pgx.Rows
+// @return []string, [][]interface{}, error (columns, rows)
+func collectRows(rows pgx.Rows) ([]string, [][]interface{}, error) {
+	defer rows.Close()
+
+	fields := rows.FieldDescriptions()
+	columns := make([]string, len(fields))
+	for i, field := range fields {
+		columns[i] = field.Name
+	}
+
+	result := [][]interface{}{}
+	for rows.Next() {
+		values, err := rows.Values()
+		if err != nil {
+			return nil, nil, fmt.Errorf("error scanning row: %w", err)
+		}
+		for i, value := range values {
+			values[i] = normalizeValue(value)
+		}
+		result = append(result, values)
+	}
+	return columns, result, rows.Err()
+}
+
+// Convert a value returned by pgx into one that encodes naturally as
+// json, yaml and text: uuids as strings, numerics without losing precision
+// @param value interface{}
+// @return interface{}
+func normalizeValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case nil, string, bool, int, int8, int16, int32, int64, uint8, uint16, uint32, uint64, time.Time:
+		return v
+	case float32:
+		return normalizeValue(float64(v))
+	case float64:
+		// json has no NaN or Infinity
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return strconv.FormatFloat(v, 'f', -1, 64)
+		}
+		return v
+	case [16]byte:
+		return fmt.Sprintf("%x-%x-%x-%x-%x", v[0:4], v[4:6], v[6:8], v[8:10], v[10:16])
+	case []byte:
+		if utf8.Valid(v) {
+			return string(v)
+		}
+		return fmt.Sprintf("\\x%x", v)
+	case []interface{}:
+		for i := range v {
+			v[i] = normalizeValue(v[i])
+		}
+		return v
+	case map[string]interface{}:
+		for key := range v {
+			v[key] = normalizeValue(v[key])
+		}
+		return v
+	case driver.Valuer:
+		// pgtype values such as Numeric and Interval
+		converted, err := v.Value()
+		if err != nil {
+			return fmt.Sprintf("%v", v)
+		}
+		return normalizeValue(converted)
+	case fmt.Stringer:
+		return v.String()
+	default:
+		return v
+	}
+}
+
+// formatValue formats a value for table and csv output
+func formatValue(value interface{}) string {
+	if value == nil {
+		return "<NULL>"
+	}
+
+	switch v := value.(type) {
+	case string:
+		return v
+	case int, int8, int16, int32, int64, uint8, uint16, uint32, uint64:
+		return fmt.Sprintf("%d", v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	case time.Time:
+		return v.Format("2006-01-02 15:04:05.999999Z07:00")
+	case []interface{}, map[string]interface{}:
+		encoded, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprintf("%v", v)
+		}
+		return string(encoded)
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
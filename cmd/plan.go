@@ -0,0 +1,133 @@
+package migroCMD
+
+import (
+	"fmt"
+	"strings"
+)
+
+// MigrateOptions controls how migrate and rollback commands run
+type MigrateOptions struct {
+	DryRun bool   // print the plan without touching the database
+	Output string // plan output format: table (text), json, yaml or csv
+	Target string // migrate up to / rollback down to this version
+
+	AllowOutOfOrder bool // apply unapplied migrations older than the current version
+}
+
+// PlanStep is a single migration a dry run would execute
+type PlanStep struct {
+	Version    int64    `json:"version" yaml:"version"`
+	Name       string   `json:"name" yaml:"name"`
+	File       string   `json:"file" yaml:"file"`
+	Statements []string `json:"statements" yaml:"statements"`
+	Stored     bool     `json:"stored,omitempty" yaml:"stored,omitempty"` // SQL comes from the history table
+
+	NoTransaction bool `json:"no_transaction,omitempty" yaml:"no_transaction,omitempty"`
+}
+
+// MigrationPlan is the ordered list of migrations a command would execute
+type MigrationPlan struct {
+	Direction string     `json:"direction" yaml:"direction"`
+	Steps     []PlanStep `json:"steps" yaml:"steps"`
+}
+
+// Validate the plan output format
+// @param output string
+// @return error
+func validatePlanOutput(output string) error {
+	switch output {
+	case "", "text", OutputTable, OutputJSON, OutputYAML, OutputCSV:
+		return nil
+	default:
+		return fmt.Errorf("❌ unsupported dry run output format '%s' (expected table, json, yaml or csv)", output)
+	}
+}
+
+// Build a plan from migrations in execution order
+// @param direction string - "up" or "down"
+// @param migrations []*Migration
+// @return *MigrationPlan
+func buildMigrationPlan(direction string, migrations []*Migration) *MigrationPlan {
+	plan := &MigrationPlan{Direction: direction, Steps: []PlanStep{}}
+	for _, migration := range migrations {
+		statements := migration.Up
+		if direction == "down" {
+			statements = migration.Down
+		}
+		if statements == nil {
+			statements = []string{}
+		}
+		plan.Steps = append(plan.Steps, PlanStep{
+			Version:    migration.Version,
+			Name:       migration.Name,
+			File:       migration.Path,
+			Statements: statements,
+			Stored:     migration.Stored,
+
+			NoTransaction: migration.NoTransaction,
+		})
+	}
+	return plan
+}
+
+// Print a migration plan as text, json, yaml or csv
+// @param plan *MigrationPlan
+// @param output string
+// @return error
+func printMigrationPlan(plan *MigrationPlan, output string) error {
+	if output == OutputJSON || output == OutputYAML {
+		return printDocument(output, plan)
+	}
+	if output == OutputCSV {
+		return printRecords(OutputCSV, planColumns, planRows(plan))
+	}
+
+	verb, pastVerb := "apply", "applied"
+	if plan.Direction == "down" {
+		verb, pastVerb = "revert", "reverted"
+	}
+
+	if len(plan.Steps) == 0 {
+		fmt.Printf("📭 Dry run: no migrations to %s\n", verb)
+		return nil
+	}
+
+	fmt.Printf("📝 Dry run: %d migration(s) would be %s (database not modified)\n", len(plan.Steps), pastVerb)
+	for i, step := range plan.Steps {
+		source := plan.Direction
+		if step.Stored {
+			source += ", stored copy: file is missing"
+		}
+		if step.NoTransaction {
+			source += ", no transaction"
+		}
+		fmt.Printf("\n-- [%d/%d] %s (%s)\n", i+1, len(plan.Steps), step.File, source)
+		if len(step.Statements) == 0 {
+			fmt.Println("-- (no statements)")
+		}
+		for _, statement := range step.Statements {
+			fmt.Println(strings.TrimSpace(statement))
+		}
+	}
+	return nil
+}
+
+// Columns of a plan printed as csv
+var planColumns = []string{"version", "file", "direction", "statement"}
+
+// Flatten a plan into one row per statement, in execution order. A
+// migration without statements gets one row with an empty statement.
+// @param plan *MigrationPlan
+// @return [][]interface{}
+func planRows(plan *MigrationPlan) [][]interface{} {
+	var rows [][]interface{}
+	for _, step := range plan.Steps {
+		if len(step.Statements) == 0 {
+			rows = append(rows, []interface{}{step.Version, step.File, plan.Direction, ""})
+		}
+		for _, statement := range step.Statements {
+			rows = append(rows, []interface{}{step.Version, step.File, plan.Direction, strings.TrimSpace(statement)})
+		}
+	}
+	return rows
+}
@@ -0,0 +1,33 @@
+package migroCMD
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestValidatePlanOutput(t *testing.T) {
+	for _, output := range []string{"", "text", OutputTable, OutputJSON, OutputYAML, OutputCSV} {
+		if err := validatePlanOutput(output); err != nil {
+			t.Errorf("validatePlanOutput(%q) error = %v", output, err)
+		}
+	}
+	if err := validatePlanOutput("xml"); err == nil {
+		t.Error("validatePlanOutput(\"xml\"): want an error")
+	}
+}
+
+func TestPlanRows(t *testing.T) {
+	plan := buildMigrationPlan("down", []*Migration{
+		{Version: 2, Path: "migrations/2_b.sql", Down: []string{"DROP TABLE b;", "  DROP TYPE s;\n"}},
+		{Version: 1, Path: "migrations/1_a.sql"},
+	})
+
+	want := [][]interface{}{
+		{int64(2), "migrations/2_b.sql", "down", "DROP TABLE b;"},
+		{int64(2), "migrations/2_b.sql", "down", "DROP TYPE s;"},
+		{int64(1), "migrations/1_a.sql", "down", ""},
+	}
+	if got := planRows(plan); !reflect.DeepEqual(got, want) {
+		t.Errorf("planRows() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,252 @@
+package migroCMD
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Reconcile actions for an applied version whose file is missing
+const (
+	reconcileMarkRemoved = "mark-removed"
+	reconcileRestore     = "restore"
+)
+
+// reconcileDecision is the action chosen for one missing version
+type reconcileDecision struct {
+	Version  int64
+	Action   string
+	Recorded *RecordedMigration
+}
+
+// Reconcile resolves versions that are applied in the database but have no
+// local migration file. For every version the user chooses to mark it as
+// removed, restore the file from the recorded SQL, or abort. Nothing is
+// changed until a decision was made for every version.
+// @param config *CONFIG
+// @param db *pgxpool.Pool
+// @return error
+func Reconcile(config *CONFIG, db *pgxpool.Pool) error {
+	ctx, cancel := commandContext(config)
+	defer cancel()
+	infof("🔍 Looking for applied migrations without a local file...\n")
+
+	// Make sure no other process is migrating this database
+	unlock, err := acquireMigrationLock(ctx, db, config)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	migrator := NewMigrator(config, db)
+	if err := migrator.ensureVersionTable(ctx); err != nil {
+		return fmt.Errorf("❌ reconcile failed: %w", err)
+	}
+	reader := bufio.NewReader(os.Stdin)
+
+	// Temp files are only removed once every decision was made
+	placeholders, err := confirmLeftoverTempFiles(migrator.dir, reader)
+	if err != nil {
+		return err
+	}
+
+	missingVersions, err := findMissingMigrations(ctx, migrator)
+	if err != nil {
+		return fmt.Errorf("❌ reconcile failed: %w", err)
+	}
+	missingVersions, err = addHiddenVersions(ctx, migrator, missingVersions, placeholders)
+	if err != nil {
+		return fmt.Errorf("❌ reconcile failed: %w", err)
+	}
+	if len(missingVersions) == 0 {
+		if err := removeTempFiles(placeholders); err != nil {
+			return err
+		}
+		infof("✅ Every applied migration has a local file, nothing to reconcile\n")
+		return nil
+	}
+
+	infof("🎯 Found %d applied migration(s) without a local file: %v\n", len(missingVersions), missingVersions)
+
+	var decisions []reconcileDecision
+	for _, version := range missingVersions {
+		recorded, err := migrator.recordedMigration(ctx, version)
+		if err != nil {
+			return fmt.Errorf("❌ reconcile failed: %w", err)
+		}
+
+		action, err := promptReconcileAction(reader, version, recorded)
+		if err != nil {
+			return err
+		}
+		decisions = append(decisions, reconcileDecision{Version: version, Action: action, Recorded: recorded})
+	}
+
+	if err := removeTempFiles(placeholders); err != nil {
+		return err
+	}
+
+	for _, decision := range decisions {
+		switch decision.Action {
+		case reconcileMarkRemoved:
+			if err := migrator.forgetVersion(ctx, decision.Version); err != nil {
+				return fmt.Errorf("❌ failed to mark version %d as removed: %w", decision.Version, err)
+			}
+			infof("🗑️  Marked version %d as removed (no SQL was run)\n", decision.Version)
+		case reconcileRestore:
+			filePath, err := restoreMigrationFile(migrator.dir, decision.Recorded)
+			if err != nil {
+				return fmt.Errorf("❌ failed to restore version %d: %w", decision.Version, err)
+			}
+			infof("📝 Restored %s from the recorded SQL\n", filePath)
+		}
+	}
+
+	infof("✅ Reconcile completed\n")
+	return showMigrationStatus(ctx, migrator, OutputTable)
+}
+
+// Ask what to do with an applied version whose file is missing
+// @param reader *bufio.Reader
+// @param version int64
+// @param recorded *RecordedMigration - nil if the version has no history row
+// @return string, error (chosen action, error if aborted)
+func promptReconcileAction(reader *bufio.Reader, version int64, recorded *RecordedMigration) (string, error) {
+	canRestore := recorded != nil && recorded.HasSQL()
+
+	infof("\n📌 Version %d", version)
+	if recorded != nil {
+		infof(" (%s, applied at %s)", recorded.Name, recorded.AppliedAt.UTC().Format("2006-01-02 15:04:05 UTC"))
+	}
+	infof("\n")
+	if !canRestore {
+		infof("   ⚠️  No SQL recorded for this version, it cannot be restored\n")
+	}
+
+	for {
+		if canRestore {
+			infof("   [m]ark as removed, [r]estore file from recorded SQL, [a]bort? ")
+		} else {
+			infof("   [m]ark as removed, [a]bort? ")
+		}
+
+		answer, err := reader.ReadString('\n')
+		answer = strings.TrimSpace(strings.ToLower(answer))
+		switch {
+		case answer == "m" || answer == "mark":
+			return reconcileMarkRemoved, nil
+		case canRestore && (answer == "r" || answer == "restore"):
+			return reconcileRestore, nil
+		case answer == "a" || answer == "abort" || err != nil:
+			return "", fmt.Errorf("reconcile aborted by user, no changes were made")
+		}
+		infof("   ❓ Unknown choice '%s'\n", answer)
+	}
+}
+
+// Write a migration file back from its recorded SQL
+// @param migrationDir string
+// @param recorded *RecordedMigration
+// @return string, error (path of the restored file)
+func restoreMigrationFile(migrationDir string, recorded *RecordedMigration) (string, error) {
+	if err := os.MkdirAll(migrationDir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create migration directory: %w", err)
+	}
+
+	name := recorded.Name
+	if name == "" {
+		name = "restored"
+	}
+	filePath := filepath.Join(migrationDir, fmt.Sprintf("%d_%s.sql", recorded.Version, name))
+	if _, err := os.Stat(filePath); err == nil {
+		return "", fmt.Errorf("migration file %s already exists", filePath)
+	}
+
+	header := fmt.Sprintf("-- Restored by migro reconcile from the SQL recorded when version %d was applied", recorded.Version)
+	content := renderMigrationSQL(header, &Migration{Up: recorded.Up, Down: recorded.Down, NoTransaction: recorded.NoTransaction})
+	if err := os.WriteFile(filePath, []byte(content), 0644); err != nil {
+		return "", fmt.Errorf("failed to write %s: %w", filePath, err)
+	}
+
+	return filePath, nil
+}
+
+// Offer to delete *_temp_*.sql placeholder files left behind by older migro
+// versions. They only contain SELECT 1 and hide the real missing files.
+// Nothing is deleted here, see removeTempFiles.
+// @param migrationDir string
+// @param reader *bufio.Reader
+// @return []*Migration, error (placeholders to remove)
+func confirmLeftoverTempFiles(migrationDir string, reader *bufio.Reader) ([]*Migration, error) {
+	migrations, err := loadMigrations(migrationDir)
+	if err != nil {
+		return nil, fmt.Errorf("❌ reconcile failed: %w", err)
+	}
+
+	var placeholders []*Migration
+	for _, migration := range migrations {
+		if isTempMigration(migration) {
+			placeholders = append(placeholders, migration)
+		}
+	}
+	if len(placeholders) == 0 {
+		return nil, nil
+	}
+
+	infof("🧹 Found %d temp placeholder file(s) from an older migro version:\n", len(placeholders))
+	for _, migration := range placeholders {
+		infof("   - %s\n", migration.Path)
+	}
+	infof("Remove them so the versions they hide can be reconciled? (y/N): ")
+
+	answer, _ := reader.ReadString('\n')
+	answer = strings.TrimSpace(strings.ToLower(answer))
+	if answer != "y" && answer != "yes" {
+		return nil, fmt.Errorf("reconcile aborted by user, no changes were made")
+	}
+	return placeholders, nil
+}
+
+// Add the applied versions hidden by temp placeholder files to the
+// missing versions, since their files are about to be removed
+// @param ctx context.Context
+// @param migrator *Migrator
+// @param missingVersions []int64
+// @param placeholders []*Migration
+// @return []int64, error
+func addHiddenVersions(ctx context.Context, migrator *Migrator, missingVersions []int64, placeholders []*Migration) ([]int64, error) {
+	if len(placeholders) == 0 {
+		return missingVersions, nil
+	}
+
+	applied, err := migrator.appliedVersions(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get database versions: %w", err)
+	}
+	for _, placeholder := range placeholders {
+		if contains64(applied, placeholder.Version) && !contains64(missingVersions, placeholder.Version) {
+			missingVersions = append(missingVersions, placeholder.Version)
+		}
+	}
+	sort.Slice(missingVersions, func(i, j int) bool { return missingVersions[i] < missingVersions[j] })
+	return missingVersions, nil
+}
+
+// Delete the temp placeholder files confirmed by confirmLeftoverTempFiles
+// @param placeholders []*Migration
+// @return error
+func removeTempFiles(placeholders []*Migration) error {
+	for _, migration := range placeholders {
+		if err := os.Remove(migration.Path); err != nil {
+			return fmt.Errorf("❌ failed to remove %s: %w", migration.Path, err)
+		}
+		infof("🗑️  Removed temp file: %s\n", migration.Path)
+	}
+	return nil
+}
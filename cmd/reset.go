@@ -1,22 +1,29 @@
 package migroCMD
 
 import (
-	"context"
 	"database/sql"
 	"fmt"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 // ResetSequenceOfTable reset sequence of table
+// @param config: *CONFIG
 // @param db: *pgxpool.Pool
-// @param table: string
-func ResetSequenceOfTable(db *pgxpool.Pool, table string) error {
-	ctx := context.Background()
+// @param table: string (table or schema.table)
+func ResetSequenceOfTable(config *CONFIG, db *pgxpool.Pool, table string) error {
+	tableName, err := resolveTableName(config, table)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := commandContext(config)
+	defer cancel()
 	var primaryKey string
-	err := db.QueryRow(ctx,
+	err = db.QueryRow(ctx,
 		"SELECT a.attname FROM pg_index i JOIN pg_attribute a ON a.attrelid = i.indrelid AND a.attnum = ANY(i.indkey) WHERE i.indrelid = $1::regclass AND i.indisprimary;",
-		table,
+		tableName.Quoted(),
 	).Scan(&primaryKey)
 	if err != nil {
 		return fmt.Errorf("get primary key failed: %w", err)
@@ -26,14 +33,14 @@ func ResetSequenceOfTable(db *pgxpool.Pool, table string) error {
 	var seqName string
 	err = db.QueryRow(ctx,
 		"SELECT pg_get_serial_sequence($1, $2)",
-		table, primaryKey,
+		tableName.Quoted(), primaryKey,
 	).Scan(&seqName)
 	if err != nil {
 		return fmt.Errorf("get serial sequence failed: %w", err)
 	}
 
 	// Get MAX id
-	query := fmt.Sprintf("SELECT MAX(%s) FROM %s", primaryKey, table)
+	query := fmt.Sprintf("SELECT MAX(%s) FROM %s", pgx.Identifier{primaryKey}.Sanitize(), tableName.Quoted())
 	var maxID sql.NullInt64
 	err = db.QueryRow(ctx, query).Scan(&maxID)
 	if err != nil {
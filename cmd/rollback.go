@@ -3,86 +3,116 @@ package migroCMD
 import (
 	"bufio"
 	"context"
+	"errors"
 	"fmt"
 	"os"
-	"os/exec"
-	"regexp"
-	"strconv"
 	"strings"
 
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
-// import (
-// 	"bufio"
-// 	"context"
-// 	"fmt"
-// 	"os"
-// 	"os/exec"
-// 	"strings"
-
-// 	"github.com/jackc/pgx/v5/pgxpool"
-// )
-
-var createdTempFiles []string // Track temp files for cleanup
-
-// Rollback migrations by count
+// Rollback migrations by count, or down to opts.Target
 // @param config: util.Config
 // @param db: *pgxpool.Pool
 // @param count: int - number of migrations to rollback
-func Rollback(config *CONFIG, db *pgxpool.Pool, count int) error {
-	ctx := context.Background()
-
-	if count <= 0 {
-		return fmt.Errorf("rollback count must be greater than 0")
+// @param opts: MigrateOptions
+func Rollback(config *CONFIG, db *pgxpool.Pool, count int, opts MigrateOptions) error {
+	ctx, cancel := commandContext(config)
+	defer cancel()
+	migrator := NewMigrator(config, db)
+
+	var target *Migration
+	if opts.Target != "" {
+		if count > 0 {
+			return fmt.Errorf("❌ use either --count or --to, not both")
+		}
+		migration, err := resolveTargetVersion(config.MIGRATION_DIR, opts.Target)
+		if err != nil {
+			return err
+		}
+		target = migration
+	} else if count <= 0 {
+		return fmt.Errorf("rollback count must be greater than 0 (use --count N or --to VERSION)")
 	}
 
-	fmt.Printf("🔄 Rolling back %d migration(s)...\n", count)
+	if opts.DryRun {
+		if target != nil {
+			appliedVersions, err := migrator.appliedVersions(ctx)
+			if err != nil {
+				return fmt.Errorf("failed to get applied migrations: %w", err)
+			}
+			count = countAppliedAfter(appliedVersions, target.Version)
+			if count == 0 {
+				infof("📭 Database is already at or below version %d, nothing to rollback\n", target.Version)
+				return nil
+			}
+		}
+		return dryRunRollback(ctx, migrator, count, opts.Output)
+	}
 
-	// Clear temp files tracker
-	createdTempFiles = []string{}
+	// Make sure no other process is migrating this database
+	unlock, err := acquireMigrationLock(ctx, db, config)
+	if err != nil {
+		return err
+	}
+	defer unlock()
 
-	for i := 1; i <= count; i++ {
-		fmt.Printf("📉 Rollback round %d/%d...\n", i, count)
+	if err := migrator.ensureVersionTable(ctx); err != nil {
+		return fmt.Errorf("rollback failed: %w", err)
+	}
 
-		err := performSingleRollback(config, ctx)
+	if target != nil {
+		// Decide under the lock, one migration at a time, so migrations
+		// applied by another process before the lock are rolled back too
+		infof("🎯 Rolling back to version %d (%s)\n", target.Version, target.FileName())
+		reverted, err := rollbackToVersion(ctx, target.Version, migrator.currentVersion, func(ctx context.Context) (*Migration, error) {
+			return performSingleRollback(ctx, migrator)
+		})
 		if err != nil {
-			return fmt.Errorf("rollback failed at round %d: %w", i, err)
+			return fmt.Errorf("rollback failed: %w", err)
 		}
+		if reverted == 0 {
+			infof("📭 Database is already at or below version %d, nothing to rollback\n", target.Version)
+			return nil
+		}
+		infof("✅ Rolled back %d migration(s)\n", reverted)
+	} else {
+		infof("🔄 Rolling back %d migration(s)...\n", count)
 
-		fmt.Printf("✅ Rollback round %d completed\n", i)
-	}
-
-	// Ask if user wants to cleanup temp files
-	if len(createdTempFiles) > 0 {
-		fmt.Printf("\n🧹 Đã tạo %d file temp migration. Bạn có muốn xóa chúng không? (y/N): ", len(createdTempFiles))
-		reader := bufio.NewReader(os.Stdin)
-		cleanup, _ := reader.ReadString('\n')
-		cleanup = strings.TrimSpace(strings.ToLower(cleanup))
+		for i := 1; i <= count; i++ {
+			infof("📉 Rollback round %d/%d...\n", i, count)
 
-		if cleanup == "y" || cleanup == "yes" {
-			cleanupTempFiles()
-		} else {
-			fmt.Println("📝 Temp files được giữ lại:")
-			for _, file := range createdTempFiles {
-				fmt.Printf("   - %s\n", file)
+			reverted, err := performSingleRollback(ctx, migrator)
+			if err != nil {
+				return fmt.Errorf("rollback failed at round %d: %w", i, err)
 			}
+			if reverted == nil {
+				infof("📭 No applied migrations left to rollback\n")
+				break
+			}
+
+			infof("✅ Rollback round %d completed\n", i)
 		}
 	}
 
 	// Show final migration status
-	return showMigrationStatus(config)
+	return showMigrationStatus(ctx, migrator, OutputTable)
 }
 
 // Rollback all migrations
 // @param config *CONFIG
 // @param db *pgxpool.Pool
+// @param opts MigrateOptions
 // @return error
-func RollbackAll(config *CONFIG, db *pgxpool.Pool) error {
-	ctx := context.Background()
+func RollbackAll(config *CONFIG, db *pgxpool.Pool, opts MigrateOptions) error {
+	if opts.DryRun {
+		ctx, cancel := commandContext(config)
+		defer cancel()
+		return dryRunRollback(ctx, NewMigrator(config, db), 0, opts.Output)
+	}
 
-	fmt.Println("🔥 Rolling back ALL migrations...")
-	fmt.Print("⚠️  This will rollback ALL migrations. Are you sure? (y/N): ")
+	infof("🔥 Rolling back ALL migrations...\n")
+	infof("⚠️  This will rollback ALL migrations. Are you sure? (y/N): ")
 
 	reader := bufio.NewReader(os.Stdin)
 	confirm, _ := reader.ReadString('\n')
@@ -92,224 +122,97 @@ func RollbackAll(config *CONFIG, db *pgxpool.Pool) error {
 		return fmt.Errorf("rollback all cancelled by user")
 	}
 
-	// Clear temp files tracker
-	createdTempFiles = []string{}
+	// Start the deadline after the confirmation prompt
+	ctx, cancel := commandContext(config)
+	defer cancel()
 
-	// Use goose reset to rollback all migrations
-	cmd := exec.CommandContext(ctx, "goose",
-		"-dir", config.MIGRATION_DIR,
-		config.DATABASE_CONNECTION_STRING,
-		"reset")
-
-	output, err := cmd.CombinedOutput()
+	// Make sure no other process is migrating this database
+	unlock, err := acquireMigrationLock(ctx, db, config)
 	if err != nil {
-		// Try to handle missing migration error
-		if strings.Contains(string(output), "missing") && strings.Contains(string(output), "migration") {
-			fmt.Println("🔧 Found missing migration, creating temp files...")
-			err = handleMissingMigrationForReset(config, ctx)
-			if err != nil {
-				return fmt.Errorf("failed to handle missing migration: %w", err)
-			}
-			// Retry reset after creating temp files
-			output, err = cmd.CombinedOutput()
-		}
+		return err
+	}
+	defer unlock()
 
+	migrator := NewMigrator(config, db)
+	if err := migrator.ensureVersionTable(ctx); err != nil {
+		return fmt.Errorf("rollback all failed: %w", err)
+	}
+	for {
+		reverted, err := performSingleRollback(ctx, migrator)
 		if err != nil {
-			return fmt.Errorf("rollback all failed: %w\nOutput: %s", err, string(output))
+			return fmt.Errorf("rollback all failed: %w", err)
 		}
-	}
-
-	fmt.Println("✅ All migrations rolled back successfully")
-
-	// Ask if user wants to cleanup temp files
-	if len(createdTempFiles) > 0 {
-		fmt.Printf("\n🧹 Đã tạo %d file temp migration. Bạn có muốn xóa chúng không? (y/N): ", len(createdTempFiles))
-		cleanup, _ := reader.ReadString('\n')
-		cleanup = strings.TrimSpace(strings.ToLower(cleanup))
-
-		if cleanup == "y" || cleanup == "yes" {
-			cleanupTempFiles()
+		if reverted == nil {
+			break
 		}
 	}
 
+	infof("✅ All migrations rolled back successfully\n")
+
 	// Show final migration status
-	return showMigrationStatus(config)
+	return showMigrationStatus(ctx, migrator, OutputTable)
 }
 
-// Handle missing migration for reset operation
-// @param config *CONFIG
+// Print the migrations a rollback would revert without running them
 // @param ctx context.Context
+// @param migrator *Migrator
+// @param count int - number of migrations, 0 for all
+// @param output string
 // @return error
-func handleMissingMigrationForReset(config *CONFIG, ctx context.Context) error {
-	// Get migration status to find missing versions
-	cmd := exec.CommandContext(ctx, "goose",
-		"-dir", config.MIGRATION_DIR,
-		config.DATABASE_CONNECTION_STRING,
-		"status")
+func dryRunRollback(ctx context.Context, migrator *Migrator, count int, output string) error {
+	if err := validatePlanOutput(output); err != nil {
+		return err
+	}
 
-	output, err := cmd.CombinedOutput()
+	plan, err := migrator.planDown(ctx, count)
 	if err != nil {
-		// Even if status fails, try to extract missing migration info
-		version, name := extractMissingMigrationInfo(config.MIGRATION_DIR, string(output))
-		if version > 0 {
-			tempFile := createTempMigration(config.MIGRATION_DIR, version, name)
-			if tempFile != "" {
-				createdTempFiles = append(createdTempFiles, tempFile)
-				return nil
-			}
-		}
-		return fmt.Errorf("failed to get migration status and create temp file: %w", err)
+		return fmt.Errorf("rollback plan failed: %w", err)
 	}
 
-	return nil
+	return printMigrationPlan(buildMigrationPlan("down", plan), output)
 }
 
-func executeGooseDown(config *CONFIG, ctx context.Context) ([]byte, error) {
-	cmd := exec.CommandContext(ctx, "goose",
-		"-dir", config.MIGRATION_DIR,
-		config.DATABASE_CONNECTION_STRING,
-		"down")
-
-	return cmd.CombinedOutput()
-}
-
-func performSingleRollback(config *CONFIG, ctx context.Context) error {
-	maxRetries := 5 // Prevent infinite loop
-
-	for retry := 0; retry < maxRetries; retry++ {
-		output, err := executeGooseDown(config, ctx)
-
+// Revert migrations one at a time while the current version is above target
+// @param ctx context.Context
+// @param target int64
+// @param currentVersion func(context.Context) (int64, error) - highest applied version
+// @param rollbackOne func(context.Context) (*Migration, error) - reverts the latest migration
+// @return int, error (number of reverted migrations)
+func rollbackToVersion(ctx context.Context, target int64, currentVersion func(context.Context) (int64, error), rollbackOne func(context.Context) (*Migration, error)) (int, error) {
+	reverted := 0
+	for {
+		current, err := currentVersion(ctx)
 		if err != nil {
-			// Check if it's a missing migration error for rollback
-			if strings.Contains(string(output), "no current version found") ||
-				strings.Contains(string(output), "migration") && strings.Contains(string(output), "no current version") {
-				fmt.Println("🔧 Found missing migration in rollback, creating temp file...")
-				fmt.Printf("🔍 Rollback error output: %s\n", string(output))
-
-				// Extract version from error
-				version := extractVersionFromRollbackError(string(output))
-				if version > 0 {
-					tempFile := createTempMigration(config.MIGRATION_DIR, version, "temp_migration")
-					if tempFile != "" {
-						fmt.Printf("📝 Created temp file for rollback: %s\n", tempFile)
-						createdTempFiles = append(createdTempFiles, tempFile)
-						continue // Retry rollback
-					}
-				}
-
-				// Fallback: try to get current version from database
-				currentVersion, err := getCurrentVersionFromDB(config)
-				if err == nil && currentVersion > 0 {
-					tempFile := createTempMigration(config.MIGRATION_DIR, currentVersion, "temp_migration")
-					if tempFile != "" {
-						fmt.Printf("📝 Created temp file for rollback (from DB): %s\n", tempFile)
-						createdTempFiles = append(createdTempFiles, tempFile)
-						continue // Retry rollback
-					}
-				}
-			}
-
-			// Check if it's a missing migration error (original logic)
-			if strings.Contains(string(output), "missing") && strings.Contains(string(output), "migration") {
-				fmt.Println("🔧 Found missing migration, creating temp file...")
-
-				// Extract missing version from error
-				version, migrationName := extractMissingMigrationInfo(config.MIGRATION_DIR, string(output))
-				if version > 0 {
-					tempFile := createTempMigration(config.MIGRATION_DIR, version, migrationName)
-					if tempFile != "" {
-						fmt.Printf("📝 Created temp file: %s\n", tempFile)
-						createdTempFiles = append(createdTempFiles, tempFile)
-						continue // Retry rollback
-					}
-				}
-			}
-
-			return fmt.Errorf("goose down failed: %w\nOutput: %s", err, string(output))
+			return reverted, err
+		}
+		if current <= target {
+			return reverted, nil
 		}
 
-		// Success
-		return nil
-	}
-
-	return fmt.Errorf("failed after %d retries", maxRetries)
-}
-
-// Extract version from rollback error "migration 20250707100918: no current version found"
-// @param output string
-// @return int64
-func extractVersionFromRollbackError(output string) int64 {
-	// Pattern: "migration 20250707100918: no current version found"
-	re := regexp.MustCompile(`migration\s+(\d+):\s+no current version`)
-	matches := re.FindStringSubmatch(output)
-
-	if len(matches) > 1 {
-		version, err := strconv.ParseInt(matches[1], 10, 64)
-		if err == nil {
-			return version
+		migration, err := rollbackOne(ctx)
+		if err != nil {
+			return reverted, fmt.Errorf("reverting version %d: %w", current, err)
 		}
-	}
-	// Alternative pattern: "goose run: migration 20250707100918: no current version found"
-	re2 := regexp.MustCompile(`goose run: migration\s+(\d+):`)
-	matches2 := re2.FindStringSubmatch(output)
-	if len(matches2) > 1 {
-		version, err := strconv.ParseInt(matches2[1], 10, 64)
-		if err == nil {
-			return version
+		if migration == nil {
+			return reverted, nil
 		}
+		reverted++
 	}
-	return 0
 }
 
-// Get current migration version from database
-// @param config *CONFIG
-// @return int64, error
-func getCurrentVersionFromDB(config *CONFIG) (int64, error) {
-	ctx := context.Background()
-
-	cmd := exec.CommandContext(ctx, "goose",
-		"-dir", config.MIGRATION_DIR,
-		config.DATABASE_CONNECTION_STRING,
-		"version")
-
-	output, err := cmd.CombinedOutput()
+// Revert the latest applied migration. An applied version without a local
+// file is never faked: the rollback stops and points to migro reconcile.
+// @param ctx context.Context
+// @param migrator *Migrator
+// @return *Migration, error (reverted migration, nil if nothing is applied)
+func performSingleRollback(ctx context.Context, migrator *Migrator) (*Migration, error) {
+	reverted, err := migrator.Down(ctx)
 	if err != nil {
-		return 0, fmt.Errorf("failed to get current version: %w", err)
-	}
-
-	// Parse version from output
-	version := parseCurrentVersionFromOutput(string(output))
-	return version, nil
-}
-
-// Parse current version from goose version command output
-// @param output string
-// @return int64
-func parseCurrentVersionFromOutput(output string) int64 {
-	// Pattern: "goose: version 20250707100918"
-	re := regexp.MustCompile(`version\s+(\d+)`)
-	matches := re.FindStringSubmatch(output)
-
-	if len(matches) > 1 {
-		version, err := strconv.ParseInt(matches[1], 10, 64)
-		if err == nil {
-			return version
-		}
-	}
-
-	return 0
-}
-
-// Cleanup temporary migration files
-// @param createdTempFiles []string
-func cleanupTempFiles() {
-	for _, file := range createdTempFiles {
-		err := os.Remove(file)
-		if err != nil {
-			fmt.Printf("⚠️  Failed to remove temp file %s: %v\n", file, err)
-		} else {
-			fmt.Printf("🗑️  Removed temp file: %s\n", file)
+		var missingErr *MissingFileError
+		if errors.As(err, &missingErr) {
+			return nil, fmt.Errorf("%w\n💡 Run: migro reconcile to restore or remove version %d", err, missingErr.Version)
 		}
+		return nil, err
 	}
-	createdTempFiles = []string{}
+	return reverted, nil
 }
@@ -0,0 +1,106 @@
+package migroCMD
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRollbackToVersion(t *testing.T) {
+	tests := []struct {
+		name         string
+		applied      []int64
+		target       int64
+		appliedLater []int64 // applied by another process before the first rollback
+		failAt       int64
+		wantReverted []int64
+		wantErr      string
+	}{
+		{
+			name:         "reverts everything above the target",
+			applied:      []int64{1, 2, 3, 4},
+			target:       2,
+			wantReverted: []int64{4, 3},
+		},
+		{
+			name:    "already at the target",
+			applied: []int64{1, 2},
+			target:  2,
+		},
+		{
+			name:         "migrations applied by another process are rolled back too",
+			applied:      []int64{1, 2, 3},
+			target:       2,
+			appliedLater: []int64{4, 5},
+			wantReverted: []int64{5, 4, 3},
+		},
+		{
+			name:         "out-of-order versions below the target are kept",
+			applied:      []int64{1, 3, 5},
+			target:       4,
+			wantReverted: []int64{5},
+		},
+		{
+			name:         "target below every applied version",
+			applied:      []int64{2, 3},
+			target:       1,
+			wantReverted: []int64{3, 2},
+		},
+		{
+			name:         "stops at the first failure",
+			applied:      []int64{1, 2, 3, 4},
+			target:       1,
+			failAt:       3,
+			wantReverted: []int64{4},
+			wantErr:      "reverting version 3",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			applied := append([]int64(nil), tt.applied...)
+			pendingConcurrent := tt.appliedLater
+			var reverted []int64
+
+			currentVersion := func(ctx context.Context) (int64, error) {
+				// Another process applies its migrations between the
+				// command starting and the first rollback
+				applied = append(applied, pendingConcurrent...)
+				pendingConcurrent = nil
+				if len(applied) == 0 {
+					return 0, nil
+				}
+				return applied[len(applied)-1], nil
+			}
+			rollbackOne := func(ctx context.Context) (*Migration, error) {
+				if len(applied) == 0 {
+					return nil, nil
+				}
+				version := applied[len(applied)-1]
+				if version == tt.failAt {
+					return nil, errors.New("down failed")
+				}
+				applied = applied[:len(applied)-1]
+				reverted = append(reverted, version)
+				return &Migration{Version: version}, nil
+			}
+
+			count, err := rollbackToVersion(context.Background(), tt.target, currentVersion, rollbackOne)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("rollbackToVersion() error = %v, want it to mention %q", err, tt.wantErr)
+				}
+			} else if err != nil {
+				t.Fatalf("rollbackToVersion() error = %v", err)
+			}
+			if !reflect.DeepEqual(reverted, tt.wantReverted) {
+				t.Errorf("reverted = %v, want %v", reverted, tt.wantReverted)
+			}
+			if count != len(tt.wantReverted) {
+				t.Errorf("rollbackToVersion() = %d, want %d", count, len(tt.wantReverted))
+			}
+		})
+	}
+}
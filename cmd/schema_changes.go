@@ -0,0 +1,408 @@
+package migroCMD
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Render the DDL statements that turn the from schema into the to schema.
+// Statements are ordered so dependencies hold: views, triggers and foreign
+// keys are dropped first and recreated last, tables are created before the
+// constraints that reference them and dropped after their columns changed.
+// Changes PostgreSQL cannot express in place, such as removing an enum
+// label, are returned as warnings instead, as are dropped tables and
+// columns since they lose data.
+// @param from *SchemaSnapshot
+// @param to *SchemaSnapshot
+// @return []string, []string (statements, warnings)
+func renderSchemaChanges(from, to *SchemaSnapshot) ([]string, []string) {
+	var (
+		dropViews, dropTriggers, dropForeignKeys, dropConstraints, dropIndexes []string
+		createSchemas, enums, createSequences, alterSequences, createFunctions []string
+		createTables, columns, lateFunctions, addConstraints, ownSequences     []string
+		addForeignKeys, createIndexes, attachIndexes, createTriggers           []string
+		createViews                                                            []string
+		dropTables, dropFunctions, dropSequences, dropEnums, dropSchemas       []string
+		warnings                                                               []string
+	)
+	qualified := func(schema, name string) string { return pgx.Identifier{schema, name}.Sanitize() }
+
+	diffNamed(from.Schemas, to.Schemas, func(schema string) string { return schema }, func(change string, schema string) {
+		if change == diffExtra {
+			createSchemas = append(createSchemas, fmt.Sprintf("CREATE SCHEMA IF NOT EXISTS %s;", pgx.Identifier{schema}.Sanitize()))
+		} else {
+			dropSchemas = append(dropSchemas, fmt.Sprintf("DROP SCHEMA IF EXISTS %s;", pgx.Identifier{schema}.Sanitize()))
+		}
+	}, nil)
+
+	enumKey := func(enum SchemaEnum) string { return TableName{Schema: enum.Schema, Name: enum.Name}.String() }
+	diffNamed(from.Enums, to.Enums, enumKey, func(change string, enum SchemaEnum) {
+		if change == diffExtra {
+			labels := make([]string, len(enum.Labels))
+			for i, label := range enum.Labels {
+				labels[i] = quoteLiteral(label)
+			}
+			enums = append(enums, fmt.Sprintf("CREATE TYPE %s AS ENUM (%s);", qualified(enum.Schema, enum.Name), strings.Join(labels, ", ")))
+		} else {
+			dropEnums = append(dropEnums, fmt.Sprintf("DROP TYPE IF EXISTS %s;", qualified(enum.Schema, enum.Name)))
+		}
+	}, func(before, after SchemaEnum) {
+		added, warning := renderEnumChanges(before, after)
+		enums = append(enums, added...)
+		if warning != "" {
+			warnings = append(warnings, warning)
+		}
+	})
+
+	sequenceKey := func(seq SchemaSequence) string { return TableName{Schema: seq.Schema, Name: seq.Name}.String() }
+	diffNamed(from.Sequences, to.Sequences, sequenceKey, func(change string, seq SchemaSequence) {
+		if change == diffExtra {
+			createSequences = append(createSequences, renderCreateSequence(seq))
+			if seq.OwnedBy != "" {
+				ownSequences = append(ownSequences, fmt.Sprintf("ALTER SEQUENCE %s OWNED BY %s;", qualified(seq.Schema, seq.Name), seq.OwnedBy))
+			}
+		} else {
+			dropSequences = append(dropSequences, fmt.Sprintf("DROP SEQUENCE IF EXISTS %s;", qualified(seq.Schema, seq.Name)))
+		}
+	}, func(before, after SchemaSequence) {
+		if before.DataType != after.DataType || before.Start != after.Start || before.Increment != after.Increment ||
+			before.Min != after.Min || before.Max != after.Max || before.Cache != after.Cache || before.Cycle != after.Cycle {
+			cycle := "NO CYCLE"
+			if after.Cycle {
+				cycle = "CYCLE"
+			}
+			alterSequences = append(alterSequences, fmt.Sprintf("ALTER SEQUENCE %s AS %s START WITH %d INCREMENT BY %d MINVALUE %d MAXVALUE %d CACHE %d %s;",
+				qualified(after.Schema, after.Name), after.DataType, after.Start, after.Increment, after.Min, after.Max, after.Cache, cycle))
+		}
+		if before.OwnedBy != after.OwnedBy {
+			owner := after.OwnedBy
+			if owner == "" {
+				owner = "NONE"
+			}
+			ownSequences = append(ownSequences, fmt.Sprintf("ALTER SEQUENCE %s OWNED BY %s;", qualified(after.Schema, after.Name), owner))
+		}
+	})
+
+	// As in a baseline, SQL functions and functions using a table row type
+	// are created once the tables and their new columns exist
+	createFunction := func(function SchemaFunction) {
+		if functionNeedsTables(function) {
+			lateFunctions = append(lateFunctions, ensureSemicolon(function.Definition))
+		} else {
+			createFunctions = append(createFunctions, ensureSemicolon(function.Definition))
+		}
+	}
+	diffNamed(from.Functions, to.Functions, functionKey, func(change string, function SchemaFunction) {
+		if change == diffExtra {
+			createFunction(function)
+		} else {
+			dropFunctions = append(dropFunctions, renderDropFunction(function))
+		}
+	}, func(before, after SchemaFunction) {
+		if normalizeDefinition(before.Definition) != normalizeDefinition(after.Definition) {
+			createFunction(after)
+		}
+	})
+	// Function bodies may use tables created later
+	if len(createFunctions)+len(lateFunctions) > 0 {
+		createFunctions = append([]string{disableFunctionBodyChecks}, createFunctions...)
+	}
+
+	existingIndexes := make(map[string]bool)
+	for _, table := range from.Tables {
+		for _, index := range table.Indexes {
+			existingIndexes[qualified(table.Schema, index.Name)] = true
+		}
+	}
+	// PostgreSQL creates the index of a new partition itself when the
+	// partitioned index already exists; otherwise the partitioned index is
+	// created ON ONLY the parent and the partition's index is attached to it
+	createIndex := func(table SchemaTable, index SchemaIndex, newTable bool) {
+		if index.Parent != "" && newTable && existingIndexes[index.Parent] {
+			return
+		}
+		createIndexes = append(createIndexes, index.Definition+";")
+		if index.Parent != "" {
+			attachIndexes = append(attachIndexes, fmt.Sprintf("ALTER INDEX %s ATTACH PARTITION %s;",
+				index.Parent, qualified(table.Schema, index.Name)))
+		}
+	}
+
+	tableKey := func(table SchemaTable) string { return TableName{Schema: table.Schema, Name: table.Name}.String() }
+	diffNamed(from.Tables, to.Tables, tableKey, func(change string, table SchemaTable) {
+		if change == diffExtra {
+			createTables = append(createTables, renderCreateTable(table))
+			for _, constraint := range table.Constraints {
+				if constraint.Type == "f" {
+					addForeignKeys = append(addForeignKeys, renderAddConstraint(table, constraint))
+				}
+			}
+			for _, index := range table.Indexes {
+				createIndex(table, index, true)
+			}
+			for _, trigger := range table.Triggers {
+				createTriggers = append(createTriggers, trigger.Definition+";")
+			}
+			return
+		}
+		// Foreign keys go first, so dropped tables may reference each other
+		for _, constraint := range table.Constraints {
+			if constraint.Type == "f" {
+				dropForeignKeys = append(dropForeignKeys, renderDropConstraint(table, constraint))
+			}
+		}
+		dropTables = append(dropTables, fmt.Sprintf("DROP TABLE IF EXISTS %s;", table.QualifiedName()))
+		warnings = append(warnings, fmt.Sprintf("table %s is dropped, its rows are lost",
+			TableName{Schema: table.Schema, Name: table.Name}))
+	}, func(before, after SchemaTable) {
+		if before.PartitionKey != after.PartitionKey || before.PartitionOf != after.PartitionOf || before.PartitionBound != after.PartitionBound {
+			warnings = append(warnings, fmt.Sprintf("table %s changed its partitioning, PostgreSQL cannot alter it in place; recreate the table manually",
+				TableName{Schema: after.Schema, Name: after.Name}))
+		}
+		diffNamed(before.Constraints, after.Constraints, func(constraint SchemaConstraint) string { return constraint.Name },
+			func(change string, constraint SchemaConstraint) {
+				switch {
+				case change == diffExtra && constraint.Type == "f":
+					addForeignKeys = append(addForeignKeys, renderAddConstraint(after, constraint))
+				case change == diffExtra:
+					addConstraints = append(addConstraints, renderAddConstraint(after, constraint))
+				case constraint.Type == "f":
+					dropForeignKeys = append(dropForeignKeys, renderDropConstraint(before, constraint))
+				default:
+					dropConstraints = append(dropConstraints, renderDropConstraint(before, constraint))
+				}
+			},
+			func(oldConstraint, newConstraint SchemaConstraint) {
+				if oldConstraint.Definition == newConstraint.Definition {
+					return
+				}
+				if oldConstraint.Type == "f" {
+					dropForeignKeys = append(dropForeignKeys, renderDropConstraint(before, oldConstraint))
+				} else {
+					dropConstraints = append(dropConstraints, renderDropConstraint(before, oldConstraint))
+				}
+				if newConstraint.Type == "f" {
+					addForeignKeys = append(addForeignKeys, renderAddConstraint(after, newConstraint))
+				} else {
+					addConstraints = append(addConstraints, renderAddConstraint(after, newConstraint))
+				}
+			})
+
+		diffNamed(before.Indexes, after.Indexes, func(index SchemaIndex) string { return index.Name },
+			func(change string, index SchemaIndex) {
+				if change == diffExtra {
+					createIndex(after, index, false)
+				} else {
+					dropIndexes = append(dropIndexes, fmt.Sprintf("DROP INDEX IF EXISTS %s;", qualified(before.Schema, index.Name)))
+				}
+			},
+			func(oldIndex, newIndex SchemaIndex) {
+				if oldIndex.Definition != newIndex.Definition {
+					dropIndexes = append(dropIndexes, fmt.Sprintf("DROP INDEX IF EXISTS %s;", qualified(before.Schema, oldIndex.Name)))
+					createIndex(after, newIndex, false)
+				}
+			})
+
+		diffNamed(before.Triggers, after.Triggers, func(trigger SchemaTrigger) string { return trigger.Name },
+			func(change string, trigger SchemaTrigger) {
+				if change == diffExtra {
+					createTriggers = append(createTriggers, trigger.Definition+";")
+				} else {
+					dropTriggers = append(dropTriggers, renderDropTrigger(before, trigger))
+				}
+			},
+			func(oldTrigger, newTrigger SchemaTrigger) {
+				if oldTrigger.Definition != newTrigger.Definition {
+					dropTriggers = append(dropTriggers, renderDropTrigger(before, oldTrigger))
+					createTriggers = append(createTriggers, newTrigger.Definition+";")
+				}
+			})
+
+		var added, dropped []string
+		diffNamed(before.Columns, after.Columns, func(column SchemaColumn) string { return column.Name },
+			func(change string, column SchemaColumn) {
+				if change == diffExtra {
+					added = append(added, fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s;", after.QualifiedName(), renderColumnDefinition(column)))
+					if column.NotNull && column.Default == "" && column.Identity == "" {
+						warnings = append(warnings, fmt.Sprintf("column %s.%s is NOT NULL without a default, adding it fails if the table has rows",
+							TableName{Schema: after.Schema, Name: after.Name}, column.Name))
+					}
+				} else {
+					dropped = append(dropped, fmt.Sprintf("ALTER TABLE %s DROP COLUMN IF EXISTS %s;", before.QualifiedName(), pgx.Identifier{column.Name}.Sanitize()))
+					warnings = append(warnings, fmt.Sprintf("column %s.%s is dropped, its values are lost",
+						TableName{Schema: before.Schema, Name: before.Name}, column.Name))
+				}
+			},
+			func(oldColumn, newColumn SchemaColumn) {
+				altered, warning := renderColumnChanges(after, oldColumn, newColumn)
+				columns = append(columns, altered...)
+				if warning != "" {
+					warnings = append(warnings, warning)
+				}
+			})
+		columns = append(columns, added...)
+		columns = append(columns, dropped...)
+	})
+
+	viewKey := func(view SchemaView) string { return TableName{Schema: view.Schema, Name: view.Name}.String() }
+	var removedViews []SchemaView
+	diffNamed(from.Views, to.Views, viewKey, func(change string, view SchemaView) {
+		if change == diffExtra {
+			createViews = append(createViews, renderCreateView(view))
+		} else {
+			removedViews = append(removedViews, view)
+		}
+	}, func(before, after SchemaView) {
+		if before.Materialized != after.Materialized || normalizeDefinition(before.Definition) != normalizeDefinition(after.Definition) {
+			removedViews = append(removedViews, before)
+			createViews = append(createViews, renderCreateView(after))
+		}
+	})
+	// Views may depend on each other, drop them in reverse creation order
+	for i := len(removedViews) - 1; i >= 0; i-- {
+		dropViews = append(dropViews, renderDropView(removedViews[i]))
+	}
+
+	var statements []string
+	for _, phase := range [][]string{
+		dropViews, dropTriggers, dropForeignKeys, dropIndexes, dropConstraints,
+		createSchemas, enums, createSequences, alterSequences, createFunctions, createTables,
+		columns, lateFunctions, addConstraints, ownSequences, addForeignKeys,
+		createIndexes, attachIndexes, createTriggers, createViews,
+		dropTables, dropFunctions, dropSequences, dropEnums, dropSchemas,
+	} {
+		statements = append(statements, phase...)
+	}
+	return statements, warnings
+}
+
+// Render the statements that change a column in place: type, default,
+// identity and NOT NULL
+// @param table SchemaTable
+// @param before SchemaColumn
+// @param after SchemaColumn
+// @return []string, string (statements, warning)
+func renderColumnChanges(table SchemaTable, before, after SchemaColumn) ([]string, string) {
+	alter := fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s ", table.QualifiedName(), pgx.Identifier{after.Name}.Sanitize())
+	name := TableName{Schema: table.Schema, Name: table.Name}.String() + "." + after.Name
+
+	if before.Generated || after.Generated {
+		if before.Generated != after.Generated || before.Default != after.Default || before.Type != after.Type {
+			return nil, fmt.Sprintf("column %s: generated column changed, drop and re-add it manually", name)
+		}
+		return nil, ""
+	}
+
+	var statements []string
+	if before.Default != "" && before.Default != after.Default {
+		statements = append(statements, alter+"DROP DEFAULT;")
+	}
+	if before.Type != after.Type {
+		statements = append(statements, fmt.Sprintf("%sTYPE %s USING %s::%s;", alter, after.Type, pgx.Identifier{after.Name}.Sanitize(), after.Type))
+	}
+	switch {
+	case before.Identity == after.Identity:
+	case after.Identity == "":
+		statements = append(statements, alter+"DROP IDENTITY IF EXISTS;")
+	case before.Identity == "":
+		statements = append(statements, alter+"ADD "+identityClause(after.Identity)+";")
+	default:
+		statements = append(statements, alter+"SET "+strings.TrimSuffix(identityClause(after.Identity), " AS IDENTITY")+";")
+	}
+	if after.Default != "" && before.Default != after.Default {
+		statements = append(statements, alter+"SET DEFAULT "+after.Default+";")
+	}
+	// Identity columns are always NOT NULL
+	if after.Identity == "" && before.NotNull != after.NotNull {
+		if after.NotNull {
+			statements = append(statements, alter+"SET NOT NULL;")
+		} else {
+			statements = append(statements, alter+"DROP NOT NULL;")
+		}
+	}
+	return statements, ""
+}
+
+// Render the statements that add the after labels of an enum. PostgreSQL
+// cannot remove or reorder enum labels, that is returned as a warning.
+// @param before SchemaEnum
+// @param after SchemaEnum
+// @return []string, string (statements, warning)
+func renderEnumChanges(before, after SchemaEnum) ([]string, string) {
+	name := pgx.Identifier{after.Schema, after.Name}.Sanitize()
+
+	var removed []string
+	for _, label := range before.Labels {
+		if !contains(after.Labels, label) {
+			removed = append(removed, label)
+		}
+	}
+	if len(removed) > 0 {
+		return nil, fmt.Sprintf("enum %s: labels %s were removed, PostgreSQL cannot drop enum labels; recreate the type manually",
+			TableName{Schema: after.Schema, Name: after.Name}, strings.Join(removed, ", "))
+	}
+
+	// The labels both enums share must keep their order
+	var kept []string
+	for _, label := range after.Labels {
+		if contains(before.Labels, label) {
+			kept = append(kept, label)
+		}
+	}
+	if strings.Join(kept, "\x00") != strings.Join(before.Labels, "\x00") {
+		return nil, fmt.Sprintf("enum %s: labels were reordered, recreate the type manually", TableName{Schema: after.Schema, Name: after.Name})
+	}
+
+	var statements []string
+	for i, label := range after.Labels {
+		if contains(before.Labels, label) {
+			continue
+		}
+		position := ""
+		if i > 0 {
+			position = " AFTER " + quoteLiteral(after.Labels[i-1])
+		} else if len(after.Labels) > 1 {
+			position = " BEFORE " + quoteLiteral(after.Labels[1])
+		}
+		statements = append(statements, fmt.Sprintf("ALTER TYPE %s ADD VALUE IF NOT EXISTS %s%s;", name, quoteLiteral(label), position))
+	}
+	return statements, ""
+}
+
+// Render ALTER TABLE ADD CONSTRAINT
+// @param table SchemaTable
+// @param constraint SchemaConstraint
+// @return string
+func renderAddConstraint(table SchemaTable, constraint SchemaConstraint) string {
+	return fmt.Sprintf("ALTER TABLE %s ADD CONSTRAINT %s %s;",
+		table.QualifiedName(), pgx.Identifier{constraint.Name}.Sanitize(), constraint.Definition)
+}
+
+// Render ALTER TABLE DROP CONSTRAINT
+// @param table SchemaTable
+// @param constraint SchemaConstraint
+// @return string
+func renderDropConstraint(table SchemaTable, constraint SchemaConstraint) string {
+	return fmt.Sprintf("ALTER TABLE %s DROP CONSTRAINT IF EXISTS %s;",
+		table.QualifiedName(), pgx.Identifier{constraint.Name}.Sanitize())
+}
+
+// Render DROP TRIGGER
+// @param table SchemaTable
+// @param trigger SchemaTrigger
+// @return string
+func renderDropTrigger(table SchemaTable, trigger SchemaTrigger) string {
+	return fmt.Sprintf("DROP TRIGGER IF EXISTS %s ON %s;", pgx.Identifier{trigger.Name}.Sanitize(), table.QualifiedName())
+}
+
+// Identity clause of a column, as in GENERATED ALWAYS AS IDENTITY
+// @param identity string - "a" or "d" as in pg_attribute.attidentity
+// @return string
+func identityClause(identity string) string {
+	if identity == "a" {
+		return "GENERATED ALWAYS AS IDENTITY"
+	}
+	return "GENERATED BY DEFAULT AS IDENTITY"
+}
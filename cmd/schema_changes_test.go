@@ -0,0 +1,36 @@
+package migroCMD
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRenderSchemaChangesFunctionOrder(t *testing.T) {
+	from := &SchemaSnapshot{
+		Tables: []SchemaTable{{Schema: "public", Name: "users", Columns: []SchemaColumn{{Name: "id", Type: "integer"}}}},
+	}
+	to := &SchemaSnapshot{
+		Functions: []SchemaFunction{
+			{Schema: "public", Name: "recent_orders", Language: "plpgsql", RowTypes: true, Definition: "CREATE FUNCTION public.recent_orders() RETURNS SETOF orders LANGUAGE plpgsql AS $$ BEGIN RETURN QUERY SELECT * FROM orders; END $$"},
+			{Schema: "public", Name: "touch", Language: "plpgsql", Definition: "CREATE FUNCTION public.touch() RETURNS trigger LANGUAGE plpgsql AS $$ BEGIN RETURN NEW; END $$"},
+			{Schema: "public", Name: "user_names", Language: "sql", Definition: "CREATE FUNCTION public.user_names() RETURNS SETOF text LANGUAGE sql AS $$ SELECT name FROM users $$"},
+		},
+		Tables: []SchemaTable{
+			{Schema: "public", Name: "orders", Columns: []SchemaColumn{{Name: "id", Type: "integer"}}},
+			{Schema: "public", Name: "users", Columns: []SchemaColumn{{Name: "id", Type: "integer"}, {Name: "name", Type: "text"}}},
+		},
+	}
+
+	up, _ := renderSchemaChanges(from, to)
+	want := []string{
+		"SET LOCAL check_function_bodies = false;",
+		"CREATE FUNCTION public.touch() RETURNS trigger LANGUAGE plpgsql AS $$ BEGIN RETURN NEW; END $$;",
+		"CREATE TABLE \"public\".\"orders\" (\n    \"id\" integer\n);",
+		"ALTER TABLE \"public\".\"users\" ADD COLUMN \"name\" text;",
+		"CREATE FUNCTION public.recent_orders() RETURNS SETOF orders LANGUAGE plpgsql AS $$ BEGIN RETURN QUERY SELECT * FROM orders; END $$;",
+		"CREATE FUNCTION public.user_names() RETURNS SETOF text LANGUAGE sql AS $$ SELECT name FROM users $$;",
+	}
+	if !reflect.DeepEqual(up, want) {
+		t.Errorf("renderSchemaChanges() up =\n%q\nwant\n%q", up, want)
+	}
+}
@@ -0,0 +1,258 @@
+package migroCMD
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Statement that lets function bodies use objects created later in the
+// same transaction
+const disableFunctionBodyChecks = "SET LOCAL check_function_bodies = false;"
+
+// Render a schema snapshot as ordered DDL statements that recreate it, and
+// the statements that drop it again in reverse order
+// @param snapshot *SchemaSnapshot
+// @return []string, []string (up, down)
+func renderSchemaDDL(snapshot *SchemaSnapshot) ([]string, []string) {
+	var up, down []string
+
+	for _, schema := range snapshot.Schemas {
+		up = append(up, fmt.Sprintf("CREATE SCHEMA IF NOT EXISTS %s;", pgx.Identifier{schema}.Sanitize()))
+	}
+
+	for _, enum := range snapshot.Enums {
+		labels := make([]string, len(enum.Labels))
+		for i, label := range enum.Labels {
+			labels[i] = quoteLiteral(label)
+		}
+		up = append(up, fmt.Sprintf("CREATE TYPE %s AS ENUM (%s);",
+			pgx.Identifier{enum.Schema, enum.Name}.Sanitize(), strings.Join(labels, ", ")))
+	}
+
+	for _, seq := range snapshot.Sequences {
+		up = append(up, renderCreateSequence(seq))
+	}
+
+	// Functions come before the tables whose defaults and checks call them;
+	// their bodies may in turn use tables, so bodies are not checked yet.
+	// SQL functions and functions taking or returning a table row type need
+	// the tables to exist, so they follow the tables.
+	var lateFunctions []SchemaFunction
+	if len(snapshot.Functions) > 0 {
+		up = append(up, disableFunctionBodyChecks)
+	}
+	for _, function := range snapshot.Functions {
+		if functionNeedsTables(function) {
+			lateFunctions = append(lateFunctions, function)
+			continue
+		}
+		up = append(up, ensureSemicolon(function.Definition))
+	}
+
+	for _, table := range snapshot.Tables {
+		up = append(up, renderCreateTable(table))
+	}
+
+	for _, function := range lateFunctions {
+		up = append(up, ensureSemicolon(function.Definition))
+	}
+
+	// Sequences owned by serial columns can only be attached once the table exists
+	for _, seq := range snapshot.Sequences {
+		if seq.OwnedBy != "" {
+			up = append(up, fmt.Sprintf("ALTER SEQUENCE %s OWNED BY %s;",
+				pgx.Identifier{seq.Schema, seq.Name}.Sanitize(), seq.OwnedBy))
+		}
+	}
+
+	// Foreign keys last, so tables can reference each other in any order
+	for _, table := range snapshot.Tables {
+		for _, constraint := range table.Constraints {
+			if constraint.Type == "f" {
+				up = append(up, fmt.Sprintf("ALTER TABLE %s ADD CONSTRAINT %s %s;",
+					table.QualifiedName(), pgx.Identifier{constraint.Name}.Sanitize(), constraint.Definition))
+			}
+		}
+	}
+
+	for _, table := range snapshot.Tables {
+		for _, index := range table.Indexes {
+			up = append(up, index.Definition+";")
+		}
+	}
+
+	// An index on a partitioned table is created ON ONLY the parent and is
+	// valid once the index of every partition is attached to it
+	for _, table := range snapshot.Tables {
+		for _, index := range table.Indexes {
+			if index.Parent != "" {
+				up = append(up, fmt.Sprintf("ALTER INDEX %s ATTACH PARTITION %s;",
+					index.Parent, pgx.Identifier{table.Schema, index.Name}.Sanitize()))
+			}
+		}
+	}
+
+	for _, table := range snapshot.Tables {
+		for _, trigger := range table.Triggers {
+			up = append(up, trigger.Definition+";")
+		}
+	}
+
+	for _, view := range snapshot.Views {
+		up = append(up, renderCreateView(view))
+	}
+
+	// Down drops everything in reverse dependency order
+	for i := len(snapshot.Views) - 1; i >= 0; i-- {
+		down = append(down, renderDropView(snapshot.Views[i]))
+	}
+	for i := len(snapshot.Tables) - 1; i >= 0; i-- {
+		down = append(down, fmt.Sprintf("DROP TABLE IF EXISTS %s CASCADE;", snapshot.Tables[i].QualifiedName()))
+	}
+	for i := len(snapshot.Functions) - 1; i >= 0; i-- {
+		down = append(down, renderDropFunction(snapshot.Functions[i]))
+	}
+	for i := len(snapshot.Sequences) - 1; i >= 0; i-- {
+		seq := snapshot.Sequences[i]
+		down = append(down, fmt.Sprintf("DROP SEQUENCE IF EXISTS %s;", pgx.Identifier{seq.Schema, seq.Name}.Sanitize()))
+	}
+	for i := len(snapshot.Enums) - 1; i >= 0; i-- {
+		enum := snapshot.Enums[i]
+		down = append(down, fmt.Sprintf("DROP TYPE IF EXISTS %s;", pgx.Identifier{enum.Schema, enum.Name}.Sanitize()))
+	}
+	for i := len(snapshot.Schemas) - 1; i >= 0; i-- {
+		down = append(down, fmt.Sprintf("DROP SCHEMA IF EXISTS %s;", pgx.Identifier{snapshot.Schemas[i]}.Sanitize()))
+	}
+
+	return up, down
+}
+
+// Render CREATE SEQUENCE with its current options
+// @param seq SchemaSequence
+// @return string
+func renderCreateSequence(seq SchemaSequence) string {
+	cycle := "NO CYCLE"
+	if seq.Cycle {
+		cycle = "CYCLE"
+	}
+	return fmt.Sprintf("CREATE SEQUENCE %s AS %s START WITH %d INCREMENT BY %d MINVALUE %d MAXVALUE %d CACHE %d %s;",
+		pgx.Identifier{seq.Schema, seq.Name}.Sanitize(), seq.DataType,
+		seq.Start, seq.Increment, seq.Min, seq.Max, seq.Cache, cycle)
+}
+
+// Render CREATE TABLE with columns and all constraints except foreign keys.
+// A partition is created as PARTITION OF its parent with its bound, and a
+// partitioned table gets its PARTITION BY clause.
+// @param table SchemaTable
+// @return string
+func renderCreateTable(table SchemaTable) string {
+	partitionBy := ""
+	if table.PartitionKey != "" {
+		partitionBy = " PARTITION BY " + table.PartitionKey
+	}
+
+	var lines []string
+	for _, column := range table.Columns {
+		lines = append(lines, "    "+renderColumnDefinition(column))
+	}
+	for _, constraint := range table.Constraints {
+		if constraint.Type == "f" {
+			continue
+		}
+		lines = append(lines, fmt.Sprintf("    CONSTRAINT %s %s", pgx.Identifier{constraint.Name}.Sanitize(), constraint.Definition))
+	}
+
+	if table.PartitionOf != "" {
+		body := ""
+		if len(lines) > 0 {
+			body = fmt.Sprintf(" (\n%s\n)", strings.Join(lines, ",\n"))
+		}
+		return fmt.Sprintf("CREATE TABLE %s PARTITION OF %s%s %s%s;",
+			table.QualifiedName(), table.PartitionOf, body, table.PartitionBound, partitionBy)
+	}
+
+	return fmt.Sprintf("CREATE TABLE %s (\n%s\n)%s;", table.QualifiedName(), strings.Join(lines, ",\n"), partitionBy)
+}
+
+// Report whether a function can only be created once the tables exist: SQL
+// functions, which may read tables when they are parsed, and functions with
+// a table row type in their signature
+// @param function SchemaFunction
+// @return bool
+func functionNeedsTables(function SchemaFunction) bool {
+	return function.Language == "sql" || function.RowTypes
+}
+
+// Render DROP FUNCTION or DROP PROCEDURE
+// @param function SchemaFunction
+// @return string
+func renderDropFunction(function SchemaFunction) string {
+	return fmt.Sprintf("DROP %s IF EXISTS %s;", strings.ToUpper(functionObject(function)), function.Signature())
+}
+
+// Render CREATE VIEW or CREATE MATERIALIZED VIEW
+// @param view SchemaView
+// @return string
+func renderCreateView(view SchemaView) string {
+	return fmt.Sprintf("CREATE %s %s AS\n%s", viewKeyword(view),
+		pgx.Identifier{view.Schema, view.Name}.Sanitize(), ensureSemicolon(view.Definition))
+}
+
+// Render DROP VIEW or DROP MATERIALIZED VIEW
+// @param view SchemaView
+// @return string
+func renderDropView(view SchemaView) string {
+	return fmt.Sprintf("DROP %s IF EXISTS %s;", viewKeyword(view), pgx.Identifier{view.Schema, view.Name}.Sanitize())
+}
+
+// SQL keyword of a view kind
+// @param view SchemaView
+// @return string
+func viewKeyword(view SchemaView) string {
+	if view.Materialized {
+		return "MATERIALIZED VIEW"
+	}
+	return "VIEW"
+}
+
+// Render a column as it appears inside CREATE TABLE
+// @param column SchemaColumn
+// @return string
+func renderColumnDefinition(column SchemaColumn) string {
+	parts := []string{pgx.Identifier{column.Name}.Sanitize(), column.Type}
+
+	switch {
+	case column.Generated:
+		parts = append(parts, fmt.Sprintf("GENERATED ALWAYS AS (%s) STORED", column.Default))
+	case column.Identity != "":
+		parts = append(parts, identityClause(column.Identity))
+	case column.Default != "":
+		parts = append(parts, "DEFAULT "+column.Default)
+	}
+
+	if column.NotNull && column.Identity == "" {
+		parts = append(parts, "NOT NULL")
+	}
+
+	return strings.Join(parts, " ")
+}
+
+// Quote a string as a SQL literal
+// @param value string
+// @return string
+func quoteLiteral(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", "''") + "'"
+}
+
+// Make sure a statement ends with a semicolon
+// @param statement string
+// @return string
+func ensureSemicolon(statement string) string {
+	statement = strings.TrimSpace(statement)
+	if !strings.HasSuffix(statement, ";") {
+		statement += ";"
+	}
+	return statement
+}
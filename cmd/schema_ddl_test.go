@@ -0,0 +1,74 @@
+package migroCMD
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRenderSchemaDDLPartitions(t *testing.T) {
+	snapshot := &SchemaSnapshot{
+		Tables: []SchemaTable{
+			{
+				Schema:       "public",
+				Name:         "events",
+				PartitionKey: "RANGE (created_at)",
+				Columns:      []SchemaColumn{{Name: "created_at", Type: "timestamp with time zone", NotNull: true}},
+				Indexes:      []SchemaIndex{{Name: "events_created_at_idx", Definition: "CREATE INDEX events_created_at_idx ON ONLY public.events USING btree (created_at)"}},
+			},
+			{
+				Schema:         "public",
+				Name:           "events_2024",
+				PartitionOf:    `"public"."events"`,
+				PartitionBound: "FOR VALUES FROM ('2024-01-01') TO ('2025-01-01')",
+				Indexes: []SchemaIndex{{
+					Name:       "events_2024_created_at_idx",
+					Definition: "CREATE INDEX events_2024_created_at_idx ON public.events_2024 USING btree (created_at)",
+					Parent:     `"public"."events_created_at_idx"`,
+				}},
+			},
+			{
+				Schema:         "public",
+				Name:           "events_default",
+				PartitionOf:    `"public"."events"`,
+				PartitionBound: "DEFAULT",
+				Constraints:    []SchemaConstraint{{Name: "positive", Type: "c", Definition: "CHECK (id > 0)"}},
+			},
+		},
+	}
+
+	up, _ := renderSchemaDDL(snapshot)
+	want := []string{
+		"CREATE TABLE \"public\".\"events\" (\n    \"created_at\" timestamp with time zone NOT NULL\n) PARTITION BY RANGE (created_at);",
+		"CREATE TABLE \"public\".\"events_2024\" PARTITION OF \"public\".\"events\" FOR VALUES FROM ('2024-01-01') TO ('2025-01-01');",
+		"CREATE TABLE \"public\".\"events_default\" PARTITION OF \"public\".\"events\" (\n    CONSTRAINT \"positive\" CHECK (id > 0)\n) DEFAULT;",
+		"CREATE INDEX events_created_at_idx ON ONLY public.events USING btree (created_at);",
+		"CREATE INDEX events_2024_created_at_idx ON public.events_2024 USING btree (created_at);",
+		"ALTER INDEX \"public\".\"events_created_at_idx\" ATTACH PARTITION \"public\".\"events_2024_created_at_idx\";",
+	}
+	if !reflect.DeepEqual(up, want) {
+		t.Errorf("renderSchemaDDL() up =\n%q\nwant\n%q", up, want)
+	}
+}
+
+func TestRenderSchemaDDLFunctionOrder(t *testing.T) {
+	snapshot := &SchemaSnapshot{
+		Functions: []SchemaFunction{
+			{Schema: "public", Name: "active_users", Language: "sql", Definition: "CREATE FUNCTION public.active_users() RETURNS bigint LANGUAGE sql AS $$ SELECT count(*) FROM users $$"},
+			{Schema: "public", Name: "touch", Language: "plpgsql", Definition: "CREATE FUNCTION public.touch() RETURNS trigger LANGUAGE plpgsql AS $$ BEGIN RETURN NEW; END $$"},
+			{Schema: "public", Name: "user_label", Language: "plpgsql", RowTypes: true, Definition: "CREATE FUNCTION public.user_label(u users) RETURNS text LANGUAGE plpgsql AS $$ BEGIN RETURN u.name; END $$"},
+		},
+		Tables: []SchemaTable{{Schema: "public", Name: "users", Columns: []SchemaColumn{{Name: "name", Type: "text"}}}},
+	}
+
+	up, _ := renderSchemaDDL(snapshot)
+	want := []string{
+		"SET LOCAL check_function_bodies = false;",
+		"CREATE FUNCTION public.touch() RETURNS trigger LANGUAGE plpgsql AS $$ BEGIN RETURN NEW; END $$;",
+		"CREATE TABLE \"public\".\"users\" (\n    \"name\" text\n);",
+		"CREATE FUNCTION public.active_users() RETURNS bigint LANGUAGE sql AS $$ SELECT count(*) FROM users $$;",
+		"CREATE FUNCTION public.user_label(u users) RETURNS text LANGUAGE plpgsql AS $$ BEGIN RETURN u.name; END $$;",
+	}
+	if !reflect.DeepEqual(up, want) {
+		t.Errorf("renderSchemaDDL() up =\n%q\nwant\n%q", up, want)
+	}
+}
@@ -0,0 +1,244 @@
+package migroCMD
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Kinds of schema differences, seen from the expected schema
+const (
+	diffMissing = "missing" // expected, but not in the compared schema
+	diffExtra   = "extra"   // in the compared schema, but not expected
+	diffChanged = "changed" // in both, with a different definition
+)
+
+// SchemaDifference is one object that differs between two schema snapshots
+type SchemaDifference struct {
+	Change string // missing, extra or changed
+	Object string // schema, enum, sequence, function, procedure, table, column, constraint, index, trigger, view or materialized view
+	Name   string // schema-qualified name; columns, constraints and indexes are prefixed with their table
+	Detail string // what changed, for changed objects
+}
+
+// Compare two schema snapshots object by object. Objects are matched by
+// name, so a renamed object shows up as one missing and one extra object.
+// @param expected *SchemaSnapshot
+// @param actual *SchemaSnapshot
+// @return []SchemaDifference
+func diffSchemas(expected, actual *SchemaSnapshot) []SchemaDifference {
+	var differences []SchemaDifference
+	add := func(change, object, name, detail string) {
+		differences = append(differences, SchemaDifference{Change: change, Object: object, Name: name, Detail: detail})
+	}
+
+	diffNamed(expected.Schemas, actual.Schemas, func(schema string) string { return schema },
+		func(change string, schema string) { add(change, "schema", schema, "") }, nil)
+
+	diffNamed(expected.Enums, actual.Enums, func(enum SchemaEnum) string {
+		return TableName{Schema: enum.Schema, Name: enum.Name}.String()
+	}, func(change string, enum SchemaEnum) {
+		add(change, "enum", TableName{Schema: enum.Schema, Name: enum.Name}.String(), "")
+	}, func(want, got SchemaEnum) {
+		if detail := describeChanges(
+			fieldChange{"labels", strings.Join(want.Labels, ", "), strings.Join(got.Labels, ", ")},
+		); detail != "" {
+			add(diffChanged, "enum", TableName{Schema: want.Schema, Name: want.Name}.String(), detail)
+		}
+	})
+
+	diffNamed(expected.Sequences, actual.Sequences, func(seq SchemaSequence) string {
+		return TableName{Schema: seq.Schema, Name: seq.Name}.String()
+	}, func(change string, seq SchemaSequence) {
+		add(change, "sequence", TableName{Schema: seq.Schema, Name: seq.Name}.String(), "")
+	}, func(want, got SchemaSequence) {
+		if detail := describeChanges(
+			fieldChange{"type", want.DataType, got.DataType},
+			fieldChange{"start", fmt.Sprint(want.Start), fmt.Sprint(got.Start)},
+			fieldChange{"increment", fmt.Sprint(want.Increment), fmt.Sprint(got.Increment)},
+			fieldChange{"min", fmt.Sprint(want.Min), fmt.Sprint(got.Min)},
+			fieldChange{"max", fmt.Sprint(want.Max), fmt.Sprint(got.Max)},
+			fieldChange{"cache", fmt.Sprint(want.Cache), fmt.Sprint(got.Cache)},
+			fieldChange{"cycle", fmt.Sprint(want.Cycle), fmt.Sprint(got.Cycle)},
+			fieldChange{"owned by", want.OwnedBy, got.OwnedBy},
+		); detail != "" {
+			add(diffChanged, "sequence", TableName{Schema: want.Schema, Name: want.Name}.String(), detail)
+		}
+	})
+
+	diffNamed(expected.Functions, actual.Functions, functionKey, func(change string, function SchemaFunction) {
+		add(change, functionObject(function), functionKey(function), "")
+	}, func(want, got SchemaFunction) {
+		if normalizeDefinition(want.Definition) != normalizeDefinition(got.Definition) {
+			add(diffChanged, functionObject(want), functionKey(want), "definition differs")
+		}
+	})
+
+	diffNamed(expected.Tables, actual.Tables, func(table SchemaTable) string {
+		return TableName{Schema: table.Schema, Name: table.Name}.String()
+	}, func(change string, table SchemaTable) {
+		add(change, "table", TableName{Schema: table.Schema, Name: table.Name}.String(), "")
+	}, func(want, got SchemaTable) {
+		differences = append(differences, diffTables(want, got)...)
+	})
+
+	diffNamed(expected.Views, actual.Views, func(view SchemaView) string {
+		return TableName{Schema: view.Schema, Name: view.Name}.String()
+	}, func(change string, view SchemaView) {
+		add(change, viewObject(view), TableName{Schema: view.Schema, Name: view.Name}.String(), "")
+	}, func(want, got SchemaView) {
+		name := TableName{Schema: want.Schema, Name: want.Name}.String()
+		if want.Materialized != got.Materialized {
+			add(diffChanged, viewObject(want), name, fmt.Sprintf("kind: %s → %s", viewObject(want), viewObject(got)))
+		} else if normalizeDefinition(want.Definition) != normalizeDefinition(got.Definition) {
+			add(diffChanged, viewObject(want), name, "definition differs")
+		}
+	})
+
+	return differences
+}
+
+// Compare the columns, constraints and indexes of a table present in both schemas
+// @param want SchemaTable
+// @param got SchemaTable
+// @return []SchemaDifference
+func diffTables(want, got SchemaTable) []SchemaDifference {
+	var differences []SchemaDifference
+	prefix := TableName{Schema: want.Schema, Name: want.Name}.String() + "."
+	add := func(change, object, name, detail string) {
+		differences = append(differences, SchemaDifference{Change: change, Object: object, Name: prefix + name, Detail: detail})
+	}
+
+	diffNamed(want.Columns, got.Columns, func(column SchemaColumn) string { return column.Name },
+		func(change string, column SchemaColumn) { add(change, "column", column.Name, column.Type) },
+		func(want, got SchemaColumn) {
+			if detail := describeChanges(
+				fieldChange{"type", want.Type, got.Type},
+				fieldChange{"not null", fmt.Sprint(want.NotNull), fmt.Sprint(got.NotNull)},
+				fieldChange{"default", want.Default, got.Default},
+				fieldChange{"identity", want.Identity, got.Identity},
+				fieldChange{"generated", fmt.Sprint(want.Generated), fmt.Sprint(got.Generated)},
+			); detail != "" {
+				add(diffChanged, "column", want.Name, detail)
+			}
+		})
+
+	diffNamed(want.Constraints, got.Constraints, func(constraint SchemaConstraint) string { return constraint.Name },
+		func(change string, constraint SchemaConstraint) {
+			add(change, "constraint", constraint.Name, constraint.Definition)
+		},
+		func(want, got SchemaConstraint) {
+			if want.Definition != got.Definition {
+				add(diffChanged, "constraint", want.Name, fmt.Sprintf("%s → %s", want.Definition, got.Definition))
+			}
+		})
+
+	diffNamed(want.Indexes, got.Indexes, func(index SchemaIndex) string { return index.Name },
+		func(change string, index SchemaIndex) { add(change, "index", index.Name, index.Definition) },
+		func(want, got SchemaIndex) {
+			if want.Definition != got.Definition {
+				add(diffChanged, "index", want.Name, fmt.Sprintf("%s → %s", want.Definition, got.Definition))
+			}
+		})
+
+	diffNamed(want.Triggers, got.Triggers, func(trigger SchemaTrigger) string { return trigger.Name },
+		func(change string, trigger SchemaTrigger) { add(change, "trigger", trigger.Name, trigger.Definition) },
+		func(want, got SchemaTrigger) {
+			if want.Definition != got.Definition {
+				add(diffChanged, "trigger", want.Name, fmt.Sprintf("%s → %s", want.Definition, got.Definition))
+			}
+		})
+
+	return differences
+}
+
+// Match two lists of objects by key. onlyIn is called with diffMissing for
+// objects only in expected and with diffExtra for objects only in actual;
+// both, when not nil, for objects in both lists. Expected order is kept,
+// extra objects come last.
+// @param expected []T
+// @param actual []T
+// @param key func(T) string
+// @param onlyIn func(string, T)
+// @param both func(T, T)
+func diffNamed[T any](expected, actual []T, key func(T) string, onlyIn func(string, T), both func(T, T)) {
+	byKey := make(map[string]T, len(actual))
+	for _, object := range actual {
+		byKey[key(object)] = object
+	}
+	seen := make(map[string]bool, len(expected))
+	for _, object := range expected {
+		seen[key(object)] = true
+		other, ok := byKey[key(object)]
+		if !ok {
+			onlyIn(diffMissing, object)
+			continue
+		}
+		if both != nil {
+			both(object, other)
+		}
+	}
+	for _, object := range actual {
+		if !seen[key(object)] {
+			onlyIn(diffExtra, object)
+		}
+	}
+}
+
+// fieldChange is a property of an object in the expected and actual schema
+type fieldChange struct {
+	name     string
+	expected string
+	actual   string
+}
+
+// Describe the fields that differ, e.g. "type: integer → bigint"
+// @param fields ...fieldChange
+// @return string - empty if all fields are equal
+func describeChanges(fields ...fieldChange) string {
+	var changes []string
+	for _, field := range fields {
+		if field.expected == field.actual {
+			continue
+		}
+		expected, actual := field.expected, field.actual
+		if expected == "" {
+			expected = "(none)"
+		}
+		if actual == "" {
+			actual = "(none)"
+		}
+		changes = append(changes, fmt.Sprintf("%s: %s → %s", field.name, expected, actual))
+	}
+	return strings.Join(changes, "; ")
+}
+
+// Key of a function: schema.name(argument types), as overloads share a name
+// @param function SchemaFunction
+// @return string
+func functionKey(function SchemaFunction) string {
+	return fmt.Sprintf("%s(%s)", TableName{Schema: function.Schema, Name: function.Name}, function.Arguments)
+}
+
+// Object name of a function for differences
+// @param function SchemaFunction
+// @return string
+func functionObject(function SchemaFunction) string {
+	if function.Procedure {
+		return "procedure"
+	}
+	return "function"
+}
+
+// Object name of a view for differences
+// @param view SchemaView
+// @return string
+func viewObject(view SchemaView) string {
+	return strings.ToLower(viewKeyword(view))
+}
+
+// Collapse whitespace so formatting alone does not count as a change
+// @param definition string
+// @return string
+func normalizeDefinition(definition string) string {
+	return strings.TrimSuffix(strings.Join(strings.Fields(definition), " "), ";")
+}
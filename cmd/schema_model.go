@@ -0,0 +1,536 @@
+package migroCMD
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// MigrationOrigin is the migration file that first created a table or view
+type MigrationOrigin struct {
+	File string
+	Kind string // table or view
+}
+
+// migrationRelation is a table or view as the migration files leave it
+type migrationRelation struct {
+	Name    TableName
+	Columns []string
+	Origin  MigrationOrigin // File is empty for tables only seen in ALTER TABLE
+}
+
+// migrationSchema is the schema the Up sections of the migration files
+// build, replayed statement by statement without a database. It follows
+// CREATE, ALTER ... RENAME, SET SCHEMA, ADD/DROP COLUMN, ALTER TYPE and
+// DROP for tables, views, enum types and schemas; other statements are
+// ignored.
+type migrationSchema struct {
+	defaultSchema string
+	relations     map[string]*migrationRelation // by schema.name
+	types         map[TableName][]string        // labels of enum types, nil for other types
+}
+
+// Keywords that start a table constraint rather than a column
+var tableConstraintKeywords = map[string]bool{
+	"CONSTRAINT": true,
+	"PRIMARY":    true,
+	"FOREIGN":    true,
+	"UNIQUE":     true,
+	"CHECK":      true,
+	"EXCLUDE":    true,
+	"LIKE":       true,
+}
+
+// Create an empty schema model
+// @param defaultSchema string - schema of unqualified names
+// @return *migrationSchema
+func newMigrationSchema(defaultSchema string) *migrationSchema {
+	return &migrationSchema{
+		defaultSchema: defaultSchema,
+		relations:     make(map[string]*migrationRelation),
+		types:         make(map[TableName][]string),
+	}
+}
+
+// Build the schema model of the migration files in a directory, applying
+// their Up sections in version order. Files that cannot be parsed are
+// skipped with a warning.
+// @param migrationDir string
+// @param defaultSchema string
+// @return *migrationSchema, error
+func loadMigrationSchema(migrationDir, defaultSchema string) (*migrationSchema, error) {
+	matches, err := filepath.Glob(filepath.Join(migrationDir, "[0-9]*.sql"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to glob migration files: %w", err)
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		first, _, _ := parseMigrationFileName(matches[i])
+		second, _, _ := parseMigrationFileName(matches[j])
+		return first < second
+	})
+
+	schema := newMigrationSchema(defaultSchema)
+	for _, file := range matches {
+		migration, err := readMigrationFile(file)
+		if err != nil {
+			// Continue with the other files even if one fails
+			infof("⚠️  Warning: %v\n", err)
+			continue
+		}
+		// A StatementBegin block may hold several statements
+		for _, statement := range migration.Up {
+			if err := schema.apply(statement, filepath.Base(file)); err != nil {
+				infof("⚠️  Warning: Failed to parse %s: %v\n", file, err)
+				break
+			}
+		}
+	}
+
+	return schema, nil
+}
+
+// Look up a table; views are not tables
+// @param name TableName
+// @return *migrationRelation, bool
+func (s *migrationSchema) table(name TableName) (*migrationRelation, bool) {
+	relation, ok := s.relations[name.String()]
+	if !ok || relation.Origin.Kind != "table" {
+		return nil, false
+	}
+	return relation, true
+}
+
+// Return the file that created each table and view, where it is known
+// @return map[TableName]MigrationOrigin
+func (s *migrationSchema) origins() map[TableName]MigrationOrigin {
+	origins := make(map[TableName]MigrationOrigin)
+	for _, relation := range s.relations {
+		if relation.Origin.File != "" {
+			origins[relation.Name] = relation.Origin
+		}
+	}
+	return origins
+}
+
+// Apply the statements of a SQL text
+// @param sql string
+// @param file string - migration file the statements come from
+// @return error
+func (s *migrationSchema) apply(sql, file string) error {
+	statements, err := splitSQLStatements(sql)
+	if err != nil {
+		return err
+	}
+	for _, statement := range statements {
+		s.applyStatement(&sqlCursor{tokens: statement.Tokens}, file)
+	}
+	return nil
+}
+
+// Apply one statement
+// @param c *sqlCursor
+// @param file string
+func (s *migrationSchema) applyStatement(c *sqlCursor, file string) {
+	switch {
+	case c.acceptKeywords("CREATE"):
+		s.applyCreate(c, file)
+	case c.acceptKeywords("ALTER", "TABLE"), c.acceptKeywords("ALTER", "VIEW"), c.acceptKeywords("ALTER", "MATERIALIZED", "VIEW"):
+		s.applyAlterRelation(c)
+	case c.acceptKeywords("ALTER", "TYPE"):
+		s.applyAlterType(c)
+	case c.acceptKeywords("ALTER", "SCHEMA"):
+		s.applyAlterSchema(c)
+	case c.acceptKeywords("DROP"):
+		s.applyDrop(c)
+	}
+}
+
+// Apply CREATE TABLE, CREATE [MATERIALIZED] VIEW and CREATE TYPE
+// @param c *sqlCursor - after CREATE
+// @param file string
+func (s *migrationSchema) applyCreate(c *sqlCursor, file string) {
+	c.acceptKeywords("OR", "REPLACE")
+	temporary := false
+	for c.peek().isKeyword("GLOBAL", "LOCAL", "TEMP", "TEMPORARY", "UNLOGGED", "RECURSIVE") {
+		temporary = temporary || c.next().isKeyword("TEMP", "TEMPORARY")
+	}
+	// Temporary objects are gone when the migration ends
+	if temporary {
+		return
+	}
+
+	switch {
+	case c.acceptKeywords("TABLE"):
+		s.createTable(c, file)
+	case c.acceptKeywords("VIEW"), c.acceptKeywords("MATERIALIZED", "VIEW"):
+		s.createView(c, file)
+	case c.acceptKeywords("TYPE"):
+		s.createType(c)
+	}
+}
+
+// Apply CREATE TABLE, including LIKE, INHERITS and PARTITION OF columns
+// @param c *sqlCursor - after TABLE
+// @param file string
+func (s *migrationSchema) createTable(c *sqlCursor, file string) {
+	ifNotExists := c.acceptKeywords("IF", "NOT", "EXISTS")
+	name, ok := c.qualifiedName(s.defaultSchema)
+	if !ok || (ifNotExists && s.relations[name.String()] != nil) {
+		return
+	}
+
+	var columns []string
+	if c.acceptKeywords("PARTITION", "OF") {
+		if parent, ok := c.qualifiedName(s.defaultSchema); ok {
+			columns = s.columnsOf(parent)
+		}
+	} else if elements, ok := c.parenthesizedList(); ok {
+		for _, element := range elements {
+			first := element[0]
+			switch {
+			case first.isKeyword("LIKE"):
+				source := &sqlCursor{tokens: element[1:]}
+				if name, ok := source.qualifiedName(s.defaultSchema); ok {
+					columns = appendColumns(columns, s.columnsOf(name)...)
+				}
+			case first.Kind == sqlWord && tableConstraintKeywords[strings.ToUpper(first.Text)]:
+				continue
+			case first.isIdentifier():
+				columns = appendColumns(columns, first.Value)
+			}
+		}
+
+		// Inherited columns come before the table's own columns
+		if c.acceptKeywords("INHERITS") {
+			parents, _ := c.parenthesizedList()
+			var inherited []string
+			for _, parent := range parents {
+				if name, ok := (&sqlCursor{tokens: parent}).qualifiedName(s.defaultSchema); ok {
+					inherited = appendColumns(inherited, s.columnsOf(name)...)
+				}
+			}
+			columns = appendColumns(inherited, columns...)
+		}
+	}
+
+	s.relations[name.String()] = &migrationRelation{
+		Name:    name,
+		Columns: columns,
+		Origin:  MigrationOrigin{File: file, Kind: "table"},
+	}
+}
+
+// Apply CREATE [OR REPLACE] [MATERIALIZED] VIEW. Only an explicit column
+// list is known; columns coming from the query are not tracked.
+// @param c *sqlCursor - after VIEW
+// @param file string
+func (s *migrationSchema) createView(c *sqlCursor, file string) {
+	ifNotExists := c.acceptKeywords("IF", "NOT", "EXISTS")
+	name, ok := c.qualifiedName(s.defaultSchema)
+	if !ok {
+		return
+	}
+	existing := s.relations[name.String()]
+	if ifNotExists && existing != nil {
+		return
+	}
+
+	var columns []string
+	if items, ok := c.parenthesizedList(); ok {
+		for _, item := range items {
+			if item[0].isIdentifier() {
+				columns = appendColumns(columns, item[0].Value)
+			}
+		}
+	}
+
+	// CREATE OR REPLACE keeps the file that first created the view
+	origin := MigrationOrigin{File: file, Kind: "view"}
+	if existing != nil && existing.Origin.Kind == "view" {
+		origin = existing.Origin
+	}
+	s.relations[name.String()] = &migrationRelation{Name: name, Columns: columns, Origin: origin}
+}
+
+// Apply CREATE TYPE; only the labels of enum types are kept
+// @param c *sqlCursor - after TYPE
+func (s *migrationSchema) createType(c *sqlCursor) {
+	name, ok := c.qualifiedName(s.defaultSchema)
+	if !ok {
+		return
+	}
+
+	var labels []string
+	if c.acceptKeywords("AS", "ENUM") {
+		items, _ := c.parenthesizedList()
+		labels = []string{}
+		for _, item := range items {
+			if item[0].Kind == sqlString {
+				labels = append(labels, item[0].stringValue())
+			}
+		}
+	}
+	s.types[name] = labels
+}
+
+// Apply ALTER TABLE, ALTER VIEW and ALTER MATERIALIZED VIEW
+// @param c *sqlCursor - after TABLE or VIEW
+func (s *migrationSchema) applyAlterRelation(c *sqlCursor) {
+	c.acceptKeywords("IF", "EXISTS")
+	c.acceptKeywords("ONLY")
+	name, ok := c.qualifiedName(s.defaultSchema)
+	if !ok {
+		return
+	}
+	c.acceptSymbol("*")
+
+	switch {
+	case c.acceptKeywords("RENAME", "TO"):
+		if newName, ok := c.identifier(); ok {
+			s.renameRelation(name, TableName{Schema: name.Schema, Name: newName})
+		}
+	case c.acceptKeywords("SET", "SCHEMA"):
+		if schema, ok := c.identifier(); ok {
+			s.renameRelation(name, TableName{Schema: schema, Name: name.Name})
+		}
+	case c.acceptKeywords("RENAME", "CONSTRAINT"):
+		// Constraints are not tracked
+	case c.acceptKeywords("RENAME"):
+		c.acceptKeywords("COLUMN")
+		column, ok := c.identifier()
+		if !ok || !c.acceptKeywords("TO") {
+			return
+		}
+		newColumn, ok := c.identifier()
+		relation := s.relations[name.String()]
+		if !ok || relation == nil {
+			return
+		}
+		for i := range relation.Columns {
+			if relation.Columns[i] == column {
+				relation.Columns[i] = newColumn
+			}
+		}
+	default:
+		for _, action := range c.commaSeparated() {
+			s.applyTableAction(name, &sqlCursor{tokens: action})
+		}
+	}
+}
+
+// Apply one action of ALTER TABLE; only ADD and DROP COLUMN change the model
+// @param name TableName
+// @param c *sqlCursor
+func (s *migrationSchema) applyTableAction(name TableName, c *sqlCursor) {
+	switch {
+	case c.acceptKeywords("ADD"):
+		if !c.acceptKeywords("COLUMN") && c.peek().Kind == sqlWord && tableConstraintKeywords[strings.ToUpper(c.peek().Text)] {
+			return
+		}
+		c.acceptKeywords("IF", "NOT", "EXISTS")
+		column, ok := c.identifier()
+		if !ok {
+			return
+		}
+		relation := s.relations[name.String()]
+		if relation == nil {
+			// A table created outside the migration files
+			relation = &migrationRelation{Name: name, Origin: MigrationOrigin{Kind: "table"}}
+			s.relations[name.String()] = relation
+		}
+		relation.Columns = appendColumns(relation.Columns, column)
+
+	case c.acceptKeywords("DROP"):
+		if c.acceptKeywords("CONSTRAINT") {
+			return
+		}
+		c.acceptKeywords("COLUMN")
+		c.acceptKeywords("IF", "EXISTS")
+		column, ok := c.identifier()
+		relation := s.relations[name.String()]
+		if !ok || relation == nil {
+			return
+		}
+		for i, existing := range relation.Columns {
+			if existing == column {
+				relation.Columns = append(relation.Columns[:i], relation.Columns[i+1:]...)
+				break
+			}
+		}
+	}
+}
+
+// Apply ALTER TYPE: RENAME TO, SET SCHEMA, ADD VALUE and RENAME VALUE
+// @param c *sqlCursor - after TYPE
+func (s *migrationSchema) applyAlterType(c *sqlCursor) {
+	name, ok := c.qualifiedName(s.defaultSchema)
+	if !ok {
+		return
+	}
+	labels, exists := s.types[name]
+	if !exists {
+		return
+	}
+
+	switch {
+	case c.acceptKeywords("RENAME", "TO"):
+		if newName, ok := c.identifier(); ok {
+			delete(s.types, name)
+			s.types[TableName{Schema: name.Schema, Name: newName}] = labels
+		}
+	case c.acceptKeywords("SET", "SCHEMA"):
+		if schema, ok := c.identifier(); ok {
+			delete(s.types, name)
+			s.types[TableName{Schema: schema, Name: name.Name}] = labels
+		}
+	case c.acceptKeywords("ADD", "VALUE"):
+		c.acceptKeywords("IF", "NOT", "EXISTS")
+		if c.peek().Kind != sqlString {
+			return
+		}
+		label := c.next().stringValue()
+		if contains(labels, label) {
+			return
+		}
+		position := len(labels)
+		after := c.acceptKeywords("AFTER")
+		if (after || c.acceptKeywords("BEFORE")) && c.peek().Kind == sqlString {
+			neighbour := c.next().stringValue()
+			for i, existing := range labels {
+				if existing == neighbour {
+					position = i
+					if after {
+						position++
+					}
+					break
+				}
+			}
+		}
+		s.types[name] = append(labels[:position], append([]string{label}, labels[position:]...)...)
+	case c.acceptKeywords("RENAME", "VALUE"):
+		if c.peek().Kind != sqlString {
+			return
+		}
+		old := c.next().stringValue()
+		if !c.acceptKeywords("TO") || c.peek().Kind != sqlString {
+			return
+		}
+		label := c.next().stringValue()
+		for i := range labels {
+			if labels[i] == old {
+				labels[i] = label
+			}
+		}
+	}
+}
+
+// Apply ALTER SCHEMA ... RENAME TO, which moves everything in the schema
+// @param c *sqlCursor - after SCHEMA
+func (s *migrationSchema) applyAlterSchema(c *sqlCursor) {
+	schema, ok := c.identifier()
+	if !ok || !c.acceptKeywords("RENAME", "TO") {
+		return
+	}
+	newSchema, ok := c.identifier()
+	if !ok {
+		return
+	}
+
+	for _, relation := range s.relationsIn(schema) {
+		s.renameRelation(relation.Name, TableName{Schema: newSchema, Name: relation.Name.Name})
+	}
+	for name, labels := range s.types {
+		if name.Schema == schema {
+			delete(s.types, name)
+			s.types[TableName{Schema: newSchema, Name: name.Name}] = labels
+		}
+	}
+}
+
+// Apply DROP TABLE, DROP [MATERIALIZED] VIEW, DROP TYPE and DROP SCHEMA
+// @param c *sqlCursor - after DROP
+func (s *migrationSchema) applyDrop(c *sqlCursor) {
+	switch {
+	case c.acceptKeywords("TABLE"), c.acceptKeywords("VIEW"), c.acceptKeywords("MATERIALIZED", "VIEW"):
+		c.acceptKeywords("IF", "EXISTS")
+		for _, item := range c.commaSeparated() {
+			if name, ok := (&sqlCursor{tokens: item}).qualifiedName(s.defaultSchema); ok {
+				delete(s.relations, name.String())
+			}
+		}
+	case c.acceptKeywords("TYPE"):
+		c.acceptKeywords("IF", "EXISTS")
+		for _, item := range c.commaSeparated() {
+			if name, ok := (&sqlCursor{tokens: item}).qualifiedName(s.defaultSchema); ok {
+				delete(s.types, name)
+			}
+		}
+	case c.acceptKeywords("SCHEMA"):
+		c.acceptKeywords("IF", "EXISTS")
+		for _, item := range c.commaSeparated() {
+			schema, ok := (&sqlCursor{tokens: item}).identifier()
+			if !ok {
+				continue
+			}
+			for _, relation := range s.relationsIn(schema) {
+				delete(s.relations, relation.Name.String())
+			}
+			for name := range s.types {
+				if name.Schema == schema {
+					delete(s.types, name)
+				}
+			}
+		}
+	}
+}
+
+// Move a table or view to a new name, keeping its columns and origin
+// @param from TableName
+// @param to TableName
+func (s *migrationSchema) renameRelation(from, to TableName) {
+	relation, ok := s.relations[from.String()]
+	if !ok {
+		return
+	}
+	delete(s.relations, from.String())
+	relation.Name = to
+	s.relations[to.String()] = relation
+}
+
+// Return the tables and views of a schema
+// @param schema string
+// @return []*migrationRelation
+func (s *migrationSchema) relationsIn(schema string) []*migrationRelation {
+	var relations []*migrationRelation
+	for _, relation := range s.relations {
+		if relation.Name.Schema == schema {
+			relations = append(relations, relation)
+		}
+	}
+	return relations
+}
+
+// Return a copy of the columns of a table, nil if it is unknown
+// @param name TableName
+// @return []string
+func (s *migrationSchema) columnsOf(name TableName) []string {
+	relation, ok := s.relations[name.String()]
+	if !ok {
+		return nil
+	}
+	return append([]string(nil), relation.Columns...)
+}
+
+// Append columns that are not in the list yet
+// @param columns []string
+// @param names ...string
+// @return []string
+func appendColumns(columns []string, names ...string) []string {
+	for _, name := range names {
+		if !contains(columns, name) {
+			columns = append(columns, name)
+		}
+	}
+	return columns
+}
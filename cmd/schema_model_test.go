@@ -0,0 +1,211 @@
+package migroCMD
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// Summary of a relation in the schema model, for comparing in tests
+type modelRelation struct {
+	Kind    string
+	File    string
+	Columns []string
+}
+
+// Summarize the relations of a schema model by schema.name
+func summarizeRelations(s *migrationSchema) map[string]modelRelation {
+	summary := make(map[string]modelRelation)
+	for name, relation := range s.relations {
+		summary[name] = modelRelation{Kind: relation.Origin.Kind, File: relation.Origin.File, Columns: relation.Columns}
+	}
+	return summary
+}
+
+func TestMigrationSchemaApply(t *testing.T) {
+	tests := []struct {
+		name       string
+		migrations []string // applied as 1.sql, 2.sql, ...
+		want       map[string]modelRelation
+		wantTypes  map[TableName][]string
+	}{
+		{
+			name: "create table on one line with constraints and comments",
+			migrations: []string{
+				`CREATE TABLE users (id bigint, "Email" text /* login */, org_id int, -- owner` + "\n" +
+					`PRIMARY KEY (id, org_id), CONSTRAINT users_email UNIQUE ("Email"), CHECK (id > 0))`,
+			},
+			want: map[string]modelRelation{
+				"public.users": {"table", "1.sql", []string{"id", "Email", "org_id"}},
+			},
+		},
+		{
+			name: "schema-qualified and quoted names",
+			migrations: []string{
+				`CREATE SCHEMA IF NOT EXISTS billing; CREATE TABLE billing."Invoice" (id int); CREATE TABLE IF NOT EXISTS billing."Invoice" (other int)`,
+			},
+			want: map[string]modelRelation{
+				"billing.Invoice": {"table", "1.sql", []string{"id"}},
+			},
+		},
+		{
+			name: "add, drop and rename columns",
+			migrations: []string{
+				"CREATE TABLE users (id int, name text, age int)",
+				"ALTER TABLE users ADD COLUMN email text, ADD nickname text, DROP COLUMN age",
+				"ALTER TABLE IF EXISTS ONLY users RENAME COLUMN name TO full_name; ALTER TABLE users RENAME nickname TO handle",
+			},
+			want: map[string]modelRelation{
+				"public.users": {"table", "1.sql", []string{"id", "full_name", "email", "handle"}},
+			},
+		},
+		{
+			name: "rename table, set schema and rename schema",
+			migrations: []string{
+				"CREATE TABLE accounts (id int); CREATE SCHEMA old",
+				"ALTER TABLE accounts RENAME TO customers",
+				"ALTER TABLE customers SET SCHEMA old",
+				"ALTER SCHEMA old RENAME TO crm",
+			},
+			want: map[string]modelRelation{
+				"crm.customers": {"table", "1.sql", []string{"id"}},
+			},
+		},
+		{
+			name: "drop tables, views and schemas",
+			migrations: []string{
+				"CREATE TABLE a (id int); CREATE TABLE b (id int); CREATE VIEW v AS SELECT id FROM a; CREATE SCHEMA s; CREATE TABLE s.c (id int)",
+				"DROP TABLE IF EXISTS a, b CASCADE; DROP VIEW v; DROP SCHEMA s CASCADE",
+			},
+			want: map[string]modelRelation{},
+		},
+		{
+			name: "views keep the file that first created them",
+			migrations: []string{
+				"CREATE VIEW active AS SELECT 1 AS id",
+				"CREATE OR REPLACE VIEW active AS SELECT 2 AS id; CREATE MATERIALIZED VIEW totals AS SELECT 1",
+			},
+			want: map[string]modelRelation{
+				"public.active": {"view", "1.sql", nil},
+				"public.totals": {"view", "2.sql", nil},
+			},
+		},
+		{
+			name: "inheritance, partitions and LIKE",
+			migrations: []string{
+				"CREATE TABLE base (id int, created_at timestamptz)",
+				"CREATE TABLE child (extra text) INHERITS (base); CREATE TABLE copy (LIKE base, note text)",
+				"CREATE TABLE events (id int, at date) PARTITION BY RANGE (at); CREATE TABLE events_2024 PARTITION OF events FOR VALUES FROM ('2024-01-01') TO ('2025-01-01')",
+			},
+			want: map[string]modelRelation{
+				"public.base":        {"table", "1.sql", []string{"id", "created_at"}},
+				"public.child":       {"table", "2.sql", []string{"id", "created_at", "extra"}},
+				"public.copy":        {"table", "2.sql", []string{"id", "created_at", "note"}},
+				"public.events":      {"table", "3.sql", []string{"id", "at"}},
+				"public.events_2024": {"table", "3.sql", []string{"id", "at"}},
+			},
+		},
+		{
+			name: "temporary tables and function bodies are ignored",
+			migrations: []string{
+				"CREATE TEMP TABLE scratch (id int)",
+				"CREATE FUNCTION f() RETURNS void AS $fn$ BEGIN CREATE TABLE fake (id int); END $fn$ LANGUAGE plpgsql",
+				"CREATE PROCEDURE p() BEGIN ATOMIC INSERT INTO t VALUES (1); END",
+			},
+			want: map[string]modelRelation{},
+		},
+		{
+			name: "columns added to unknown tables have no origin",
+			migrations: []string{
+				"ALTER TABLE legacy ADD COLUMN flag boolean",
+			},
+			want: map[string]modelRelation{
+				"public.legacy": {"table", "", []string{"flag"}},
+			},
+		},
+		{
+			name: "enum types",
+			migrations: []string{
+				"CREATE TYPE mood AS ENUM ('sad', 'ok'); CREATE TYPE pair AS (a int, b int)",
+				"ALTER TYPE mood ADD VALUE 'happy'; ALTER TYPE mood ADD VALUE IF NOT EXISTS 'meh' BEFORE 'ok'",
+				"ALTER TYPE mood RENAME VALUE 'sad' TO 'blue'; ALTER TYPE mood ADD VALUE 'first' AFTER 'blue'",
+			},
+			want: map[string]modelRelation{},
+			wantTypes: map[TableName][]string{
+				{Schema: "public", Name: "mood"}: {"blue", "first", "meh", "ok", "happy"},
+				{Schema: "public", Name: "pair"}: nil,
+			},
+		},
+		{
+			name: "rename and drop types",
+			migrations: []string{
+				"CREATE TYPE status AS ENUM ('on', 'off'); CREATE TYPE gone AS ENUM ('x'); CREATE SCHEMA app",
+				"ALTER TYPE status RENAME TO state; ALTER TYPE state SET SCHEMA app; DROP TYPE IF EXISTS gone",
+			},
+			want: map[string]modelRelation{},
+			wantTypes: map[TableName][]string{
+				{Schema: "app", Name: "state"}: {"on", "off"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schema := newMigrationSchema(defaultSchemaName)
+			for i, sql := range tt.migrations {
+				file := fmt.Sprintf("%d.sql", i+1)
+				if err := schema.apply(sql, file); err != nil {
+					t.Fatalf("apply(%s) error = %v", file, err)
+				}
+			}
+
+			if got := summarizeRelations(schema); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("relations =\n%v\nwant\n%v", got, tt.want)
+			}
+			wantTypes := tt.wantTypes
+			if wantTypes == nil {
+				wantTypes = map[TableName][]string{}
+			}
+			if !reflect.DeepEqual(schema.types, wantTypes) {
+				t.Errorf("types =\n%v\nwant\n%v", schema.types, wantTypes)
+			}
+		})
+	}
+}
+
+func TestLoadMigrationSchema(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"20240101000000_users.sql": "-- +goose Up\n-- users table\nCREATE TABLE users (\n  id int, -- key\n  name text\n);\n\n" +
+			"-- +goose Down\nDROP TABLE users;\n",
+		"20240102000000_touch.sql": "-- +goose Up\n-- +goose StatementBegin\nCREATE FUNCTION touch() RETURNS trigger AS $$\nBEGIN\n  NEW.updated_at = now();\n  RETURN NEW;\nEND;\n$$ LANGUAGE plpgsql;\n" +
+			"ALTER TABLE users ADD COLUMN updated_at timestamptz;\n-- +goose StatementEnd\n\n-- +goose Down\nDROP FUNCTION touch();\n",
+		"20240103000000_rename.sql": "-- +goose Up\nALTER TABLE users RENAME COLUMN name TO full_name; ALTER TABLE users RENAME TO people;\n\n-- +goose Down\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	schema, err := loadMigrationSchema(dir, defaultSchemaName)
+	if err != nil {
+		t.Fatalf("loadMigrationSchema() error = %v", err)
+	}
+	want := map[string]modelRelation{
+		"public.people": {"table", "20240101000000_users.sql", []string{"id", "full_name", "updated_at"}},
+	}
+	if got := summarizeRelations(schema); !reflect.DeepEqual(got, want) {
+		t.Errorf("relations =\n%v\nwant\n%v", got, want)
+	}
+
+	if _, ok := schema.table(TableName{Schema: "public", Name: "people"}); !ok {
+		t.Error("table(public.people) not found")
+	}
+	origins := schema.origins()
+	if origin := origins[TableName{Schema: "public", Name: "people"}]; origin.File != "20240101000000_users.sql" {
+		t.Errorf("origins()[public.people] = %v", origin)
+	}
+}
@@ -0,0 +1,118 @@
+package migroCMD
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Open a scratch database to replay migrations in. With scratchURL the
+// given database is used and must be empty; otherwise a temporary database
+// is created on the server of db and dropped again by the returned cleanup
+// function, which needs the CREATEDB privilege.
+// @param ctx context.Context
+// @param db *pgxpool.Pool
+// @param scratchURL string - connection URL of an empty database, or empty
+// @return *pgxpool.Pool, func(), error (scratch pool, cleanup)
+func openScratchDatabase(ctx context.Context, db *pgxpool.Pool, scratchURL string) (*pgxpool.Pool, func(), error) {
+	if scratchURL != "" {
+		scratch, err := pgxpool.New(ctx, scratchURL)
+		if err != nil {
+			return nil, nil, fmt.Errorf("❌ unable to parse scratch database URL: %w", err)
+		}
+		if err := scratch.Ping(ctx); err != nil {
+			scratch.Close()
+			return nil, nil, fmt.Errorf("❌ cannot connect to scratch database: %w", err)
+		}
+
+		snapshot, err := introspectSchema(ctx, scratch, nil)
+		if err != nil {
+			scratch.Close()
+			return nil, nil, fmt.Errorf("❌ failed to read scratch database: %w", err)
+		}
+		if len(snapshot.Schemas)+len(snapshot.Enums)+len(snapshot.Sequences)+len(snapshot.Tables)+len(snapshot.Views) > 0 {
+			scratch.Close()
+			return nil, nil, fmt.Errorf("❌ scratch database is not empty, migrations must be replayed into an empty database")
+		}
+		return scratch, scratch.Close, nil
+	}
+
+	name := fmt.Sprintf("migro_scratch_%d_%d", time.Now().Unix(), os.Getpid())
+	quoted := pgx.Identifier{name}.Sanitize()
+	if _, err := db.Exec(ctx, "CREATE DATABASE "+quoted+" TEMPLATE template0"); err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "42501" { // insufficient_privilege
+			return nil, nil, fmt.Errorf("❌ failed to create scratch database: %w\n"+
+				"💡 Grant CREATEDB to the database user, or pass --scratch-url with an empty database", err)
+		}
+		return nil, nil, fmt.Errorf("❌ failed to create scratch database: %w", err)
+	}
+	drop := func() {
+		// The command context may already be cancelled, the database must go anyway
+		if _, err := db.Exec(context.Background(), "DROP DATABASE IF EXISTS "+quoted); err != nil {
+			infof("⚠️  Failed to drop scratch database %s: %v\n", name, err)
+		}
+	}
+
+	poolConfig := db.Config()
+	poolConfig.ConnConfig.Database = name
+	poolConfig.MinConns = 0
+	scratch, err := pgxpool.NewWithConfig(ctx, poolConfig)
+	if err != nil {
+		drop()
+		return nil, nil, fmt.Errorf("❌ unable to create scratch connection pool: %w", err)
+	}
+	if err := scratch.Ping(ctx); err != nil {
+		scratch.Close()
+		drop()
+		return nil, nil, fmt.Errorf("❌ cannot connect to scratch database %s: %w", name, err)
+	}
+
+	return scratch, func() {
+		scratch.Close()
+		drop()
+	}, nil
+}
+
+// Apply migrations to a scratch database in version order
+// @param ctx context.Context
+// @param config *CONFIG
+// @param scratch *pgxpool.Pool
+// @param migrations []*Migration
+// @return *Migrator, error (migrator of the scratch database)
+func replayMigrations(ctx context.Context, config *CONFIG, scratch *pgxpool.Pool, migrations []*Migration) (*Migrator, error) {
+	migrator := NewMigrator(config, scratch)
+	if err := migrator.ensureVersionTable(ctx); err != nil {
+		return nil, err
+	}
+
+	for _, migration := range migrations {
+		infof("⬆️  Replaying %s\n", migration.FileName())
+		if err := migrator.applyUp(ctx, migration); err != nil {
+			return nil, err
+		}
+	}
+	return migrator, nil
+}
+
+// Read the schema of a database without migro's own tables
+// @param ctx context.Context
+// @param migrator *Migrator
+// @return *SchemaSnapshot, error
+func introspectMigratedSchema(ctx context.Context, migrator *Migrator) (*SchemaSnapshot, error) {
+	exclude, err := migrator.ownTables(ctx)
+	if err != nil {
+		return nil, err
+	}
+	snapshot, err := introspectSchema(ctx, migrator.db, exclude)
+	if err != nil {
+		return nil, fmt.Errorf("❌ failed to read schema: %w", err)
+	}
+	return snapshot, nil
+}
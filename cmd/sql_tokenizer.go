@@ -0,0 +1,519 @@
+package migroCMD
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Kinds of tokens in PostgreSQL SQL
+type sqlTokenKind int
+
+const (
+	sqlEnd              sqlTokenKind = iota // past the last token of a statement
+	sqlWord                                 // keyword or unquoted identifier
+	sqlQuotedIdentifier                     // "Name"
+	sqlString                               // '...', E'...', B'...', X'...', N'...', U&'...' or $tag$...$tag$
+	sqlNumber                               // 42, 3.14, 1e10
+	sqlParameter                            // $1
+	sqlPunctuation                          // ( ) [ ] , ; . :
+	sqlOperator                             // ::, =, <>, || and other operators
+)
+
+// Characters operators are made of
+const sqlOperatorChars = "+-*/<>=~!@#%^&|`?"
+
+// sqlToken is one token of a SQL text; comments and whitespace are skipped
+type sqlToken struct {
+	Kind  sqlTokenKind
+	Text  string // as written
+	Value string // identifiers: unquoted words folded to lower case, quoted ones unescaped
+	Start int    // byte offset of the token in the SQL text
+	End   int    // byte offset just after the token
+}
+
+// Check if the token is one of the keywords, in any case
+// @param words ...string
+// @return bool
+func (t sqlToken) isKeyword(words ...string) bool {
+	if t.Kind != sqlWord {
+		return false
+	}
+	for _, word := range words {
+		if strings.EqualFold(t.Text, word) {
+			return true
+		}
+	}
+	return false
+}
+
+// Check if the token is the punctuation character or operator
+// @param text string
+// @return bool
+func (t sqlToken) isSymbol(text string) bool {
+	return (t.Kind == sqlPunctuation || t.Kind == sqlOperator) && t.Text == text
+}
+
+// Check if the token can be an identifier
+// @return bool
+func (t sqlToken) isIdentifier() bool {
+	return t.Kind == sqlWord || t.Kind == sqlQuotedIdentifier
+}
+
+// sqlStatement is one statement of a SQL text, without its trailing semicolon
+type sqlStatement struct {
+	SQL    string
+	Tokens []sqlToken
+}
+
+// Split SQL text into statements at semicolons outside of strings,
+// quoted identifiers, comments, dollar-quoted bodies and BEGIN ATOMIC
+// blocks. Comments between statements are dropped.
+// @param sql string
+// @return []sqlStatement, error
+func splitSQLStatements(sql string) ([]sqlStatement, error) {
+	tokens, err := tokenizeSQL(sql)
+	if err != nil {
+		return nil, err
+	}
+
+	var statements []sqlStatement
+	add := func(tokens []sqlToken) {
+		if len(tokens) > 0 {
+			statements = append(statements, sqlStatement{
+				SQL:    sql[tokens[0].Start:tokens[len(tokens)-1].End],
+				Tokens: tokens,
+			})
+		}
+	}
+
+	start := 0
+	depth := 0 // nesting of BEGIN ATOMIC ... END function bodies and CASE ... END inside them
+	for i, token := range tokens {
+		switch {
+		case token.isKeyword("ATOMIC") && i > 0 && tokens[i-1].isKeyword("BEGIN"):
+			depth++
+		case depth > 0 && token.isKeyword("CASE"):
+			depth++
+		case depth > 0 && token.isKeyword("END"):
+			depth--
+		case depth == 0 && token.isSymbol(";"):
+			add(tokens[start:i])
+			start = i + 1
+		}
+	}
+	if depth > 0 {
+		return nil, fmt.Errorf("unterminated BEGIN ATOMIC block")
+	}
+	add(tokens[start:])
+
+	return statements, nil
+}
+
+// Split SQL text into tokens the way PostgreSQL's lexer does. Comments,
+// including nested /* */ comments, and whitespace are skipped.
+// @param sql string
+// @return []sqlToken, error
+func tokenizeSQL(sql string) ([]sqlToken, error) {
+	var tokens []sqlToken
+
+	i := 0
+	for i < len(sql) {
+		c := sql[i]
+		next := byte(0)
+		if i+1 < len(sql) {
+			next = sql[i+1]
+		}
+
+		start := i
+		kind := sqlOperator
+		value := ""
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f' || c == '\v':
+			i++
+			continue
+
+		case c == '-' && next == '-':
+			end := strings.IndexByte(sql[i:], '\n')
+			if end < 0 {
+				i = len(sql)
+			} else {
+				i += end + 1
+			}
+			continue
+
+		case c == '/' && next == '*':
+			end, ok := skipBlockComment(sql, i)
+			if !ok {
+				return nil, fmt.Errorf("unterminated /* comment at line %d", lineAt(sql, start))
+			}
+			i = end
+			continue
+
+		case c == '\'' || (strings.IndexByte("bBxXnN", c) >= 0 && next == '\''):
+			if c != '\'' {
+				i++
+			}
+			end, ok := scanQuoted(sql, i, '\'', false)
+			if !ok {
+				return nil, fmt.Errorf("unterminated quoted string at line %d", lineAt(sql, start))
+			}
+			kind, i = sqlString, end
+
+		case (c == 'e' || c == 'E') && next == '\'':
+			end, ok := scanQuoted(sql, i+1, '\'', true)
+			if !ok {
+				return nil, fmt.Errorf("unterminated quoted string at line %d", lineAt(sql, start))
+			}
+			kind, i = sqlString, end
+
+		case (c == 'u' || c == 'U') && next == '&' && i+2 < len(sql) && (sql[i+2] == '\'' || sql[i+2] == '"'):
+			// Unicode escapes are kept as written
+			quote := sql[i+2]
+			end, ok := scanQuoted(sql, i+2, quote, false)
+			if !ok {
+				return nil, fmt.Errorf("unterminated quoted string at line %d", lineAt(sql, start))
+			}
+			kind, i = sqlString, end
+			if quote == '"' {
+				kind = sqlQuotedIdentifier
+				value = strings.ReplaceAll(sql[start+3:end-1], `""`, `"`)
+			}
+
+		case c == '"':
+			end, ok := scanQuoted(sql, i, '"', false)
+			if !ok {
+				return nil, fmt.Errorf("unterminated quoted identifier at line %d", lineAt(sql, start))
+			}
+			kind, i = sqlQuotedIdentifier, end
+			value = strings.ReplaceAll(sql[start+1:end-1], `""`, `"`)
+
+		case c == '$' && isDigit(next):
+			i++
+			for i < len(sql) && isDigit(sql[i]) {
+				i++
+			}
+			kind = sqlParameter
+
+		case c == '$':
+			tag, ok := dollarQuoteTag(sql, i)
+			if !ok {
+				i++
+				break
+			}
+			end := strings.Index(sql[i+len(tag):], tag)
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated dollar-quoted string %s at line %d", tag, lineAt(sql, start))
+			}
+			kind, i = sqlString, i+len(tag)+end+len(tag)
+
+		case isDigit(c) || (c == '.' && isDigit(next)):
+			kind, i = sqlNumber, scanNumber(sql, i)
+
+		case isIdentifierStart(c):
+			for i < len(sql) && isIdentifierChar(sql[i]) {
+				i++
+			}
+			kind = sqlWord
+			value = strings.ToLower(sql[start:i])
+
+		case c == ':' && next == ':':
+			i += 2
+
+		case strings.IndexByte("()[],;.:", c) >= 0:
+			kind = sqlPunctuation
+			i++
+
+		case strings.IndexByte(sqlOperatorChars, c) >= 0:
+			// An operator never contains the start of a comment
+			for i < len(sql) && strings.IndexByte(sqlOperatorChars, sql[i]) >= 0 &&
+				!strings.HasPrefix(sql[i:], "--") && !strings.HasPrefix(sql[i:], "/*") {
+				i++
+			}
+
+		default:
+			i++
+		}
+
+		tokens = append(tokens, sqlToken{Kind: kind, Text: sql[start:i], Value: value, Start: start, End: i})
+	}
+
+	return tokens, nil
+}
+
+// Find the end of a /* */ comment, which may be nested
+// @param sql string
+// @param start int - offset of the opening /*
+// @return int, bool (offset after the comment, false if unterminated)
+func skipBlockComment(sql string, start int) (int, bool) {
+	depth := 0
+	for i := start; i+1 < len(sql); i++ {
+		switch {
+		case sql[i] == '/' && sql[i+1] == '*':
+			depth++
+			i++
+		case sql[i] == '*' && sql[i+1] == '/':
+			depth--
+			i++
+			if depth == 0 {
+				return i + 1, true
+			}
+		}
+	}
+	return 0, false
+}
+
+// Find the end of a quoted string or identifier, where a doubled quote
+// stands for itself and, in E'...' strings, a backslash escapes any character
+// @param sql string
+// @param start int - offset of the opening quote
+// @param quote byte
+// @param backslash bool - whether backslash escapes are allowed
+// @return int, bool (offset after the closing quote, false if unterminated)
+func scanQuoted(sql string, start int, quote byte, backslash bool) (int, bool) {
+	for i := start + 1; i < len(sql); i++ {
+		switch {
+		case backslash && sql[i] == '\\':
+			i++
+		case sql[i] == quote:
+			if i+1 < len(sql) && sql[i+1] == quote {
+				i++
+				continue
+			}
+			return i + 1, true
+		}
+	}
+	return 0, false
+}
+
+// Read the $tag$ opening a dollar-quoted string
+// @param sql string
+// @param start int - offset of the first $
+// @return string, bool (the tag including both dollars, false if this is no dollar quote)
+func dollarQuoteTag(sql string, start int) (string, bool) {
+	i := start + 1
+	if i < len(sql) && isIdentifierStart(sql[i]) {
+		for i < len(sql) && isIdentifierChar(sql[i]) && sql[i] != '$' {
+			i++
+		}
+	}
+	if i < len(sql) && sql[i] == '$' {
+		return sql[start : i+1], true
+	}
+	return "", false
+}
+
+// Find the end of a numeric constant such as 42, 1_000, 3.14 or 1.5e-3
+// @param sql string
+// @param start int
+// @return int
+func scanNumber(sql string, start int) int {
+	i := start
+	for i < len(sql) && (isDigit(sql[i]) || sql[i] == '_') {
+		i++
+	}
+	if i < len(sql) && sql[i] == '.' && !strings.HasPrefix(sql[i:], "..") {
+		i++
+		for i < len(sql) && (isDigit(sql[i]) || sql[i] == '_') {
+			i++
+		}
+	}
+	if i < len(sql) && (sql[i] == 'e' || sql[i] == 'E') {
+		j := i + 1
+		if j < len(sql) && (sql[j] == '+' || sql[j] == '-') {
+			j++
+		}
+		if j < len(sql) && isDigit(sql[j]) {
+			i = j
+			for i < len(sql) && isDigit(sql[i]) {
+				i++
+			}
+		}
+	}
+	return i
+}
+
+// Line number of a byte offset, for error messages
+// @param sql string
+// @param offset int
+// @return int
+func lineAt(sql string, offset int) int {
+	return strings.Count(sql[:offset], "\n") + 1
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// Identifiers start with a letter, an underscore or any non-ASCII byte
+func isIdentifierStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || c >= 0x80
+}
+
+func isIdentifierChar(c byte) bool {
+	return isIdentifierStart(c) || isDigit(c) || c == '$'
+}
+
+// sqlCursor reads the tokens of a statement from left to right
+type sqlCursor struct {
+	tokens []sqlToken
+	pos    int
+}
+
+// Return the next token without consuming it; its kind is sqlEnd at the end
+// @return sqlToken
+func (c *sqlCursor) peek() sqlToken {
+	if c.pos >= len(c.tokens) {
+		return sqlToken{}
+	}
+	return c.tokens[c.pos]
+}
+
+// Consume and return the next token
+// @return sqlToken
+func (c *sqlCursor) next() sqlToken {
+	token := c.peek()
+	if c.pos < len(c.tokens) {
+		c.pos++
+	}
+	return token
+}
+
+// Consume the next tokens if they are exactly these keywords
+// @param words ...string
+// @return bool
+func (c *sqlCursor) acceptKeywords(words ...string) bool {
+	for i, word := range words {
+		if c.pos+i >= len(c.tokens) || !c.tokens[c.pos+i].isKeyword(word) {
+			return false
+		}
+	}
+	c.pos += len(words)
+	return true
+}
+
+// Consume the next token if it is the punctuation character or operator
+// @param text string
+// @return bool
+func (c *sqlCursor) acceptSymbol(text string) bool {
+	if c.peek().isSymbol(text) {
+		c.pos++
+		return true
+	}
+	return false
+}
+
+// Consume an identifier
+// @return string, bool (the normalized name, false if the next token is no identifier)
+func (c *sqlCursor) identifier() (string, bool) {
+	if !c.peek().isIdentifier() {
+		return "", false
+	}
+	return c.next().Value, true
+}
+
+// Consume a possibly qualified name; of database.schema.name only the
+// schema and name are kept
+// @param defaultSchema string - schema of unqualified names
+// @return TableName, bool
+func (c *sqlCursor) qualifiedName(defaultSchema string) (TableName, bool) {
+	first, ok := c.identifier()
+	if !ok {
+		return TableName{}, false
+	}
+	parts := []string{first}
+	for c.peek().isSymbol(".") && c.pos+1 < len(c.tokens) && c.tokens[c.pos+1].isIdentifier() {
+		c.pos++
+		part, _ := c.identifier()
+		parts = append(parts, part)
+	}
+	if len(parts) == 1 {
+		return TableName{Schema: defaultSchema, Name: first}, true
+	}
+	return TableName{Schema: parts[len(parts)-2], Name: parts[len(parts)-1]}, true
+}
+
+// Consume a parenthesized list and return its non-empty comma-separated items
+// @return [][]sqlToken, bool (false if the next token is no opening parenthesis or it is never closed)
+func (c *sqlCursor) parenthesizedList() ([][]sqlToken, bool) {
+	if !c.peek().isSymbol("(") {
+		return nil, false
+	}
+	start := c.pos
+	c.pos++
+
+	var items [][]sqlToken
+	itemStart := c.pos
+	depth := 1
+	for c.pos < len(c.tokens) {
+		token := c.next()
+		switch {
+		case token.isSymbol("(") || token.isSymbol("["):
+			depth++
+		case token.isSymbol(")") || token.isSymbol("]"):
+			depth--
+			if depth == 0 {
+				if c.pos-1 > itemStart {
+					items = append(items, c.tokens[itemStart:c.pos-1])
+				}
+				return items, true
+			}
+		case depth == 1 && token.isSymbol(","):
+			if c.pos-1 > itemStart {
+				items = append(items, c.tokens[itemStart:c.pos-1])
+			}
+			itemStart = c.pos
+		}
+	}
+	c.pos = start
+	return nil, false
+}
+
+// Split the remaining tokens at commas outside parentheses, dropping empty items
+// @return [][]sqlToken
+func (c *sqlCursor) commaSeparated() [][]sqlToken {
+	var items [][]sqlToken
+	itemStart := c.pos
+	depth := 0
+	for c.pos < len(c.tokens) {
+		token := c.next()
+		switch {
+		case token.isSymbol("(") || token.isSymbol("["):
+			depth++
+		case token.isSymbol(")") || token.isSymbol("]"):
+			depth--
+		case depth == 0 && token.isSymbol(","):
+			if c.pos-1 > itemStart {
+				items = append(items, c.tokens[itemStart:c.pos-1])
+			}
+			itemStart = c.pos
+		}
+	}
+	if c.pos > itemStart {
+		items = append(items, c.tokens[itemStart:c.pos])
+	}
+	return items
+}
+
+// Return the text of a string token without its quotes and prefix; escapes
+// other than doubled quotes are kept as written
+// @return string
+func (t sqlToken) stringValue() string {
+	text := t.Text
+	if strings.HasPrefix(text, "$") {
+		tag := strings.Index(text[1:], "$") + 2
+		return text[tag : len(text)-tag]
+	}
+	text = text[strings.IndexByte(text, '\''):]
+	return strings.ReplaceAll(text[1:len(text)-1], "''", "'")
+}
+
+// Consume the next token if it is one of the keywords
+// @param words ...string
+// @return bool
+func (c *sqlCursor) acceptAnyKeyword(words ...string) bool {
+	if c.peek().isKeyword(words...) {
+		c.pos++
+		return true
+	}
+	return false
+}
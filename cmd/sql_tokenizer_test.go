@@ -0,0 +1,184 @@
+package migroCMD
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestTokenizeSQL(t *testing.T) {
+	type token struct {
+		Kind  sqlTokenKind
+		Text  string
+		Value string
+	}
+	tests := []struct {
+		name string
+		sql  string
+		want []token
+	}{
+		{
+			name: "words fold to lower case",
+			sql:  "SELECT Id FROM Users",
+			want: []token{{sqlWord, "SELECT", "select"}, {sqlWord, "Id", "id"}, {sqlWord, "FROM", "from"}, {sqlWord, "Users", "users"}},
+		},
+		{
+			name: "quoted identifiers keep case and unescape quotes",
+			sql:  `"User ""Table""".id`,
+			want: []token{{sqlQuotedIdentifier, `"User ""Table"""`, `User "Table"`}, {sqlPunctuation, ".", ""}, {sqlWord, "id", "id"}},
+		},
+		{
+			name: "strings with doubled quotes and prefixes",
+			sql:  `'it''s' N'x' B'101' X'1F'`,
+			want: []token{{sqlString, `'it''s'`, ""}, {sqlString, "N'x'", ""}, {sqlString, "B'101'", ""}, {sqlString, "X'1F'", ""}},
+		},
+		{
+			name: "escape strings allow backslash quotes",
+			sql:  `E'it\'s; \\' ;`,
+			want: []token{{sqlString, `E'it\'s; \\'`, ""}, {sqlPunctuation, ";", ""}},
+		},
+		{
+			name: "unicode escape identifiers",
+			sql:  `U&"d\0061t\+000061"`,
+			want: []token{{sqlQuotedIdentifier, `U&"d\0061t\+000061"`, `d\0061t\+000061`}},
+		},
+		{
+			name: "dollar quotes with and without tags",
+			sql:  "$$a;'b$$ $fn$ $$ nested; $fn$ $1",
+			want: []token{{sqlString, "$$a;'b$$", ""}, {sqlString, "$fn$ $$ nested; $fn$", ""}, {sqlParameter, "$1", ""}},
+		},
+		{
+			name: "dollar sign inside an identifier is no quote",
+			sql:  "a$b $x$y$x$",
+			want: []token{{sqlWord, "a$b", "a$b"}, {sqlString, "$x$y$x$", ""}},
+		},
+		{
+			name: "line and nested block comments are skipped",
+			sql:  "a -- ; comment\n/* outer /* inner; */ still comment */ b",
+			want: []token{{sqlWord, "a", "a"}, {sqlWord, "b", "b"}},
+		},
+		{
+			name: "numbers, casts and operators",
+			sql:  "1.5e3::numeric >= .5 || x",
+			want: []token{{sqlNumber, "1.5e3", ""}, {sqlOperator, "::", ""}, {sqlWord, "numeric", "numeric"}, {sqlOperator, ">=", ""}, {sqlNumber, ".5", ""}, {sqlOperator, "||", ""}, {sqlWord, "x", "x"}},
+		},
+		{
+			name: "operators stop before a comment",
+			sql:  "a+-- comment\nb",
+			want: []token{{sqlWord, "a", "a"}, {sqlOperator, "+", ""}, {sqlWord, "b", "b"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tokens, err := tokenizeSQL(tt.sql)
+			if err != nil {
+				t.Fatalf("tokenizeSQL() error = %v", err)
+			}
+			got := make([]token, len(tokens))
+			for i, tok := range tokens {
+				got[i] = token{tok.Kind, tok.Text, tok.Value}
+				if tt.sql[tok.Start:tok.End] != tok.Text {
+					t.Errorf("token %q has offsets of %q", tok.Text, tt.sql[tok.Start:tok.End])
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("tokenizeSQL() =\n%v\nwant\n%v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTokenizeSQLErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		sql     string
+		wantErr string
+	}{
+		{"unterminated string", "SELECT 'abc", "unterminated"},
+		{"unterminated escape string", `SELECT E'abc\'`, "unterminated"},
+		{"unterminated quoted identifier", `SELECT "abc`, "unterminated"},
+		{"unterminated dollar quote", "SELECT 1;\n$tag$ body $other$", "line 2"},
+		{"unterminated nested comment", "/* outer /* inner */", "unterminated"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tokenizeSQL(tt.sql)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("tokenizeSQL() error = %v, want it to mention %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestStringValue(t *testing.T) {
+	tests := []struct {
+		sql  string
+		want string
+	}{
+		{`'it''s'`, "it's"},
+		{"E'a'", "a"},
+		{"$$ body $$", " body "},
+		{"$fn$SELECT $$x$$$fn$", "SELECT $$x$$"},
+	}
+
+	for _, tt := range tests {
+		tokens, err := tokenizeSQL(tt.sql)
+		if err != nil || len(tokens) != 1 {
+			t.Fatalf("tokenizeSQL(%q) = %v, %v", tt.sql, tokens, err)
+		}
+		if got := tokens[0].stringValue(); got != tt.want {
+			t.Errorf("stringValue(%q) = %q, want %q", tt.sql, got, tt.want)
+		}
+	}
+}
+
+func TestSplitSQLStatements(t *testing.T) {
+	tests := []struct {
+		name string
+		sql  string
+		want []string
+	}{
+		{
+			name: "semicolons in strings, identifiers and comments",
+			sql:  `INSERT INTO "a;b" VALUES ('x;y', E'\';'); -- c;` + "\n/* d; */ SELECT 1",
+			want: []string{`INSERT INTO "a;b" VALUES ('x;y', E'\';')`, "SELECT 1"},
+		},
+		{
+			name: "dollar-quoted function body",
+			sql:  "CREATE FUNCTION f() RETURNS int AS $body$ BEGIN RETURN 1; END; $body$ LANGUAGE plpgsql;\nSELECT f();",
+			want: []string{"CREATE FUNCTION f() RETURNS int AS $body$ BEGIN RETURN 1; END; $body$ LANGUAGE plpgsql", "SELECT f()"},
+		},
+		{
+			name: "BEGIN ATOMIC body with CASE",
+			sql:  "CREATE FUNCTION g(x int) RETURNS int BEGIN ATOMIC SELECT CASE WHEN x > 0 THEN 1 ELSE 0 END; SELECT 2; END; SELECT 3",
+			want: []string{"CREATE FUNCTION g(x int) RETURNS int BEGIN ATOMIC SELECT CASE WHEN x > 0 THEN 1 ELSE 0 END; SELECT 2; END", "SELECT 3"},
+		},
+		{
+			name: "empty statements and comments only",
+			sql:  ";; -- nothing\n;",
+			want: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			statements, err := splitSQLStatements(tt.sql)
+			if err != nil {
+				t.Fatalf("splitSQLStatements() error = %v", err)
+			}
+			var got []string
+			for _, statement := range statements {
+				got = append(got, statement.SQL)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("splitSQLStatements() =\n%q\nwant\n%q", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := splitSQLStatements("CREATE FUNCTION h() RETURNS int BEGIN ATOMIC SELECT 1;"); err == nil {
+		t.Error("splitSQLStatements() accepted an unterminated BEGIN ATOMIC block")
+	}
+}
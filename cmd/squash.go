@@ -0,0 +1,411 @@
+package migroCMD
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Directory inside MIGRATION_DIR that keeps the original files of squashed migrations
+const squashArchiveDir = "archive"
+
+// Keywords starting statements that change data rather than schema; a squash only keeps the schema
+var dataStatementKeywords = []string{"INSERT", "UPDATE", "DELETE", "COPY", "TRUNCATE", "CALL"}
+
+// SquashOptions configures migro squash
+type SquashOptions struct {
+	Before     string // squash migrations with a version lower than this
+	ScratchURL string // empty database to replay migrations in, instead of a temporary one
+	RecordOnly bool   // only update the version table for a squash done before
+}
+
+// SquashMigrations replaces all migrations older than --before with one
+// migration holding the schema they build. The migrations are replayed into
+// a scratch database, its schema is written as a migration carrying the
+// last squashed version, and the original files are moved to the archive
+// directory. In the connected database the squashed versions are replaced
+// by the new one, so it is not applied twice. Other databases that applied
+// the squashed migrations are updated with --record-only.
+// @param config *CONFIG
+// @param db *pgxpool.Pool
+// @param opts SquashOptions
+// @return error
+func SquashMigrations(config *CONFIG, db *pgxpool.Pool, opts SquashOptions) error {
+	before, err := strconv.ParseInt(opts.Before, 10, 64)
+	if err != nil {
+		return fmt.Errorf("❌ invalid --before version '%s'", opts.Before)
+	}
+
+	ctx, cancel := commandContext(config)
+	defer cancel()
+
+	// Make sure no other process is migrating this database
+	unlock, err := acquireMigrationLock(ctx, db, config)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	migrator := NewMigrator(config, db)
+	if opts.RecordOnly {
+		return recordSquashOnly(ctx, migrator, before)
+	}
+
+	migrations, err := loadMigrations(config.MIGRATION_DIR)
+	if err != nil {
+		return fmt.Errorf("❌ failed to load migrations: %w", err)
+	}
+	var squashed []*Migration
+	for _, migration := range migrations {
+		if migration.Version < before {
+			squashed = append(squashed, migration)
+		}
+	}
+	if len(squashed) < 2 {
+		return fmt.Errorf("❌ found %d migration(s) before version %d, nothing to squash", len(squashed), before)
+	}
+	last := squashed[len(squashed)-1]
+	versions := make([]int64, len(squashed))
+	for i, migration := range squashed {
+		versions[i] = migration.Version
+	}
+
+	// Refuse before touching any file if the database is half way through the squashed range
+	applied, err := migrator.appliedVersions(ctx)
+	if err != nil {
+		return fmt.Errorf("❌ failed to read applied migrations: %w", err)
+	}
+	if err := checkSquashable(applied, versions, last.Version); err != nil {
+		return err
+	}
+
+	scratch, cleanup, err := openScratchDatabase(ctx, db, opts.ScratchURL)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	infof("🧪 Replaying %d migration(s) into a scratch database...\n", len(squashed))
+	replayed, err := replayMigrations(ctx, config, scratch, squashed)
+	if err != nil {
+		return fmt.Errorf("❌ failed to replay migrations: %w", err)
+	}
+	snapshot, err := introspectMigratedSchema(ctx, replayed)
+	if err != nil {
+		return err
+	}
+	up, down := renderSchemaDDL(snapshot)
+
+	header := fmt.Sprintf("-- Squash of %d migrations from %d to %d, generated by migro on %s\n"+
+		"-- The original files are in %s.",
+		len(squashed), squashed[0].Version, last.Version, time.Now().UTC().Format("2006-01-02 15:04:05 UTC"),
+		filepath.Join(config.MIGRATION_DIR, squashArchiveDir))
+	dataFiles := findDataStatements(squashed)
+	for _, file := range dataFiles {
+		header += "\n-- ⚠️  " + file + " changed data, which the squash does not keep"
+	}
+
+	archived, err := archiveMigrations(config.MIGRATION_DIR, squashed)
+	if err != nil {
+		return err
+	}
+	squashPath := filepath.Join(config.MIGRATION_DIR, fmt.Sprintf("%d_squashed.sql", last.Version))
+	migration, err := writeMigrationFileAt(squashPath, header, &Migration{Up: up, Down: down})
+	if err != nil {
+		os.Remove(squashPath)
+		restoreArchivedMigrations(archived)
+		return fmt.Errorf("❌ failed to write squashed migration, the original files were restored: %w", err)
+	}
+	infof("🗜️  Squashed %d migration(s) into %s\n", len(squashed), migration.Path)
+	infof("📦 Moved the original files to %s\n", filepath.Join(config.MIGRATION_DIR, squashArchiveDir))
+
+	recorded, err := migrator.recordSquash(ctx, migration, versions)
+	if err != nil {
+		return fmt.Errorf("❌ wrote %s but failed to update the version table: %w\n"+
+			"💡 Retry with: migro squash --before %d --record-only", migration.Path, err, before)
+	}
+	if recorded {
+		infof("📋 Replaced %d applied version(s) with version %d in the version table\n", len(versions), migration.Version)
+	} else {
+		infof("📋 None of the squashed migrations were applied here, the squashed migration will run on the next migrate\n")
+	}
+
+	if len(dataFiles) > 0 {
+		infof("⚠️  %d squashed migration(s) changed data; copy those statements into the squashed file if new databases need them\n", len(dataFiles))
+	}
+	infof("💡 On every other database that applied these migrations, run: migro squash --before %d --record-only\n", before)
+	return nil
+}
+
+// Update the version table of a database for a squash written before: the
+// only local migration older than before is the squashed one, the
+// archived migrations older than before are the ones it replaced
+// @param ctx context.Context
+// @param migrator *Migrator
+// @param before int64
+// @return error
+func recordSquashOnly(ctx context.Context, migrator *Migrator, before int64) error {
+	migrations, err := loadMigrations(migrator.dir)
+	if err != nil {
+		return fmt.Errorf("❌ failed to load migrations: %w", err)
+	}
+	squashed, err := findSquashedMigration(migrations, before)
+	if err != nil {
+		return err
+	}
+
+	versions, err := archivedVersionsBefore(filepath.Join(migrator.dir, squashArchiveDir), before)
+	if err != nil {
+		return err
+	}
+
+	applied, err := migrator.appliedVersions(ctx)
+	if err != nil {
+		return fmt.Errorf("❌ failed to read applied migrations: %w", err)
+	}
+	if err := checkSquashable(applied, versions, squashed.Version); err != nil {
+		return err
+	}
+
+	recorded, err := migrator.recordSquash(ctx, squashed, versions)
+	if err != nil {
+		return fmt.Errorf("❌ failed to update the version table: %w", err)
+	}
+	if recorded {
+		infof("✅ Recorded %s in place of %d squashed version(s)\n", squashed.FileName(), len(versions))
+	} else {
+		infof("✅ None of the squashed migrations were applied here, nothing to record\n")
+	}
+	return nil
+}
+
+// Find the squashed migration of a squash done before: the only local
+// migration older than before
+// @param migrations []*Migration
+// @param before int64
+// @return *Migration, error
+func findSquashedMigration(migrations []*Migration, before int64) (*Migration, error) {
+	var squashed []*Migration
+	for _, migration := range migrations {
+		if migration.Version < before {
+			squashed = append(squashed, migration)
+		}
+	}
+	if len(squashed) != 1 {
+		return nil, fmt.Errorf("❌ expected exactly one squashed migration before version %d, found %d", before, len(squashed))
+	}
+	return squashed[0], nil
+}
+
+// Get the versions of the archived migrations older than before
+// @param archiveDir string
+// @param before int64
+// @return []int64, error
+func archivedVersionsBefore(archiveDir string, before int64) ([]int64, error) {
+	// Earlier squashes leave a squashed and an original file per version in the archive
+	archived, err := getLocalMigrationVersions(archiveDir)
+	if err != nil {
+		return nil, fmt.Errorf("❌ failed to read archived migrations: %w", err)
+	}
+	var versions []int64
+	for _, version := range archived {
+		if version < before && !contains64(versions, version) {
+			versions = append(versions, version)
+		}
+	}
+	if len(versions) == 0 {
+		return nil, fmt.Errorf("❌ no archived migrations before version %d in %s", before, archiveDir)
+	}
+	return versions, nil
+}
+
+// Check if a migration is the file written by migro squash
+// @param migration *Migration
+// @return bool
+func isSquashMigration(migration *Migration) bool {
+	return migration.Name == "squashed"
+}
+
+// Check a database can follow a squash: either the last squashed version
+// is applied, or none of the squashed versions are
+// @param applied []int64
+// @param versions []int64 - squashed versions
+// @param last int64 - version of the squashed migration
+// @return error
+func checkSquashable(applied, versions []int64, last int64) error {
+	if contains64(applied, last) {
+		return nil
+	}
+	var partial []int64
+	for _, version := range versions {
+		if contains64(applied, version) {
+			partial = append(partial, version)
+		}
+	}
+	if len(partial) > 0 {
+		return fmt.Errorf("❌ this database applied %d of the squashed migrations but not the last one (%d)\n"+
+			"💡 Run: migro migrate --to %d with the original files first", len(partial), last, last)
+	}
+	return nil
+}
+
+// Replace the rows of squashed versions in the version and history tables
+// with the squashed migration, if the database applied them
+// @param ctx context.Context
+// @param squashed *Migration
+// @param versions []int64 - squashed versions
+// @return bool, error (whether the database had the squashed versions applied)
+func (m *Migrator) recordSquash(ctx context.Context, squashed *Migration, versions []int64) (bool, error) {
+	applied, err := m.appliedVersions(ctx)
+	if err != nil {
+		return false, err
+	}
+	if !contains64(applied, squashed.Version) {
+		return false, nil
+	}
+	if err := m.ensureVersionTable(ctx); err != nil {
+		return false, err
+	}
+
+	tx, err := m.db.Begin(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, fmt.Sprintf("DELETE FROM %s WHERE version_id = ANY($1) AND version_id <> $2", m.quotedTable()), versions, squashed.Version)
+	if err != nil {
+		return false, fmt.Errorf("failed to remove squashed versions: %w", err)
+	}
+	_, err = tx.Exec(ctx, fmt.Sprintf("DELETE FROM %s WHERE version_id = ANY($1) AND version_id <> $2", m.quotedHistoryTable()), versions, squashed.Version)
+	if err != nil {
+		return false, fmt.Errorf("failed to remove history of squashed versions: %w", err)
+	}
+	if err := m.recordHistory(ctx, tx, squashed); err != nil {
+		return false, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return false, fmt.Errorf("failed to commit: %w", err)
+	}
+	return true, nil
+}
+
+// Move migration files into the archive directory
+// @param migrationDir string
+// @param migrations []*Migration
+// @return map[string]string, error (archived path by original path)
+func archiveMigrations(migrationDir string, migrations []*Migration) (map[string]string, error) {
+	archiveDir := filepath.Join(migrationDir, squashArchiveDir)
+	if err := os.MkdirAll(archiveDir, 0755); err != nil {
+		return nil, fmt.Errorf("❌ failed to create archive directory: %w", err)
+	}
+
+	archived := make(map[string]string)
+	for _, migration := range migrations {
+		target := filepath.Join(archiveDir, migration.FileName())
+		if _, err := os.Stat(target); err == nil {
+			restoreArchivedMigrations(archived)
+			return nil, fmt.Errorf("❌ %s already exists", target)
+		}
+		if err := os.Rename(migration.Path, target); err != nil {
+			restoreArchivedMigrations(archived)
+			return nil, fmt.Errorf("❌ failed to archive %s: %w", migration.FileName(), err)
+		}
+		archived[migration.Path] = target
+	}
+	return archived, nil
+}
+
+// Move archived files back to their original place
+// @param archived map[string]string - archived path by original path
+func restoreArchivedMigrations(archived map[string]string) {
+	for original, target := range archived {
+		if err := os.Rename(target, original); err != nil {
+			infof("⚠️  Failed to restore %s from %s: %v\n", original, target, err)
+		}
+	}
+}
+
+// Find migrations whose Up section changes data. A migration that cannot
+// be tokenized is reported as well, since it may change data.
+// @param migrations []*Migration
+// @return []string - file names
+func findDataStatements(migrations []*Migration) []string {
+	var files []string
+	for _, migration := range migrations {
+		changes, err := changesData(migration.Up)
+		if err != nil {
+			infof("⚠️  Could not read the statements of %s, assuming it changes data: %v\n", migration.FileName(), err)
+		}
+		if changes || err != nil {
+			files = append(files, migration.FileName())
+		}
+	}
+	return files
+}
+
+// Check if SQL changes data: a statement starting with a data keyword, a
+// WITH query that inserts, updates or deletes, or a DO block that does
+// @param sqls []string
+// @return bool, error
+func changesData(sqls []string) (bool, error) {
+	for _, sql := range sqls {
+		statements, err := splitSQLStatements(sql)
+		if err != nil {
+			return false, err
+		}
+		for _, statement := range statements {
+			first := statement.Tokens[0]
+			switch {
+			case first.isKeyword(dataStatementKeywords...):
+				return true, nil
+			case first.isKeyword("WITH"):
+				if containsDataStatement(statement.Tokens) {
+					return true, nil
+				}
+			case first.isKeyword("DO"):
+				for _, token := range statement.Tokens[1:] {
+					if token.Kind != sqlString {
+						continue
+					}
+					body, err := tokenizeSQL(token.stringValue())
+					if err != nil {
+						return false, err
+					}
+					if containsDataStatement(body) {
+						return true, nil
+					}
+					break
+				}
+			}
+		}
+	}
+	return false, nil
+}
+
+// Check if tokens contain a data keyword where a statement or subquery
+// starts, so ON DELETE or FOR UPDATE clauses do not count
+// @param tokens []sqlToken
+// @return bool
+func containsDataStatement(tokens []sqlToken) bool {
+	for i, token := range tokens {
+		if !token.isKeyword(dataStatementKeywords...) {
+			continue
+		}
+		if i == 0 {
+			return true
+		}
+		previous := tokens[i-1]
+		if previous.isSymbol(";") || previous.isSymbol("(") || previous.isSymbol(")") ||
+			previous.isKeyword("BEGIN", "THEN", "ELSE", "LOOP") {
+			return true
+		}
+	}
+	return false
+}
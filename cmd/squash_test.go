@@ -0,0 +1,140 @@
+package migroCMD
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestChangesData(t *testing.T) {
+	tests := []struct {
+		name string
+		sql  []string
+		want bool
+	}{
+		{name: "schema only", sql: []string{"CREATE TABLE a (id int REFERENCES b ON DELETE CASCADE);", "ALTER TABLE a ADD COLUMN name text;"}},
+		{name: "insert", sql: []string{"CREATE TABLE a (id int);", "INSERT INTO a VALUES (1);"}, want: true},
+		{name: "copy", sql: []string{"COPY a (id) FROM '/tmp/a.csv' WITH (FORMAT csv);"}, want: true},
+		{name: "with insert", sql: []string{"WITH ids AS (SELECT id FROM b) INSERT INTO a SELECT id FROM ids;"}, want: true},
+		{name: "with delete in a data-modifying CTE", sql: []string{"WITH gone AS (DELETE FROM a RETURNING id) SELECT count(*) FROM gone;"}, want: true},
+		{name: "with select only", sql: []string{"WITH ids AS (SELECT id FROM b) SELECT * FROM ids;"}},
+		{name: "do block updating rows", sql: []string{"DO $$\nBEGIN\n  UPDATE a SET id = id + 1;\nEND\n$$;"}, want: true},
+		{name: "do block with a tagged body", sql: []string{"DO $body$ BEGIN IF true THEN DELETE FROM a; END IF; END $body$;"}, want: true},
+		{name: "do block changing schema only", sql: []string{"DO $$\nBEGIN\n  EXECUTE 'CREATE INDEX i ON a (id)';\nEND\n$$;"}},
+		{name: "for update is not an update", sql: []string{"CREATE VIEW v AS SELECT * FROM a;", "SELECT * FROM a FOR UPDATE;"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := changesData(tt.sql)
+			if err != nil {
+				t.Fatalf("changesData() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("changesData() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := changesData([]string{"INSERT INTO a VALUES ('unterminated);"}); err == nil {
+		t.Error("changesData() with an unterminated string: want an error")
+	}
+}
+
+func TestCheckSquashable(t *testing.T) {
+	versions := []int64{1, 2, 3}
+	tests := []struct {
+		name    string
+		applied []int64
+		wantErr bool
+	}{
+		{name: "whole range applied", applied: []int64{1, 2, 3, 4}},
+		{name: "nothing applied", applied: []int64{}},
+		{name: "only later versions applied", applied: []int64{4}},
+		{name: "partially applied range", applied: []int64{1, 2}, wantErr: true},
+		{name: "first version only", applied: []int64{1}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkSquashable(tt.applied, versions, 3)
+			if tt.wantErr {
+				if err == nil || !strings.Contains(err.Error(), "migro migrate --to 3") {
+					t.Fatalf("checkSquashable() error = %v, want a refusal pointing to migrate --to 3", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("checkSquashable() error = %v", err)
+			}
+		})
+	}
+}
+
+func TestRecordSquashOnlyInputs(t *testing.T) {
+	dir := t.TempDir()
+	archiveDir := filepath.Join(dir, squashArchiveDir)
+	if err := os.MkdirAll(archiveDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	content := "-- +goose Up\nSELECT 1;\n-- +goose Down\n"
+	for _, path := range []string{
+		filepath.Join(dir, "3_squashed.sql"),
+		filepath.Join(dir, "5_later.sql"),
+		filepath.Join(archiveDir, "1_create.sql"),
+		filepath.Join(archiveDir, "2_alter.sql"),
+		filepath.Join(archiveDir, "3_index.sql"),
+		filepath.Join(archiveDir, "3_squashed.sql"), // left by an earlier squash
+		filepath.Join(archiveDir, "5_later.sql"),
+	} {
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	migrations, err := loadMigrations(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	squashed, err := findSquashedMigration(migrations, 4)
+	if err != nil {
+		t.Fatalf("findSquashedMigration() error = %v", err)
+	}
+	if squashed.Version != 3 || !isSquashMigration(squashed) {
+		t.Errorf("findSquashedMigration() = %s, want 3_squashed.sql", squashed.FileName())
+	}
+	if _, err := findSquashedMigration(migrations, 6); err == nil {
+		t.Error("findSquashedMigration() with two migrations before the version: want an error")
+	}
+
+	versions, err := archivedVersionsBefore(archiveDir, 4)
+	if err != nil {
+		t.Fatalf("archivedVersionsBefore() error = %v", err)
+	}
+	if want := []int64{1, 2, 3}; !reflect.DeepEqual(versions, want) {
+		t.Errorf("archivedVersionsBefore() = %v, want %v", versions, want)
+	}
+	if _, err := archivedVersionsBefore(archiveDir, 1); err == nil {
+		t.Error("archivedVersionsBefore() without archived versions: want an error")
+	}
+}
+
+func TestMismatchHintForSquash(t *testing.T) {
+	squashed := &Migration{Version: 3, Name: "squashed", Path: "migrations/3_squashed.sql"}
+	edited := &Migration{Version: 5, Name: "later", Path: "migrations/5_later.sql"}
+
+	hint := mismatchHint([]ChecksumMismatch{{Migration: squashed, UpChanged: true, DownChanged: true}})
+	if !strings.Contains(hint, "migro squash --before 4 --record-only") {
+		t.Errorf("mismatchHint() = %q, want it to suggest squash --record-only", hint)
+	}
+	if strings.Contains(hint, "--repair-checksums") {
+		t.Errorf("mismatchHint() = %q, want no checksum repair for a squash", hint)
+	}
+
+	hint = mismatchHint([]ChecksumMismatch{{Migration: edited, UpChanged: true}})
+	if !strings.Contains(hint, "--repair-checksums") || strings.Contains(hint, "--record-only") {
+		t.Errorf("mismatchHint() = %q, want only the checksum repair hint", hint)
+	}
+}
@@ -0,0 +1,95 @@
+package migroCMD
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Schema used for unqualified table names when DEFAULT_SCHEMA is not set
+const defaultSchemaName = "public"
+
+// A SQL identifier, quoted or not
+const identifierPattern = `(?:"(?:[^"]|"")+"|[A-Za-z_][A-Za-z0-9_$]*)`
+
+// An optionally schema-qualified name such as users, app.users or "App"."Users"
+const qualifiedNamePattern = identifierPattern + `(?:\s*\.\s*` + identifierPattern + `)?`
+
+var qualifiedNameRegex = regexp.MustCompile(`^(` + identifierPattern + `)(?:\s*\.\s*(` + identifierPattern + `))?$`)
+
+// TableName is a table reference resolved to its schema
+type TableName struct {
+	Schema string
+	Name   string
+}
+
+// String returns schema.table unquoted, as used in messages and as the key
+// of tables found in migration files
+func (t TableName) String() string {
+	return t.Schema + "." + t.Name
+}
+
+// Quoted returns the schema-qualified, quoted name for use in SQL
+func (t TableName) Quoted() string {
+	return pgx.Identifier{t.Schema, t.Name}.Sanitize()
+}
+
+// Label returns the name used in migration file names: the bare table name
+// in the default schema, schema_table otherwise
+// @param defaultSchema string
+// @return string
+func (t TableName) Label(defaultSchema string) string {
+	if t.Schema == defaultSchema {
+		return t.Name
+	}
+	return t.Schema + "_" + t.Name
+}
+
+// Parse "table", "schema.table" or quoted names such as "My Schema"."Table".
+// Unquoted names are folded to lower case like PostgreSQL does.
+// @param input string
+// @param defaultSchema string - schema of unqualified names
+// @return TableName, error
+func parseTableName(input, defaultSchema string) (TableName, error) {
+	match := qualifiedNameRegex.FindStringSubmatch(strings.TrimSpace(input))
+	if match == nil {
+		return TableName{}, fmt.Errorf("invalid table name '%s' (expected table or schema.table)", input)
+	}
+	if match[2] == "" {
+		return TableName{Schema: defaultSchema, Name: normalizeIdentifier(match[1])}, nil
+	}
+	return TableName{Schema: normalizeIdentifier(match[1]), Name: normalizeIdentifier(match[2])}, nil
+}
+
+// Resolve a --table value against DEFAULT_SCHEMA
+// @param config *CONFIG
+// @param table string
+// @return TableName, error
+func resolveTableName(config *CONFIG, table string) (TableName, error) {
+	name, err := parseTableName(table, config.DEFAULT_SCHEMA)
+	if err != nil {
+		return TableName{}, fmt.Errorf("❌ %w", err)
+	}
+	return name, nil
+}
+
+// Normalize an identifier: strip the quotes of a quoted one, fold an
+// unquoted one to lower case
+// @param identifier string
+// @return string
+func normalizeIdentifier(identifier string) string {
+	identifier = strings.TrimSpace(identifier)
+	if len(identifier) >= 2 && strings.HasPrefix(identifier, `"`) && strings.HasSuffix(identifier, `"`) {
+		return strings.ReplaceAll(identifier[1:len(identifier)-1], `""`, `"`)
+	}
+	return strings.ToLower(identifier)
+}
+
+// Quote a column name given on the command line
+// @param column string
+// @return string
+func quoteColumnName(column string) string {
+	return pgx.Identifier{normalizeIdentifier(column)}.Sanitize()
+}
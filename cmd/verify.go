@@ -0,0 +1,291 @@
+package migroCMD
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// ChecksumMismatch is an applied migration whose file changed since it ran
+type ChecksumMismatch struct {
+	Migration   *Migration
+	UpChanged   bool
+	DownChanged bool
+}
+
+// ChecksumReport is the result of comparing applied migrations with their files
+type ChecksumReport struct {
+	Mismatches []ChecksumMismatch
+	Unrecorded []*Migration // applied before checksum tracking existed
+	Outdated   []*Migration // unchanged, but recorded in an older checksum format
+}
+
+// Compare recorded checksums with the current migration files
+// @param ctx context.Context
+// @return *ChecksumReport, error
+func (m *Migrator) verifyChecksums(ctx context.Context) (*ChecksumReport, error) {
+	migrations, err := loadMigrations(m.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	applied, err := m.appliedVersions(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	recorded, err := m.recordedChecksums(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return compareChecksums(migrations, applied, recorded)
+}
+
+// Compare recorded checksums with the migration files of applied versions.
+// A checksum recorded in an older format also matches the file parsed the
+// way that format did, so files that did not change are reported as
+// outdated instead of changed.
+// @param migrations []*Migration - local migration files
+// @param applied []int64
+// @param recorded map[int64]recordedChecksums
+// @return *ChecksumReport, error
+func compareChecksums(migrations []*Migration, applied []int64, recorded map[int64]recordedChecksums) (*ChecksumReport, error) {
+	report := &ChecksumReport{}
+	for _, version := range applied {
+		migration := findMigration(migrations, version)
+		if migration == nil {
+			continue // missing files are reported by status
+		}
+
+		sums, ok := recorded[version]
+		if !ok {
+			report.Unrecorded = append(report.Unrecorded, migration)
+			continue
+		}
+
+		mismatch := ChecksumMismatch{
+			Migration:   migration,
+			UpChanged:   sums.up != migration.UpChecksum(),
+			DownChanged: sums.down != migration.DownChecksum(),
+		}
+		if sums.format < checksumFormat {
+			if mismatch.UpChanged || mismatch.DownChanged {
+				legacy, err := legacyChecksums(migration.Path)
+				if err != nil {
+					return nil, err
+				}
+				mismatch.UpChanged = mismatch.UpChanged && sums.up != legacy.up
+				mismatch.DownChanged = mismatch.DownChanged && sums.down != legacy.down
+			}
+			if !mismatch.UpChanged && !mismatch.DownChanged {
+				report.Outdated = append(report.Outdated, migration)
+				continue
+			}
+		}
+		if mismatch.UpChanged || mismatch.DownChanged {
+			report.Mismatches = append(report.Mismatches, mismatch)
+		}
+	}
+
+	return report, nil
+}
+
+// Compute the checksums of a migration file in checksum format 1
+// @param filePath string
+// @return recordedChecksums, error
+func legacyChecksums(filePath string) (recordedChecksums, error) {
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		return recordedChecksums{}, fmt.Errorf("failed to read migration %s: %w", filePath, err)
+	}
+	migration, err := parseLegacyMigrationSQL(string(content))
+	if err != nil {
+		return recordedChecksums{}, fmt.Errorf("failed to parse migration %s: %w", filePath, err)
+	}
+	return recordedChecksums{up: migration.UpChecksum(), down: migration.DownChecksum(), format: 1}, nil
+}
+
+// recordedChecksums are the up and down checksums of an applied migration
+// and the checksum format they were recorded in
+type recordedChecksums struct {
+	up, down string
+	format   int
+}
+
+// Read the recorded checksums by version; empty if there is no history table yet.
+// Read-only: history tables without a checksum_format column hold format 1.
+// @param ctx context.Context
+// @return map[int64]recordedChecksums, error
+func (m *Migrator) recordedChecksums(ctx context.Context) (map[int64]recordedChecksums, error) {
+	recorded := make(map[int64]recordedChecksums)
+	var exists, hasFormat bool
+	err := m.db.QueryRow(ctx, `SELECT to_regclass($1) IS NOT NULL, EXISTS (
+		SELECT 1 FROM pg_attribute
+		WHERE attrelid = to_regclass($1) AND attname = 'checksum_format' AND NOT attisdropped
+	)`, m.history).Scan(&exists, &hasFormat)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check history table: %w", err)
+	}
+	if !exists {
+		return recorded, nil
+	}
+
+	format := "checksum_format"
+	if !hasFormat {
+		format = "1"
+	}
+
+	rows, err := m.db.Query(ctx, fmt.Sprintf("SELECT version_id, up_checksum, down_checksum, %s FROM %s", format, m.quotedHistoryTable()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query history table: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var version int64
+		var sums recordedChecksums
+		if err := rows.Scan(&version, &sums.up, &sums.down, &sums.format); err != nil {
+			return nil, fmt.Errorf("failed to scan history row: %w", err)
+		}
+		recorded[version] = sums
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read history table: %w", err)
+	}
+	return recorded, nil
+}
+
+// Accept the current file content of changed or unrecorded migrations
+// @param ctx context.Context
+// @param migrations []*Migration
+// @return error
+func (m *Migrator) repairChecksums(ctx context.Context, migrations []*Migration) error {
+	if err := m.ensureVersionTable(ctx); err != nil {
+		return err
+	}
+
+	tx, err := m.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	for _, migration := range migrations {
+		if err := m.recordHistory(ctx, tx, migration); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit(ctx)
+}
+
+// VerifyMigrations reports applied migrations whose files changed after they ran
+// @param config *CONFIG
+// @param db *pgxpool.Pool
+// @param repair bool - accept the new content and update recorded checksums
+// @return error
+func VerifyMigrations(config *CONFIG, db *pgxpool.Pool, repair bool) error {
+	ctx, cancel := commandContext(config)
+	defer cancel()
+	infof("🔍 Verifying checksums of applied migrations...\n")
+
+	if repair {
+		// Make sure no other process is migrating this database
+		unlock, err := acquireMigrationLock(ctx, db, config)
+		if err != nil {
+			return err
+		}
+		defer unlock()
+	}
+
+	migrator := NewMigrator(config, db)
+	report, err := migrator.verifyChecksums(ctx)
+	if err != nil {
+		return fmt.Errorf("❌ verify failed: %w", err)
+	}
+
+	printChecksumReport(report)
+
+	if repair {
+		var toRepair []*Migration
+		for _, mismatch := range report.Mismatches {
+			toRepair = append(toRepair, mismatch.Migration)
+		}
+		toRepair = append(toRepair, report.Unrecorded...)
+		toRepair = append(toRepair, report.Outdated...)
+
+		if len(toRepair) == 0 {
+			return nil
+		}
+		if err := migrator.repairChecksums(ctx, toRepair); err != nil {
+			return fmt.Errorf("❌ failed to repair checksums: %w", err)
+		}
+		infof("🔧 Recorded new checksums for %d migration(s)\n", len(toRepair))
+		return nil
+	}
+
+	if len(report.Mismatches) > 0 {
+		return fmt.Errorf("❌ %d applied migration(s) changed after being applied\n%s", len(report.Mismatches), mismatchHint(report.Mismatches))
+	}
+	if len(report.Unrecorded) > 0 {
+		infof("💡 Run: migro verify --repair-checksums to record their current checksums\n")
+	}
+
+	return nil
+}
+
+// Suggest how to resolve checksum mismatches. A squashed migration differs
+// from the last migration it replaced until the squash is recorded.
+// @param mismatches []ChecksumMismatch
+// @return string
+func mismatchHint(mismatches []ChecksumMismatch) string {
+	var hints []string
+	edited := false
+	for _, mismatch := range mismatches {
+		if isSquashMigration(mismatch.Migration) {
+			hints = append(hints, fmt.Sprintf("💡 %s replaced migrations applied here, run: migro squash --before %d --record-only",
+				mismatch.Migration.FileName(), mismatch.Migration.Version+1))
+		} else {
+			edited = true
+		}
+	}
+	if edited {
+		hints = append(hints, "💡 Revert the files, or run: migro verify --repair-checksums")
+	}
+	return strings.Join(hints, "\n")
+}
+
+// Print a checksum report
+// @param report *ChecksumReport
+func printChecksumReport(report *ChecksumReport) {
+	for _, mismatch := range report.Mismatches {
+		var sections []string
+		if mismatch.UpChanged {
+			sections = append(sections, "Up")
+		}
+		if mismatch.DownChanged {
+			sections = append(sections, "Down")
+		}
+		infof("   ❌ %s: %v section changed since it was applied\n", mismatch.Migration.FileName(), sections)
+	}
+
+	for _, migration := range report.Unrecorded {
+		infof("   ⚠️  %s: no checksum recorded (applied before checksum tracking)\n", migration.FileName())
+	}
+
+	if len(report.Outdated) > 0 {
+		infof("ℹ️  %d applied migration(s) match checksums recorded in an older format, migrate or verify --repair-checksums re-records them\n", len(report.Outdated))
+	}
+
+	switch {
+	case len(report.Mismatches) > 0:
+	case len(report.Unrecorded) > 0:
+		infof("⚠️  %d applied migration(s) could not be verified, the others match their recorded checksums\n", len(report.Unrecorded))
+	default:
+		infof("✅ All applied migrations match their recorded checksums\n")
+	}
+}
@@ -0,0 +1,159 @@
+package migroCMD
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestCompareChecksumsLegacyFormat(t *testing.T) {
+	dir := t.TempDir()
+	content := "-- +goose Up\n\n-- users\nCREATE TABLE a (id int); CREATE TABLE b (id int);\n-- +goose Down\nDROP TABLE b; DROP TABLE a;\n"
+	if err := os.WriteFile(filepath.Join(dir, "20240101120000_create.sql"), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	migrations, err := loadMigrations(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	migration := migrations[0]
+	legacy, err := parseLegacyMigrationSQL(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if legacy.UpChecksum() == migration.UpChecksum() {
+		t.Fatal("test file must split differently in the two formats")
+	}
+
+	tests := []struct {
+		name         string
+		sums         recordedChecksums
+		wantOutdated bool
+		wantUp       bool
+		wantDown     bool
+	}{
+		{
+			name:         "format 1 checksums of an unchanged file",
+			sums:         recordedChecksums{up: legacy.UpChecksum(), down: legacy.DownChecksum(), format: 1},
+			wantOutdated: true,
+		},
+		{
+			name:         "format 1 row recorded with the current splitting",
+			sums:         recordedChecksums{up: migration.UpChecksum(), down: migration.DownChecksum(), format: 1},
+			wantOutdated: true,
+		},
+		{
+			name:   "format 1 checksums of a changed file",
+			sums:   recordedChecksums{up: "edited", down: legacy.DownChecksum(), format: 1},
+			wantUp: true,
+		},
+		{
+			name:     "format 1 checksums are not accepted in the current format",
+			sums:     recordedChecksums{up: legacy.UpChecksum(), down: legacy.DownChecksum(), format: checksumFormat},
+			wantUp:   true,
+			wantDown: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report, err := compareChecksums(migrations, []int64{migration.Version}, map[int64]recordedChecksums{migration.Version: tt.sums})
+			if err != nil {
+				t.Fatalf("compareChecksums() error = %v", err)
+			}
+			if got := len(report.Outdated) == 1; got != tt.wantOutdated {
+				t.Errorf("outdated = %v, want %v", got, tt.wantOutdated)
+			}
+			var gotUp, gotDown bool
+			if len(report.Mismatches) == 1 {
+				gotUp, gotDown = report.Mismatches[0].UpChanged, report.Mismatches[0].DownChanged
+			}
+			if gotUp != tt.wantUp || gotDown != tt.wantDown {
+				t.Errorf("changed = (up %v, down %v), want (up %v, down %v)", gotUp, gotDown, tt.wantUp, tt.wantDown)
+			}
+		})
+	}
+}
+
+func TestCompareChecksums(t *testing.T) {
+	migrations := []*Migration{
+		{Version: 1, Name: "one", Up: []string{"CREATE TABLE a (id int);"}, Down: []string{"DROP TABLE a;"}},
+		{Version: 2, Name: "two", Up: []string{"CREATE TABLE b (id int);"}, Down: []string{"DROP TABLE b;"}},
+		{Version: 3, Name: "three", Up: []string{"CREATE TABLE c (id int);"}, Down: []string{"DROP TABLE c;"}},
+	}
+	current := func(migration *Migration) recordedChecksums {
+		return recordedChecksums{up: migration.UpChecksum(), down: migration.DownChecksum(), format: checksumFormat}
+	}
+
+	tests := []struct {
+		name           string
+		applied        []int64
+		recorded       map[int64]recordedChecksums
+		wantMismatches map[int64][2]bool // version -> up, down changed
+		wantUnrecorded []int64
+	}{
+		{
+			name:     "all match",
+			applied:  []int64{1, 2},
+			recorded: map[int64]recordedChecksums{1: current(migrations[0]), 2: current(migrations[1])},
+		},
+		{
+			name:    "up section changed",
+			applied: []int64{1, 2},
+			recorded: map[int64]recordedChecksums{
+				1: current(migrations[0]),
+				2: {up: "old", down: migrations[1].DownChecksum(), format: checksumFormat},
+			},
+			wantMismatches: map[int64][2]bool{2: {true, false}},
+		},
+		{
+			name:    "both sections changed",
+			applied: []int64{1},
+			recorded: map[int64]recordedChecksums{
+				1: {up: "old", down: "old", format: checksumFormat},
+			},
+			wantMismatches: map[int64][2]bool{1: {true, true}},
+		},
+		{
+			name:           "applied before checksum tracking",
+			applied:        []int64{1, 2},
+			recorded:       map[int64]recordedChecksums{1: current(migrations[0])},
+			wantUnrecorded: []int64{2},
+		},
+		{
+			name:     "pending and missing migrations are not checked",
+			applied:  []int64{1, 4},
+			recorded: map[int64]recordedChecksums{1: current(migrations[0]), 4: {up: "x", down: "x", format: checksumFormat}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report, err := compareChecksums(migrations, tt.applied, tt.recorded)
+			if err != nil {
+				t.Fatalf("compareChecksums() error = %v", err)
+			}
+
+			gotMismatches := make(map[int64][2]bool)
+			for _, mismatch := range report.Mismatches {
+				gotMismatches[mismatch.Migration.Version] = [2]bool{mismatch.UpChanged, mismatch.DownChanged}
+			}
+			if len(gotMismatches) != len(tt.wantMismatches) {
+				t.Errorf("mismatches = %v, want %v", gotMismatches, tt.wantMismatches)
+			}
+			for version, want := range tt.wantMismatches {
+				if gotMismatches[version] != want {
+					t.Errorf("mismatch of version %d = %v, want %v", version, gotMismatches[version], want)
+				}
+			}
+
+			if got := migrationVersions(report.Unrecorded); !reflect.DeepEqual(got, tt.wantUnrecorded) {
+				t.Errorf("unrecorded = %v, want %v", got, tt.wantUnrecorded)
+			}
+			if len(report.Outdated) > 0 {
+				t.Errorf("outdated = %v, want none", migrationVersions(report.Outdated))
+			}
+		})
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"time"
 
 	migroCMD "github.com/ChungNQ511/migro/cmd"
 	"github.com/urfave/cli/v2"
@@ -19,6 +20,58 @@ func getGlobalConfig() *migroCMD.CONFIG {
 	return GlobalConfig
 }
 
+// findConfigFile returns the --config path, or the first default config
+// file found in the current directory
+func findConfigFile(c *cli.Context) (string, error) {
+	if configPath := c.String("config"); configPath != "" {
+		return configPath, nil
+	}
+
+	// Try multiple possible config file names
+	possibleConfigs := []string{
+		"migro.yaml",
+		"migro.yml",
+		"config.yaml",
+		"config.yml",
+	}
+
+	for _, fileName := range possibleConfigs {
+		if _, err := os.Stat(fileName); err == nil {
+			fmt.Fprintf(os.Stderr, "📄 Using config file: %s\n", fileName)
+			return fileName, nil
+		}
+	}
+
+	return "", fmt.Errorf("❌ No config file found. Run migro init to create migro.yaml, or specify --config flag")
+}
+
+// applyLockWaitFlag overrides the configured lock timeout with --lock-wait
+func applyLockWaitFlag(c *cli.Context) {
+	if c.IsSet("lock-wait") {
+		getGlobalConfig().MIGRATION_LOCK_TIMEOUT_SECONDS = c.Int("lock-wait")
+	}
+}
+
+// outputFormat returns the command's own --output, falling back to the
+// global --output or OUTPUT_FORMAT
+func outputFormat(c *cli.Context) string {
+	if output := c.String("output"); output != "" {
+		return output
+	}
+	return getGlobalConfig().OUTPUT_FORMAT
+}
+
+// migrateOptions reads the flags shared by migrate and rollback commands
+func migrateOptions(c *cli.Context) migroCMD.MigrateOptions {
+	return migroCMD.MigrateOptions{
+		DryRun: c.Bool("dry-run"),
+		Output: outputFormat(c),
+		Target: c.String("to"),
+
+		AllowOutOfOrder: c.Bool("allow-out-of-order"),
+	}
+}
+
 func main() {
 	app := &cli.App{
 		Name:  "migro",
@@ -30,41 +83,103 @@ func main() {
 				Usage:   "Path to config file (default: migro.yaml)",
 				EnvVars: []string{"MIGRO_CONFIG"},
 			},
+			&cli.StringFlag{
+				Name:    "env",
+				Aliases: []string{"e"},
+				Usage:   "Environment profile from the environments section of the config file",
+				EnvVars: []string{"MIGRO_ENV"},
+			},
+			&cli.StringFlag{
+				Name:    "output",
+				Aliases: []string{"o"},
+				Usage:   "Output format for data: table, json, yaml or csv (default: OUTPUT_FORMAT or table)",
+				EnvVars: []string{"MIGRO_OUTPUT"},
+			},
+			&cli.IntFlag{
+				Name:    "wait",
+				Usage:   "Seconds to keep retrying until the database accepts connections (default: DATABASE_WAIT_SECONDS)",
+				EnvVars: []string{"MIGRO_WAIT"},
+			},
 		},
 		Before: func(c *cli.Context) error {
-			configPath := c.String("config")
-
-			// If no config specified, try to find migro.yaml in current directory
-			if configPath == "" {
-				// Try multiple possible config file names
-				possibleConfigs := []string{
-					"migro.yaml",
-					"migro.yml",
-					"config.yaml",
-					"config.yml",
-				}
-
-				for _, fileName := range possibleConfigs {
-					if _, err := os.Stat(fileName); err == nil {
-						configPath = fileName
-						fmt.Printf("📄 Using config file: %s\n", fileName)
-						break
-					}
-				}
+			// init writes the config file and doctor reports config
+			// problems itself, so both must run without a valid config
+			switch c.Args().First() {
+			case "init", "doctor":
+				return nil
+			}
 
-				if configPath == "" {
-					return fmt.Errorf("❌ No config file found. Please create migro.yaml or specify --config flag")
-				}
+			configPath, err := findConfigFile(c)
+			if err != nil {
+				return err
 			}
 
-			cfg, err := migroCMD.LoadConfig(configPath)
+			cfg, err := migroCMD.LoadConfig(configPath, c.String("env"))
 			if err != nil {
 				return fmt.Errorf("❌ Failed to load config from %s: %w", configPath, err)
 			}
+			if c.IsSet("wait") {
+				cfg.DATABASE_WAIT_SECONDS = c.Int("wait")
+			}
+			if c.IsSet("output") {
+				cfg.OUTPUT_FORMAT = c.String("output")
+			}
+			if err := migroCMD.ValidateOutputFormat(cfg.OUTPUT_FORMAT); err != nil {
+				return err
+			}
 			setGlobalConfig(cfg)
+			migroCMD.PrintEnvironment(cfg)
 			return nil
 		},
 		Commands: []*cli.Command{
+			{
+				Name:  "init",
+				Usage: "Create migro.yaml and the migration directories",
+				Flags: []cli.Flag{
+					&cli.StringFlag{Name: "host", Usage: "Database host"},
+					&cli.StringFlag{Name: "port", Usage: "Database port"},
+					&cli.StringFlag{Name: "user", Usage: "Database user"},
+					&cli.StringFlag{Name: "password", Usage: "Database password, or ${VAR} to read it from the environment"},
+					&cli.StringFlag{Name: "database", Usage: "Database name"},
+					&cli.StringFlag{Name: "sslmode", Usage: "SSL mode: disable, require, verify-ca or verify-full"},
+					&cli.StringFlag{Name: "migration-dir", Usage: "Migration directory"},
+					&cli.StringFlag{Name: "query-dir", Usage: "Query directory"},
+					&cli.StringFlag{
+						Name:  "initial-migration",
+						Usage: "Also create an empty migration with this name",
+					},
+					&cli.BoolFlag{
+						Name:  "baseline",
+						Usage: "Write a baseline migration from the existing database and mark it as applied",
+					},
+					&cli.BoolFlag{
+						Name:  "force",
+						Usage: "Overwrite an existing config file",
+					},
+					&cli.BoolFlag{
+						Name:    "yes",
+						Aliases: []string{"y"},
+						Usage:   "Use defaults for everything not given as a flag instead of prompting",
+					},
+				},
+				Action: func(c *cli.Context) error {
+					return migroCMD.InitProject(migroCMD.InitOptions{
+						ConfigPath:       c.String("config"),
+						Host:             c.String("host"),
+						Port:             c.String("port"),
+						User:             c.String("user"),
+						Password:         c.String("password"),
+						Database:         c.String("database"),
+						SSLMode:          c.String("sslmode"),
+						MigrationDir:     c.String("migration-dir"),
+						QueryDir:         c.String("query-dir"),
+						InitialMigration: c.String("initial-migration"),
+						Baseline:         c.Bool("baseline"),
+						Force:            c.Bool("force"),
+						Yes:              c.Bool("yes"),
+					})
+				},
+			},
 			{
 				Name:  "create-migration",
 				Usage: "Create a new migration file",
@@ -80,6 +195,49 @@ func main() {
 					return migroCMD.CreateEmptyMigration(getGlobalConfig().MIGRATION_DIR, c.String("name"))
 				},
 			},
+			{
+				Name:  "diff",
+				Usage: "Generate a migration that turns the live database into a desired schema file",
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "from-db",
+						Usage: "Compare from the live database",
+					},
+					&cli.StringFlag{
+						Name:     "to",
+						Usage:    "Desired schema file: schema.sql, or .yaml / .yml",
+						Required: true,
+					},
+					&cli.StringFlag{
+						Name:     "name",
+						Aliases:  []string{"n"},
+						Usage:    "Name of the migration",
+						Required: true,
+					},
+					&cli.StringFlag{
+						Name:  "scratch-url",
+						Usage: "Connection URL of an empty database to load the desired schema in (default: a temporary database, needs CREATEDB)",
+					},
+					&cli.BoolFlag{
+						Name:  "dry-run",
+						Usage: "Print the migration instead of writing it",
+					},
+				},
+				Action: func(c *cli.Context) error {
+					pool, err := migroCMD.DBConnection(getGlobalConfig())
+					if err != nil {
+						return err
+					}
+					defer pool.Close()
+					return migroCMD.GenerateDiffMigration(getGlobalConfig(), pool, migroCMD.DiffOptions{
+						FromDB:     c.Bool("from-db"),
+						To:         c.String("to"),
+						Name:       c.String("name"),
+						ScratchURL: c.String("scratch-url"),
+						DryRun:     c.Bool("dry-run"),
+					})
+				},
+			},
 			{
 				Name:  "create-table",
 				Usage: "Create a new table with columns",
@@ -87,7 +245,7 @@ func main() {
 					&cli.StringFlag{
 						Name:     "table",
 						Aliases:  []string{"t"},
-						Usage:    "Table name to create",
+						Usage:    "Table name to create (table or schema.table)",
 						Required: true,
 					},
 					&cli.StringFlag{
@@ -98,7 +256,10 @@ func main() {
 					},
 				},
 				Action: func(c *cli.Context) error {
-					pool := migroCMD.DBConnection(getGlobalConfig())
+					pool, err := migroCMD.DBConnection(getGlobalConfig())
+					if err != nil {
+						return err
+					}
 					defer pool.Close()
 					return migroCMD.CreateTable(getGlobalConfig(), pool, c.String("table"), c.String("columns"))
 				},
@@ -110,7 +271,7 @@ func main() {
 					&cli.StringFlag{
 						Name:     "table",
 						Aliases:  []string{"t"},
-						Usage:    "Table name to add columns to",
+						Usage:    "Table name to add columns to (table or schema.table)",
 						Required: true,
 					},
 					&cli.StringFlag{
@@ -121,7 +282,10 @@ func main() {
 					},
 				},
 				Action: func(c *cli.Context) error {
-					pool := migroCMD.DBConnection(getGlobalConfig())
+					pool, err := migroCMD.DBConnection(getGlobalConfig())
+					if err != nil {
+						return err
+					}
 					defer pool.Close()
 					return migroCMD.AddColumn(getGlobalConfig(), pool, c.String("table"), c.String("columns"))
 				},
@@ -133,7 +297,7 @@ func main() {
 					&cli.StringFlag{
 						Name:     "table",
 						Aliases:  []string{"t"},
-						Usage:    "Table name to delete columns from",
+						Usage:    "Table name to delete columns from (table or schema.table)",
 						Required: true,
 					},
 					&cli.StringFlag{
@@ -144,32 +308,45 @@ func main() {
 					},
 				},
 				Action: func(c *cli.Context) error {
-					pool := migroCMD.DBConnection(getGlobalConfig())
+					pool, err := migroCMD.DBConnection(getGlobalConfig())
+					if err != nil {
+						return err
+					}
 					defer pool.Close()
 					return migroCMD.DeleteColumn(getGlobalConfig(), pool, c.String("table"), c.String("columns"))
 				},
 			},
 			{
-				Name:  "read-table",
-				Usage: "Read column information of a table",
+				Name:  "list-tables",
+				Usage: "List tables, views and materialized views with sizes and the migration that created them",
+				Action: func(c *cli.Context) error {
+					pool, err := migroCMD.DBConnection(getGlobalConfig())
+					if err != nil {
+						return err
+					}
+					defer pool.Close()
+					return migroCMD.ListTables(getGlobalConfig(), pool)
+				},
+			},
+			{
+				Name:    "describe",
+				Aliases: []string{"read-table"},
+				Usage:   "Show the columns, keys, indexes, checks, triggers and size of a table",
 				Flags: []cli.Flag{
 					&cli.StringFlag{
 						Name:     "table",
 						Aliases:  []string{"t"},
-						Usage:    "Table name to read columns from",
+						Usage:    "Table name to describe (table or schema.table)",
 						Required: true,
 					},
 				},
 				Action: func(c *cli.Context) error {
-					pool := migroCMD.DBConnection(getGlobalConfig())
-					defer pool.Close()
-					columns, err := migroCMD.ReadColumnOfTable(pool, c.String("table"))
+					pool, err := migroCMD.DBConnection(getGlobalConfig())
 					if err != nil {
 						return err
 					}
-					fmt.Println("✅ Read column of table", c.String("table"), "success")
-					fmt.Println(columns)
-					return nil
+					defer pool.Close()
+					return migroCMD.DescribeTable(getGlobalConfig(), pool, c.String("table"))
 				},
 			},
 			{
@@ -179,56 +356,273 @@ func main() {
 					&cli.StringFlag{
 						Name:     "table",
 						Aliases:  []string{"t"},
-						Usage:    "Table name to reset sequence for",
+						Usage:    "Table name to reset sequence for (table or schema.table)",
 						Required: true,
 					},
 				},
 				Action: func(c *cli.Context) error {
-					pool := migroCMD.DBConnection(getGlobalConfig())
+					pool, err := migroCMD.DBConnection(getGlobalConfig())
+					if err != nil {
+						return err
+					}
 					defer pool.Close()
-					return migroCMD.ResetSequenceOfTable(pool, c.String("table"))
+					return migroCMD.ResetSequenceOfTable(getGlobalConfig(), pool, c.String("table"))
 				},
 			},
 			{
 				Name:  "rollback",
-				Usage: "Rollback specified number of migrations",
+				Usage: "Rollback specified number of migrations or down to a version",
 				Flags: []cli.Flag{
 					&cli.IntFlag{
-						Name:     "count",
-						Aliases:  []string{"c"},
-						Usage:    "Number of migrations to rollback",
-						Required: true,
+						Name:    "count",
+						Aliases: []string{"c"},
+						Usage:   "Number of migrations to rollback",
+					},
+					&cli.StringFlag{
+						Name:  "to",
+						Usage: "Rollback down to this version (the version itself stays applied)",
+					},
+					&cli.IntFlag{
+						Name:  "lock-wait",
+						Usage: "Seconds to wait for another running migration to release the lock (default: MIGRATION_LOCK_TIMEOUT_SECONDS or 60)",
+					},
+					&cli.BoolFlag{
+						Name:  "dry-run",
+						Usage: "Print the migrations and SQL that would run without touching the database",
+					},
+					&cli.StringFlag{
+						Name:    "output",
+						Aliases: []string{"o"},
+						Usage:   "Dry run output format: table, json or yaml (default: global --output)",
 					},
 				},
 				Action: func(c *cli.Context) error {
-					pool := migroCMD.DBConnection(getGlobalConfig())
+					applyLockWaitFlag(c)
+					pool, err := migroCMD.DBConnection(getGlobalConfig())
+					if err != nil {
+						return err
+					}
 					defer pool.Close()
-					return migroCMD.Rollback(getGlobalConfig(), pool, c.Int("count"))
+					return migroCMD.Rollback(getGlobalConfig(), pool, c.Int("count"), migrateOptions(c))
 				},
 			},
 			{
 				Name:  "rollback-all",
 				Usage: "Rollback ALL migrations (WARNING: This will reset your database)",
+				Flags: []cli.Flag{
+					&cli.IntFlag{
+						Name:  "lock-wait",
+						Usage: "Seconds to wait for another running migration to release the lock (default: MIGRATION_LOCK_TIMEOUT_SECONDS or 60)",
+					},
+					&cli.BoolFlag{
+						Name:  "dry-run",
+						Usage: "Print the migrations and SQL that would run without touching the database",
+					},
+					&cli.StringFlag{
+						Name:    "output",
+						Aliases: []string{"o"},
+						Usage:   "Dry run output format: table, json or yaml (default: global --output)",
+					},
+				},
 				Action: func(c *cli.Context) error {
-					pool := migroCMD.DBConnection(getGlobalConfig())
+					applyLockWaitFlag(c)
+					pool, err := migroCMD.DBConnection(getGlobalConfig())
+					if err != nil {
+						return err
+					}
 					defer pool.Close()
-					return migroCMD.RollbackAll(getGlobalConfig(), pool)
+					return migroCMD.RollbackAll(getGlobalConfig(), pool, migrateOptions(c))
 				},
 			},
 			{
 				Name:  "migrate",
 				Usage: "Run database migration up to latest version",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "to",
+						Usage: "Migrate up to and including this version instead of latest",
+					},
+					&cli.BoolFlag{
+						Name:  "allow-out-of-order",
+						Usage: "Apply unapplied migrations older than the current version (default: MIGRATION_ALLOW_OUT_OF_ORDER)",
+					},
+					&cli.IntFlag{
+						Name:  "lock-wait",
+						Usage: "Seconds to wait for another running migration to release the lock (default: MIGRATION_LOCK_TIMEOUT_SECONDS or 60)",
+					},
+					&cli.BoolFlag{
+						Name:  "dry-run",
+						Usage: "Print the migrations and SQL that would run without touching the database",
+					},
+					&cli.StringFlag{
+						Name:    "output",
+						Aliases: []string{"o"},
+						Usage:   "Dry run output format: table, json or yaml (default: global --output)",
+					},
+				},
+				Action: func(c *cli.Context) error {
+					applyLockWaitFlag(c)
+					pool, err := migroCMD.DBConnection(getGlobalConfig())
+					if err != nil {
+						return err
+					}
+					defer pool.Close()
+					return migroCMD.MigrateUp(getGlobalConfig(), pool, migrateOptions(c))
+				},
+			},
+			{
+				Name:  "baseline",
+				Usage: "Write the schema of a database without migration history as a baseline migration and mark it as applied",
+				Flags: []cli.Flag{
+					&cli.IntFlag{
+						Name:  "lock-wait",
+						Usage: "Seconds to wait for the migration lock (default: MIGRATION_LOCK_TIMEOUT_SECONDS or 60)",
+					},
+				},
+				Action: func(c *cli.Context) error {
+					applyLockWaitFlag(c)
+					pool, err := migroCMD.DBConnection(getGlobalConfig())
+					if err != nil {
+						return err
+					}
+					defer pool.Close()
+					return migroCMD.CreateBaseline(getGlobalConfig(), pool)
+				},
+			},
+			{
+				Name:  "squash",
+				Usage: "Replace all migrations older than a version with one migration holding the schema they build",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:     "before",
+						Usage:    "Squash migrations with a version lower than this one",
+						Required: true,
+					},
+					&cli.StringFlag{
+						Name:  "scratch-url",
+						Usage: "Connection URL of an empty database to replay migrations in (default: a temporary database, needs CREATEDB)",
+					},
+					&cli.BoolFlag{
+						Name:  "record-only",
+						Usage: "Only update the version table of this database for a squash written before",
+					},
+					&cli.IntFlag{
+						Name:  "lock-wait",
+						Usage: "Seconds to wait for the migration lock (default: MIGRATION_LOCK_TIMEOUT_SECONDS or 60)",
+					},
+				},
+				Action: func(c *cli.Context) error {
+					applyLockWaitFlag(c)
+					pool, err := migroCMD.DBConnection(getGlobalConfig())
+					if err != nil {
+						return err
+					}
+					defer pool.Close()
+					return migroCMD.SquashMigrations(getGlobalConfig(), pool, migroCMD.SquashOptions{
+						Before:     c.String("before"),
+						ScratchURL: c.String("scratch-url"),
+						RecordOnly: c.Bool("record-only"),
+					})
+				},
+			},
+			{
+				Name:  "verify",
+				Usage: "Verify applied migrations still match their files",
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "repair-checksums",
+						Usage: "Accept the current file content and update recorded checksums",
+					},
+				},
+				Action: func(c *cli.Context) error {
+					pool, err := migroCMD.DBConnection(getGlobalConfig())
+					if err != nil {
+						return err
+					}
+					defer pool.Close()
+					return migroCMD.VerifyMigrations(getGlobalConfig(), pool, c.Bool("repair-checksums"))
+				},
+			},
+			{
+				Name:  "drift",
+				Usage: "Compare the live schema with the schema the migration files build, exit non-zero on drift",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "scratch-url",
+						Usage: "Connection URL of an empty database to replay migrations in (default: a temporary database, needs CREATEDB)",
+					},
+				},
+				Action: func(c *cli.Context) error {
+					pool, err := migroCMD.DBConnection(getGlobalConfig())
+					if err != nil {
+						return err
+					}
+					defer pool.Close()
+					return migroCMD.DetectDrift(getGlobalConfig(), pool, migroCMD.DriftOptions{
+						ScratchURL: c.String("scratch-url"),
+					})
+				},
+			},
+			{
+				Name:  "reconcile",
+				Usage: "Resolve applied migrations whose files are missing",
 				Action: func(c *cli.Context) error {
-					pool := migroCMD.DBConnection(getGlobalConfig())
+					applyLockWaitFlag(c)
+					pool, err := migroCMD.DBConnection(getGlobalConfig())
+					if err != nil {
+						return err
+					}
 					defer pool.Close()
-					return migroCMD.MigrateUp(getGlobalConfig(), pool)
+					return migroCMD.Reconcile(getGlobalConfig(), pool)
+				},
+				Flags: []cli.Flag{
+					&cli.IntFlag{
+						Name:  "lock-wait",
+						Usage: "Seconds to wait for the migration lock (default: MIGRATION_LOCK_TIMEOUT_SECONDS or 60)",
+					},
+				},
+			},
+			{
+				Name:  "wait-for-db",
+				Usage: "Wait until the database accepts connections",
+				Flags: []cli.Flag{
+					&cli.IntFlag{
+						Name:  "timeout",
+						Usage: "Seconds to wait before giving up",
+						Value: 60,
+					},
+				},
+				Action: func(c *cli.Context) error {
+					return migroCMD.WaitForDatabase(getGlobalConfig(), time.Duration(c.Int("timeout"))*time.Second)
+				},
+			},
+			{
+				Name:  "doctor",
+				Usage: "Check the config, database connection, tools and migration directory",
+				Action: func(c *cli.Context) error {
+					// A missing config file is reported as a failed check
+					configPath, _ := findConfigFile(c)
+					wait := -1
+					if c.IsSet("wait") {
+						wait = c.Int("wait")
+					}
+					return migroCMD.RunDoctor(migroCMD.DoctorOptions{
+						ConfigPath: configPath,
+						Env:        c.String("env"),
+						Wait:       wait,
+					})
 				},
 			},
 			{
 				Name:  "status",
 				Usage: "Show current migration status",
 				Action: func(c *cli.Context) error {
-					return migroCMD.ShowMigrationStatus(getGlobalConfig())
+					pool, err := migroCMD.DBConnection(getGlobalConfig())
+					if err != nil {
+						return err
+					}
+					defer pool.Close()
+					return migroCMD.ShowMigrationStatus(getGlobalConfig(), pool)
 				},
 			},
 			{
@@ -238,7 +632,7 @@ func main() {
 					&cli.StringFlag{
 						Name:     "table",
 						Aliases:  []string{"t"},
-						Usage:    "Table name to insert data into",
+						Usage:    "Table name to insert data into (table or schema.table)",
 						Required: true,
 					},
 					&cli.StringFlag{
@@ -249,7 +643,10 @@ func main() {
 					},
 				},
 				Action: func(c *cli.Context) error {
-					pool := migroCMD.DBConnection(getGlobalConfig())
+					pool, err := migroCMD.DBConnection(getGlobalConfig())
+					if err != nil {
+						return err
+					}
 					defer pool.Close()
 					return migroCMD.InsertData(getGlobalConfig(), pool, c.String("table"), c.String("data"))
 				},
@@ -261,7 +658,7 @@ func main() {
 					&cli.StringFlag{
 						Name:     "table",
 						Aliases:  []string{"t"},
-						Usage:    "Table name to update data in",
+						Usage:    "Table name to update data in (table or schema.table)",
 						Required: true,
 					},
 					&cli.StringFlag{
@@ -278,7 +675,10 @@ func main() {
 					},
 				},
 				Action: func(c *cli.Context) error {
-					pool := migroCMD.DBConnection(getGlobalConfig())
+					pool, err := migroCMD.DBConnection(getGlobalConfig())
+					if err != nil {
+						return err
+					}
 					defer pool.Close()
 					return migroCMD.UpdateData(getGlobalConfig(), pool, c.String("table"), c.String("data"), c.String("where"))
 				},
@@ -290,7 +690,7 @@ func main() {
 					&cli.StringFlag{
 						Name:     "table",
 						Aliases:  []string{"t"},
-						Usage:    "Table name to select from",
+						Usage:    "Table name to select from (table or schema.table)",
 						Required: true,
 					},
 					&cli.StringFlag{
@@ -307,7 +707,10 @@ func main() {
 					},
 				},
 				Action: func(c *cli.Context) error {
-					pool := migroCMD.DBConnection(getGlobalConfig())
+					pool, err := migroCMD.DBConnection(getGlobalConfig())
+					if err != nil {
+						return err
+					}
 					defer pool.Close()
 					return migroCMD.SelectOne(getGlobalConfig(), pool, c.String("table"), c.String("columns"), c.String("where"))
 				},
@@ -319,7 +722,7 @@ func main() {
 					&cli.StringFlag{
 						Name:     "table",
 						Aliases:  []string{"t"},
-						Usage:    "Table name to select from",
+						Usage:    "Table name to select from (table or schema.table)",
 						Required: true,
 					},
 					&cli.StringFlag{
@@ -341,7 +744,10 @@ func main() {
 					},
 				},
 				Action: func(c *cli.Context) error {
-					pool := migroCMD.DBConnection(getGlobalConfig())
+					pool, err := migroCMD.DBConnection(getGlobalConfig())
+					if err != nil {
+						return err
+					}
 					defer pool.Close()
 					return migroCMD.SelectMany(getGlobalConfig(), pool, c.String("table"), c.String("columns"), c.String("where"), c.Int("limit"))
 				},
@@ -353,7 +759,7 @@ func main() {
 					&cli.StringFlag{
 						Name:     "table",
 						Aliases:  []string{"t"},
-						Usage:    "Table name to delete from",
+						Usage:    "Table name to delete from (table or schema.table)",
 						Required: true,
 					},
 					&cli.StringFlag{
@@ -364,7 +770,10 @@ func main() {
 					},
 				},
 				Action: func(c *cli.Context) error {
-					pool := migroCMD.DBConnection(getGlobalConfig())
+					pool, err := migroCMD.DBConnection(getGlobalConfig())
+					if err != nil {
+						return err
+					}
 					defer pool.Close()
 					return migroCMD.SoftDelete(getGlobalConfig(), pool, c.String("table"), c.String("where"))
 				},